@@ -0,0 +1,218 @@
+// Package delayqs provides a delay queue: items become available once their ready time has passed.
+//
+// Items are kept in an mpqs.PriorityQueue ordered by ready time, with ties served in insertion
+// order. Dequeue returns the earliest item only if it is ready, while DequeueContext waits until
+// it is. Time is read from a Clock, which tests can replace to control readiness deterministically.
+// A Queue is safe for concurrent use.
+package delayqs
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/byExist/priorityqueues/mpqs"
+)
+
+// ErrClosed is returned when enqueueing into a closed queue, or when waiting
+// to dequeue from a queue that is closed and empty.
+var ErrClosed = errors.New("delayqs: queue closed")
+
+// Clock is the source of time for a Queue.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock backed by the time package. It is the default.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type entry[T any] struct {
+	item    T
+	readyAt time.Time
+}
+
+// Queue is a delay queue of items of type T.
+type Queue[T any] struct {
+	mu      sync.Mutex
+	pq      *mpqs.PriorityQueue[entry[T], time.Time]
+	clock   Clock
+	closed  bool
+	changed chan struct{}
+}
+
+// New creates an empty delay queue using SystemClock.
+func New[T any]() *Queue[T] {
+	return &Queue[T]{
+		pq:    mpqs.New(mpqs.StableMinFirstFunc[entry[T]](time.Time.Compare)),
+		clock: SystemClock,
+	}
+}
+
+// SetClock replaces the clock used to decide readiness and to wait for deadlines.
+func SetClock[T any](q *Queue[T], clock Clock) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.clock = clock
+	q.broadcast()
+}
+
+// Enqueue inserts item to become ready at readyAt.
+// It returns ErrClosed if the queue is closed.
+func Enqueue[T any](q *Queue[T], item T, readyAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	mpqs.Enqueue(q.pq, entry[T]{item, readyAt}, readyAt)
+	q.broadcast()
+	return nil
+}
+
+// EnqueueAfter inserts item to become ready once delay has elapsed on the queue's clock.
+// It returns ErrClosed if the queue is closed.
+func EnqueueAfter[T any](q *Queue[T], item T, delay time.Duration) error {
+	q.mu.Lock()
+	now := q.clock.Now()
+	q.mu.Unlock()
+	return Enqueue(q, item, now.Add(delay))
+}
+
+// Dequeue removes and returns the earliest item if its ready time has passed, without blocking.
+// The boolean indicates whether an item was returned.
+func Dequeue[T any](q *Queue[T]) (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item, _, ok := dequeueReady(q)
+	return item, ok
+}
+
+// DequeueContext removes and returns the earliest item, waiting until it is ready.
+// It returns ErrClosed once the queue is closed and empty, or ctx.Err() if ctx is done first.
+// Items enqueued while waiting with an earlier ready time are served first.
+func DequeueContext[T any](ctx context.Context, q *Queue[T]) (T, error) {
+	var zero T
+	q.mu.Lock()
+	for {
+		item, wait, ok := dequeueReady(q)
+		if ok {
+			q.mu.Unlock()
+			return item, nil
+		}
+		if mpqs.Len(q.pq) == 0 && q.closed {
+			q.mu.Unlock()
+			return zero, ErrClosed
+		}
+		changed := q.wait()
+		var deadline <-chan time.Time
+		if mpqs.Len(q.pq) > 0 {
+			deadline = q.clock.After(wait)
+		}
+		q.mu.Unlock()
+		select {
+		case <-changed:
+		case <-deadline:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+		q.mu.Lock()
+	}
+}
+
+// Peek returns the earliest item and its ready time without removing it, whether it is ready or not.
+// The boolean indicates whether the queue is non-empty.
+func Peek[T any](q *Queue[T]) (T, time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := mpqs.Peek(q.pq)
+	return e.item, e.readyAt, ok
+}
+
+// Len returns the number of items in the queue, ready or not.
+func Len[T any](q *Queue[T]) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return mpqs.Len(q.pq)
+}
+
+// Ready returns the number of items whose ready time has passed. It runs in O(n).
+func Ready[T any](q *Queue[T]) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+	n := 0
+	for e := range mpqs.All(q.pq) {
+		if !e.readyAt.After(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// All returns an iterator over a snapshot of the items and their ready times in unspecified order.
+func All[T any](q *Queue[T]) iter.Seq2[T, time.Time] {
+	q.mu.Lock()
+	entries := make([]entry[T], 0, mpqs.Len(q.pq))
+	for e := range mpqs.All(q.pq) {
+		entries = append(entries, e)
+	}
+	q.mu.Unlock()
+	return func(yield func(T, time.Time) bool) {
+		for _, e := range entries {
+			if !yield(e.item, e.readyAt) {
+				return
+			}
+		}
+	}
+}
+
+// Close marks the queue as closed and wakes every goroutine blocked in DequeueContext.
+// Items still in the queue can be dequeued after Close once they are ready.
+// Closing an already closed queue has no effect.
+func Close[T any](q *Queue[T]) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.broadcast()
+}
+
+// dequeueReady removes the earliest item if it is ready. Otherwise it returns how long
+// until the earliest item becomes ready. q.mu must be held.
+func dequeueReady[T any](q *Queue[T]) (T, time.Duration, bool) {
+	var zero T
+	e, ok := mpqs.Peek(q.pq)
+	if !ok {
+		return zero, 0, false
+	}
+	if wait := e.readyAt.Sub(q.clock.Now()); wait > 0 {
+		return zero, wait, false
+	}
+	mpqs.Dequeue(q.pq)
+	return e.item, 0, true
+}
+
+// wait returns a channel that is closed on the next change to the queue. q.mu must be held.
+func (q *Queue[T]) wait() <-chan struct{} {
+	if q.changed == nil {
+		q.changed = make(chan struct{})
+	}
+	return q.changed
+}
+
+// broadcast wakes all goroutines waiting on the channels returned by wait. q.mu must be held.
+func (q *Queue[T]) broadcast() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = nil
+	}
+}
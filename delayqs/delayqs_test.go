@@ -0,0 +1,264 @@
+package delayqs_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/delayqs"
+	"github.com/stretchr/testify/assert"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeClock is a manually advanced Clock.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: epoch}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{c.now.Add(d), ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires every waiter whose time has come.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+func (c *fakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func newQueue() (*delayqs.Queue[string], *fakeClock) {
+	q := delayqs.New[string]()
+	clock := newFakeClock()
+	delayqs.SetClock(q, clock)
+	return q, clock
+}
+
+func TestDequeueOnlyReady(t *testing.T) {
+	q, clock := newQueue()
+	assert.NoError(t, delayqs.EnqueueAfter(q, "b", 2*time.Second))
+	assert.NoError(t, delayqs.EnqueueAfter(q, "a", time.Second))
+	assert.NoError(t, delayqs.Enqueue(q, "c", epoch.Add(2*time.Second)))
+
+	item, ok := delayqs.Dequeue(q)
+	assert.False(t, ok)
+	assert.Empty(t, item)
+	assert.Equal(t, 3, delayqs.Len(q))
+	assert.Equal(t, 0, delayqs.Ready(q))
+
+	head, at, ok := delayqs.Peek(q)
+	assert.True(t, ok)
+	assert.Equal(t, "a", head)
+	assert.Equal(t, epoch.Add(time.Second), at)
+
+	clock.Advance(time.Second)
+	item, ok = delayqs.Dequeue(q)
+	assert.True(t, ok)
+	assert.Equal(t, "a", item)
+	_, ok = delayqs.Dequeue(q)
+	assert.False(t, ok)
+
+	// Items with the same ready time come out in insertion order.
+	clock.Advance(time.Hour)
+	assert.Equal(t, 2, delayqs.Ready(q))
+	item, _ = delayqs.Dequeue(q)
+	assert.Equal(t, "b", item)
+	item, _ = delayqs.Dequeue(q)
+	assert.Equal(t, "c", item)
+	_, _, ok = delayqs.Peek(q)
+	assert.False(t, ok)
+}
+
+func TestPastReadyTime(t *testing.T) {
+	q, _ := newQueue()
+	assert.NoError(t, delayqs.Enqueue(q, "late", epoch.Add(-time.Minute)))
+	item, ok := delayqs.Dequeue(q)
+	assert.True(t, ok)
+	assert.Equal(t, "late", item)
+}
+
+func TestExtremeReadyTimes(t *testing.T) {
+	q, _ := newQueue()
+	far := time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, delayqs.Enqueue(q, "far", far))
+	assert.NoError(t, delayqs.Enqueue(q, "recent", epoch.Add(-time.Second)))
+	assert.NoError(t, delayqs.Enqueue(q, "ancient", time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.NoError(t, delayqs.Enqueue(q, "zero", time.Time{}))
+
+	var got []string
+	for {
+		item, ok := delayqs.Dequeue(q)
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"zero", "ancient", "recent"}, got)
+	item, readyAt, ok := delayqs.Peek(q)
+	assert.True(t, ok)
+	assert.Equal(t, "far", item)
+	assert.Equal(t, far, readyAt)
+}
+
+func TestDequeueContextWaitsForDeadline(t *testing.T) {
+	q, clock := newQueue()
+	assert.NoError(t, delayqs.EnqueueAfter(q, "a", time.Minute))
+	got := make(chan string)
+	go func() {
+		item, err := delayqs.DequeueContext(context.Background(), q)
+		assert.NoError(t, err)
+		got <- item
+	}()
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(30 * time.Second)
+	select {
+	case <-got:
+		t.Fatal("dequeued before the deadline")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, "a", <-got)
+}
+
+func TestDequeueContextPrefersEarlierArrival(t *testing.T) {
+	q, clock := newQueue()
+	assert.NoError(t, delayqs.EnqueueAfter(q, "later", time.Hour))
+	got := make(chan string)
+	go func() {
+		item, err := delayqs.DequeueContext(context.Background(), q)
+		assert.NoError(t, err)
+		got <- item
+	}()
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+
+	// A new earlier item wakes the waiter, which then waits for the new deadline.
+	assert.NoError(t, delayqs.EnqueueAfter(q, "sooner", time.Second))
+	assert.Eventually(t, func() bool { return clock.Waiters() == 2 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	assert.Equal(t, "sooner", <-got)
+	assert.Equal(t, 1, delayqs.Len(q))
+}
+
+func TestDequeueContextCancel(t *testing.T) {
+	q, _ := newQueue()
+	assert.NoError(t, delayqs.EnqueueAfter(q, "a", time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := delayqs.DequeueContext(ctx, q)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, delayqs.Len(q))
+}
+
+func TestClose(t *testing.T) {
+	q, clock := newQueue()
+	errs := make(chan error)
+	go func() {
+		_, err := delayqs.DequeueContext(context.Background(), q)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	delayqs.Close(q)
+	assert.ErrorIs(t, <-errs, delayqs.ErrClosed)
+	assert.ErrorIs(t, delayqs.Enqueue(q, "x", epoch), delayqs.ErrClosed)
+
+	// Items queued before Close are still served once ready.
+	q, clock = newQueue()
+	assert.NoError(t, delayqs.EnqueueAfter(q, "a", time.Second))
+	delayqs.Close(q)
+	go func() {
+		item, err := delayqs.DequeueContext(context.Background(), q)
+		assert.Equal(t, "a", item)
+		errs <- err
+	}()
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	assert.NoError(t, <-errs)
+	_, err := delayqs.DequeueContext(context.Background(), q)
+	assert.ErrorIs(t, err, delayqs.ErrClosed)
+}
+
+func TestAll(t *testing.T) {
+	q, _ := newQueue()
+	delayqs.EnqueueAfter(q, "a", time.Second)
+	delayqs.EnqueueAfter(q, "b", time.Minute)
+	got := map[string]time.Time{}
+	for item, at := range delayqs.All(q) {
+		got[item] = at
+	}
+	assert.Equal(t, map[string]time.Time{
+		"a": epoch.Add(time.Second),
+		"b": epoch.Add(time.Minute),
+	}, got)
+}
+
+func TestSystemClock(t *testing.T) {
+	q := delayqs.New[int]()
+	start := time.Now()
+	assert.NoError(t, delayqs.EnqueueAfter(q, 1, 20*time.Millisecond))
+	item, err := delayqs.DequeueContext(context.Background(), q)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, item)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func ExampleDequeueContext() {
+	q := delayqs.New[string]()
+	delayqs.EnqueueAfter(q, "retry #2", 20*time.Millisecond)
+	delayqs.EnqueueAfter(q, "retry #1", 10*time.Millisecond)
+
+	for range 2 {
+		item, _ := delayqs.DequeueContext(context.Background(), q)
+		fmt.Println(item)
+	}
+	// Output:
+	// retry #1
+	// retry #2
+}
+
+func ExampleDequeue() {
+	q := delayqs.New[string]()
+	delayqs.EnqueueAfter(q, "reminder", time.Hour)
+
+	_, ok := delayqs.Dequeue(q)
+	fmt.Println(ok)
+	// Output:
+	// false
+}
@@ -0,0 +1,222 @@
+package dmpqs
+
+import (
+	"cmp"
+	"iter"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+// Elem represents an element in the priority queue with an item, priority, and sequence number.
+type Elem[T any, P any] struct {
+	item T
+	prio P
+	seq  int
+}
+
+// Item returns the item stored in the element.
+func (e Elem[T, P]) Item() T {
+	return e.item
+}
+
+// Priority returns the priority of the element.
+func (e Elem[T, P]) Priority() P {
+	return e.prio
+}
+
+// Sequence returns the sequence number of the element.
+func (e Elem[T, P]) Sequence() int {
+	return e.seq
+}
+
+func counter() func() int {
+	return counterFrom(0)
+}
+
+// counterFrom returns a counter whose first value is i+1.
+func counterFrom(i int) func() int {
+	return func() int {
+		i++
+		return i
+	}
+}
+
+// PriorityQueue represents a double-ended priority queue with elements of type T and priority of type P.
+// Both the lowest and the highest element can be inspected in O(1) and removed in O(log n).
+type PriorityQueue[T any, P any] struct {
+	heap    heaps.DoubleEnded[Elem[T, P]]
+	counter func() int
+	hooks   []hooks.Hook[T, P]
+
+	nanPolicy NaNPolicy
+	nanOrder  nan.Order
+	isNaN     func(P) bool
+}
+
+// Ascending compares two elements and returns true if x has a lower priority value than y.
+func Ascending[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
+	return x.prio < y.prio
+}
+
+// StableAscending compares two elements and returns true if x has a lower priority value than y,
+// or if priorities are equal, if x was inserted earlier (lower sequence number).
+// Among equal priorities, the min end yields the earliest and the max end the latest inserted element.
+func StableAscending[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
+	if x.prio == y.prio {
+		return x.seq < y.seq
+	}
+	return x.prio < y.prio
+}
+
+// AscendingFunc returns a comparator that orders elements by ascending priority as determined
+// by compare, such as time.Time.Compare, for priority types that are not cmp.Ordered.
+func AscendingFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) < 0
+	}
+}
+
+// StableAscendingFunc is like AscendingFunc, but elements with equal priorities are ordered by insertion.
+func StableAscendingFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c < 0
+		}
+		return x.seq < y.seq
+	}
+}
+
+// New creates a new PriorityQueue ordered by the provided less function.
+// The lessFunc defines the order from the min end to the max end: it should return true if x sorts before y.
+func New[T any, P any](
+	lessFunc func(x, y Elem[T, P]) bool,
+) *PriorityQueue[T, P] {
+	pq := &PriorityQueue[T, P]{
+		counter: counter(),
+		isNaN:   nan.Func[P](),
+	}
+	pq.heap = heaps.MinMax(debug.Less(nanLess(pq, lessFunc)))
+	return pq
+}
+
+// Clear removes all elements from the priority queue and resets its sequence counter.
+func Clear[T any, P any](pq *PriorityQueue[T, P]) {
+	pq.heap.Clear()
+	pq.counter = counter()
+	emit(pq, hooks.Clear, Elem[T, P]{})
+}
+
+// Enqueue inserts a new item with the given priority into the priority queue.
+// It returns ErrNaN if prio is NaN and the NaN policy is RejectNaN.
+func Enqueue[T any, P any](pq *PriorityQueue[T, P], item T, prio P) error {
+	if rejected(pq, prio) {
+		return ErrNaN
+	}
+	elem := Elem[T, P]{
+		item: item,
+		prio: prio,
+		seq:  pq.counter(),
+	}
+	pq.heap.Push(elem)
+	emit(pq, hooks.Enqueue, elem)
+	return nil
+}
+
+// PeekMin returns the item at the min end without removing it.
+// The boolean return value indicates whether an item was returned.
+func PeekMin[T any, P any](pq *PriorityQueue[T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.heap.Peek().Value.item, true
+}
+
+// PeekMax returns the item at the max end without removing it.
+// The boolean return value indicates whether an item was returned.
+func PeekMax[T any, P any](pq *PriorityQueue[T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.heap.PeekMax().Value.item, true
+}
+
+// DequeueMin removes and returns the item at the min end.
+// The boolean return value indicates whether an item was returned.
+func DequeueMin[T any, P any](pq *PriorityQueue[T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.Pop().Value
+	emit(pq, hooks.Dequeue, elem)
+	return elem.item, true
+}
+
+// DequeueMax removes and returns the item at the max end.
+// The boolean return value indicates whether an item was returned.
+func DequeueMax[T any, P any](pq *PriorityQueue[T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.PopMax().Value
+	emit(pq, hooks.Dequeue, elem)
+	return elem.item, true
+}
+
+// Len returns the number of elements currently in the priority queue.
+func Len[T any, P any](pq *PriorityQueue[T, P]) int {
+	return pq.heap.Len()
+}
+
+// Merge moves all elements of src into dst and leaves src empty.
+// Stable comparators put dst's elements first on ties.
+func Merge[T any, P any](dst, src *PriorityQueue[T, P]) {
+	if dst == src {
+		return
+	}
+	// Order src by dst's NaN policy while it is moved, and restore its own policy afterwards.
+	if policy := src.nanPolicy; policy != dst.nanPolicy {
+		SetNaNPolicy(src, dst.nanPolicy)
+		defer SetNaNPolicy(src, policy)
+	}
+	// Renumber src to follow dst, so stable comparators put dst's elements first on ties.
+	base, last := dst.counter(), src.counter()
+	for node := range src.heap.All() {
+		node.Value.seq += base
+	}
+	// Elements moved into dst are reported as enqueued there once the meld is done.
+	var moved []Elem[T, P]
+	if len(dst.hooks) > 0 {
+		for node := range src.heap.All() {
+			moved = append(moved, node.Value)
+		}
+	}
+	// The combined min-max heap is rebuilt in linear time.
+	dst.heap.Meld(src.heap)
+	dst.counter = counterFrom(base + last)
+	src.counter = counter()
+	emit(src, hooks.Clear, Elem[T, P]{})
+	for _, elem := range moved {
+		emit(dst, hooks.Enqueue, elem)
+	}
+	check(dst)
+}
+
+// All returns an iterator over the items and priorities currently in the priority queue.
+// Elements are yielded in unspecified order and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func All[T any, P any](pq *PriorityQueue[T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for node := range pq.heap.All() {
+			if !yield(node.Value.item, node.Value.prio) {
+				return
+			}
+		}
+	}
+}
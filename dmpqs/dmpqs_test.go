@@ -0,0 +1,297 @@
+package dmpqs_test
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/dmpqs"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	assert.NotNil(t, pq)
+	assert.Equal(t, 0, dmpqs.Len(pq))
+}
+
+func TestClear(t *testing.T) {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	dmpqs.Enqueue(pq, "a", 1)
+	dmpqs.Clear(pq)
+	assert.Equal(t, 0, dmpqs.Len(pq))
+	_, ok := dmpqs.PeekMin(pq)
+	assert.False(t, ok)
+	_, ok = dmpqs.PeekMax(pq)
+	assert.False(t, ok)
+}
+
+func TestPeekBothEnds(t *testing.T) {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	dmpqs.Enqueue(pq, "b", 2)
+	dmpqs.Enqueue(pq, "a", 1)
+	dmpqs.Enqueue(pq, "c", 3)
+
+	minItem, ok := dmpqs.PeekMin(pq)
+	assert.True(t, ok)
+	assert.Equal(t, "a", minItem)
+	maxItem, ok := dmpqs.PeekMax(pq)
+	assert.True(t, ok)
+	assert.Equal(t, "c", maxItem)
+	assert.Equal(t, 3, dmpqs.Len(pq))
+}
+
+func TestDequeueBothEnds(t *testing.T) {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	for i, item := range []string{"d", "a", "e", "b", "c"} {
+		dmpqs.Enqueue(pq, item, []int{4, 1, 5, 2, 3}[i])
+	}
+
+	var got []string
+	for dmpqs.Len(pq) > 0 {
+		item, _ := dmpqs.DequeueMin(pq)
+		got = append(got, item)
+		if item, ok := dmpqs.DequeueMax(pq); ok {
+			got = append(got, item)
+		}
+	}
+	assert.Equal(t, []string{"a", "e", "b", "d", "c"}, got)
+}
+
+func TestEmptyDequeue(t *testing.T) {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	_, ok := dmpqs.DequeueMin(pq)
+	assert.False(t, ok)
+	_, ok = dmpqs.DequeueMax(pq)
+	assert.False(t, ok)
+}
+
+func TestStableAscendingOrder(t *testing.T) {
+	pq := dmpqs.New(dmpqs.StableAscending[string, int])
+	dmpqs.Enqueue(pq, "a", 1)
+	dmpqs.Enqueue(pq, "b", 1)
+	dmpqs.Enqueue(pq, "c", 1)
+
+	first, _ := dmpqs.DequeueMin(pq)
+	last, _ := dmpqs.DequeueMax(pq)
+	assert.Equal(t, "a", first)
+	assert.Equal(t, "c", last)
+}
+
+func TestFuncOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	pq := dmpqs.New(dmpqs.StableAscendingFunc[string](time.Time.Compare))
+	dmpqs.Enqueue(pq, "b", base.Add(time.Hour))
+	dmpqs.Enqueue(pq, "a", base)
+	dmpqs.Enqueue(pq, "c", base.Add(time.Hour))
+
+	first, _ := dmpqs.DequeueMin(pq)
+	last, _ := dmpqs.DequeueMax(pq)
+	assert.Equal(t, "a", first)
+	assert.Equal(t, "c", last)
+
+	unstable := dmpqs.New(dmpqs.AscendingFunc[string](time.Time.Compare))
+	dmpqs.Enqueue(unstable, "late", base.Add(time.Hour))
+	dmpqs.Enqueue(unstable, "early", base)
+	first, _ = dmpqs.PeekMin(unstable)
+	assert.Equal(t, "early", first)
+}
+
+func TestRandomDequeueBothEnds(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	pq := dmpqs.New(dmpqs.Ascending[int, int])
+	var ref []int
+	for range 2000 {
+		switch r.IntN(3) {
+		case 0:
+			v := r.IntN(100)
+			dmpqs.Enqueue(pq, v, v)
+			ref = append(ref, v)
+			slices.Sort(ref)
+		case 1:
+			item, ok := dmpqs.DequeueMin(pq)
+			assert.Equal(t, len(ref) > 0, ok)
+			if ok {
+				assert.Equal(t, ref[0], item)
+				ref = ref[1:]
+			}
+		case 2:
+			item, ok := dmpqs.DequeueMax(pq)
+			assert.Equal(t, len(ref) > 0, ok)
+			if ok {
+				assert.Equal(t, ref[len(ref)-1], item)
+				ref = ref[:len(ref)-1]
+			}
+		}
+		assert.Equal(t, len(ref), dmpqs.Len(pq))
+	}
+}
+
+func TestAll(t *testing.T) {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	dmpqs.Enqueue(pq, "a", 2)
+	dmpqs.Enqueue(pq, "b", 1)
+	dmpqs.Enqueue(pq, "c", 3)
+
+	got := map[string]int{}
+	for item, prio := range dmpqs.All(pq) {
+		got[item] = prio
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 1, "c": 3}, got)
+	assert.Equal(t, 3, dmpqs.Len(pq))
+}
+
+func TestMerge(t *testing.T) {
+	dst := dmpqs.New(dmpqs.StableAscending[string, int])
+	src := dmpqs.New(dmpqs.StableAscending[string, int])
+	dmpqs.Enqueue(src, "s1", 1)
+	dmpqs.Enqueue(dst, "d1", 1)
+	dmpqs.Enqueue(src, "s2", 1)
+	dmpqs.Enqueue(dst, "d2", 5)
+
+	dmpqs.Merge(dst, src)
+	assert.Equal(t, 0, dmpqs.Len(src))
+	assert.Equal(t, 4, dmpqs.Len(dst))
+
+	var got []string
+	for dmpqs.Len(dst) > 0 {
+		item, _ := dmpqs.DequeueMin(dst)
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"d1", "s1", "s2", "d2"}, got)
+}
+
+func TestHooks(t *testing.T) {
+	pq := dmpqs.New(dmpqs.StableAscending[string, int])
+	var events []hooks.Event[string, int]
+	dmpqs.AddHook(pq, func(e hooks.Event[string, int]) { events = append(events, e) })
+
+	dmpqs.Enqueue(pq, "a", 1)
+	dmpqs.Enqueue(pq, "b", 2)
+	dmpqs.Enqueue(pq, "c", 3)
+	dmpqs.DequeueMax(pq)
+	dmpqs.DequeueMin(pq)
+	dmpqs.Clear(pq)
+
+	assert.Equal(t, []hooks.Event[string, int]{
+		{Op: hooks.Enqueue, Item: "a", Priority: 1, Sequence: 1, Len: 1},
+		{Op: hooks.Enqueue, Item: "b", Priority: 2, Sequence: 2, Len: 2},
+		{Op: hooks.Enqueue, Item: "c", Priority: 3, Sequence: 3, Len: 3},
+		{Op: hooks.Dequeue, Item: "c", Priority: 3, Sequence: 3, Len: 2},
+		{Op: hooks.Dequeue, Item: "a", Priority: 1, Sequence: 1, Len: 1},
+		{Op: hooks.Clear},
+	}, events)
+}
+
+func TestValidate(t *testing.T) {
+	rank := map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}
+	pq := dmpqs.New(func(x, y dmpqs.Elem[string, int]) bool {
+		return rank[x.Item()] < rank[y.Item()]
+	})
+	for _, item := range []string{"a", "b", "c", "d"} {
+		dmpqs.Enqueue(pq, item, 0)
+	}
+	assert.Empty(t, dmpqs.Validate(pq))
+
+	corrupt := func() {
+		rank["a"] = 5
+		dmpqs.Enqueue(pq, "e", 0)
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	assert.NotEmpty(t, dmpqs.Validate(pq))
+}
+
+func TestFormat(t *testing.T) {
+	pq := dmpqs.New(dmpqs.StableAscending[string, int])
+	for i, s := range []string{"a", "b", "c", "d"} {
+		dmpqs.Enqueue(pq, s, i)
+	}
+	assert.Equal(t, "a (priority 0, seq 1)\n├── d (priority 3, seq 4)\n│   └── b (priority 1, seq 2)\n└── c (priority 2, seq 3)", pq.String())
+
+	var sb strings.Builder
+	assert.NoError(t, pq.WriteDOT(&sb))
+	assert.Contains(t, sb.String(), `digraph "dmpqs" {`)
+	assert.Equal(t, 3, strings.Count(sb.String(), "->"))
+}
+
+func TestNaNPolicy(t *testing.T) {
+	nan := math.NaN()
+	pq := dmpqs.New(dmpqs.StableAscending[string, float64])
+	dmpqs.SetNaNPolicy(pq, dmpqs.NaNFirst)
+	for i, prio := range []float64{2, nan, 1, nan, 3} {
+		assert.NoError(t, dmpqs.Enqueue(pq, fmt.Sprint(i), prio))
+	}
+	assert.Empty(t, dmpqs.Validate(pq))
+	item, _ := dmpqs.DequeueMin(pq)
+	assert.Equal(t, "1", item)
+	item, _ = dmpqs.PeekMax(pq)
+	assert.Equal(t, "4", item)
+
+	dmpqs.SetNaNPolicy(pq, dmpqs.NaNLast)
+	item, _ = dmpqs.PeekMax(pq)
+	assert.Equal(t, "3", item)
+	item, _ = dmpqs.PeekMin(pq)
+	assert.Equal(t, "2", item)
+
+	dmpqs.SetNaNPolicy(pq, dmpqs.RejectNaN)
+	assert.Equal(t, 3, dmpqs.Len(pq))
+	assert.ErrorIs(t, dmpqs.Enqueue(pq, "nan", nan), dmpqs.ErrNaN)
+	assert.Equal(t, 3, dmpqs.Len(pq))
+}
+
+func ExampleNew() {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	dmpqs.Enqueue(pq, "low", 1)
+	dmpqs.Enqueue(pq, "high", 9)
+	minItem, _ := dmpqs.PeekMin(pq)
+	maxItem, _ := dmpqs.PeekMax(pq)
+	fmt.Println(minItem, maxItem)
+	// Output: low high
+}
+
+func ExampleDequeueMin() {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	dmpqs.Enqueue(pq, "b", 2)
+	dmpqs.Enqueue(pq, "a", 1)
+	item, _ := dmpqs.DequeueMin(pq)
+	fmt.Println(item)
+	// Output: a
+}
+
+func ExampleDequeueMax() {
+	pq := dmpqs.New(dmpqs.Ascending[string, int])
+	dmpqs.Enqueue(pq, "b", 2)
+	dmpqs.Enqueue(pq, "a", 1)
+	item, _ := dmpqs.DequeueMax(pq)
+	fmt.Println(item)
+	// Output: b
+}
+
+func Example_boundedBuffer() {
+	// Keep the three highest bids, evicting the lowest whenever the buffer overflows.
+	pq := dmpqs.New(dmpqs.StableAscending[string, int])
+	for i, prio := range []int{5, 1, 8, 3, 9} {
+		dmpqs.Enqueue(pq, fmt.Sprintf("bid%d", i), prio)
+		if dmpqs.Len(pq) > 3 {
+			dmpqs.DequeueMin(pq)
+		}
+	}
+	for dmpqs.Len(pq) > 0 {
+		item, _ := dmpqs.DequeueMax(pq)
+		fmt.Println(item)
+	}
+	// Output:
+	// bid4
+	// bid2
+	// bid0
+}
@@ -0,0 +1,28 @@
+package dmpqs
+
+import "github.com/byExist/priorityqueues/hooks"
+
+// AddHook registers a function called after every enqueue, dequeue and clear on the priority queue.
+// DequeueMin and DequeueMax are both reported as dequeues. Merge reports a clear on src and an
+// enqueue on dst for every element it moves.
+// Hooks must not modify the queue.
+func AddHook[T any, P any](pq *PriorityQueue[T, P], hook hooks.Hook[T, P]) {
+	pq.hooks = append(pq.hooks, hook)
+}
+
+func emit[T any, P any](pq *PriorityQueue[T, P], op hooks.Op, elem Elem[T, P]) {
+	check(pq)
+	if len(pq.hooks) == 0 {
+		return
+	}
+	e := hooks.Event[T, P]{
+		Op:       op,
+		Item:     elem.item,
+		Priority: elem.prio,
+		Sequence: elem.seq,
+		Len:      pq.heap.Len(),
+	}
+	for _, hook := range pq.hooks {
+		hook(e)
+	}
+}
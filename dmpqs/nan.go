@@ -0,0 +1,74 @@
+package dmpqs
+
+import (
+	"errors"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+// ErrNaN is returned by Enqueue when the priority is NaN and the NaN policy is RejectNaN.
+var ErrNaN = errors.New("dmpqs: NaN priority")
+
+// NaNPolicy determines how a queue with floating-point priorities handles NaN, which compares
+// false to every priority and so breaks the heap order under Ascending and StableAscending.
+type NaNPolicy int
+
+const (
+	// AllowNaN leaves NaN priorities to the comparator. This is the default.
+	AllowNaN NaNPolicy = iota
+	// RejectNaN leaves the queue unchanged and makes Enqueue return ErrNaN.
+	RejectNaN
+	// NaNFirst places elements with NaN priorities at the min end, in insertion order.
+	NaNFirst
+	// NaNLast places elements with NaN priorities at the max end, in insertion order.
+	NaNLast
+)
+
+// SetNaNPolicy sets how the priority queue handles NaN priorities. It has no effect unless P is a
+// floating-point type. Queued elements are reordered under the new policy in linear time, and under
+// RejectNaN the ones with NaN priorities are removed.
+func SetNaNPolicy[T any, P any](pq *PriorityQueue[T, P], policy NaNPolicy) {
+	pq.nanPolicy = policy
+	if pq.isNaN == nil {
+		return
+	}
+	if policy == RejectNaN {
+		var nodes []*heaps.Node[Elem[T, P]]
+		for node := range pq.heap.All() {
+			if pq.isNaN(node.Value.prio) {
+				nodes = append(nodes, node)
+			}
+		}
+		for _, node := range nodes {
+			pq.heap.Remove(node)
+			emit(pq, hooks.Delete, node.Value)
+		}
+	}
+	switch policy {
+	case NaNFirst:
+		pq.nanOrder = nan.First
+	case NaNLast:
+		pq.nanOrder = nan.Last
+	default:
+		pq.nanOrder = nan.Unordered
+	}
+	heaps.Rebuild(pq.heap)
+	check(pq)
+}
+
+// nanLess returns lessFunc with NaN priorities placed by the NaN policy of pq.
+func nanLess[T any, P any](pq *PriorityQueue[T, P], lessFunc func(x, y Elem[T, P]) bool) func(x, y Elem[T, P]) bool {
+	if pq.isNaN == nil {
+		return lessFunc
+	}
+	isNaN := func(e Elem[T, P]) bool { return pq.isNaN(e.prio) }
+	bySeq := func(x, y Elem[T, P]) bool { return x.seq < y.seq }
+	return nan.Less(lessFunc, isNaN, bySeq, &pq.nanOrder)
+}
+
+// rejected reports whether prio is NaN and pq rejects NaN priorities.
+func rejected[T any, P any](pq *PriorityQueue[T, P], prio P) bool {
+	return pq.nanPolicy == RejectNaN && pq.isNaN != nil && pq.isNaN(prio)
+}
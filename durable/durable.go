@@ -0,0 +1,435 @@
+// Package durable provides a keyed priority queue that survives restarts.
+//
+// A Queue wraps a kmpqs.PriorityQueue. Every Enqueue, Update, Delete and Dequeue is
+// appended to a write-ahead log in the queue's directory before it is applied, and
+// Open rebuilds the queue by loading the latest snapshot and replaying the log on top
+// of it. Compaction writes a new snapshot and starts an empty log.
+//
+// A record that was only partly written when the process crashed is detected by its
+// checksum and dropped on Open, together with anything after it.
+package durable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/byExist/priorityqueues/kmpqs"
+	"github.com/byExist/priorityqueues/snapshot"
+)
+
+var (
+	// ErrClosed is returned by operations on a closed Queue.
+	ErrClosed = errors.New("durable: queue closed")
+	// ErrCorrupt is returned by Open when the snapshot or an intact log record cannot be decoded.
+	ErrCorrupt = errors.New("durable: corrupt data")
+)
+
+// SyncPolicy determines when the log is flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the log after every record. This is the default.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the log on the first write after the sync interval has
+	// passed since the last flush. Records written since then may be lost on a crash.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system and to Sync and Close.
+	SyncNever
+)
+
+const (
+	snapshotName           = "snapshot"
+	logSuffix              = ".wal"
+	defaultSyncInterval    = time.Second
+	defaultCompactionLimit = 10000
+)
+
+// Queue is a kmpqs.PriorityQueue persisted to a write-ahead log.
+// A Queue is not safe for concurrent use; callers must synchronize access.
+type Queue[K comparable, T any, P any] struct {
+	pq    *kmpqs.PriorityQueue[K, T, P]
+	codec snapshot.Codec[T]
+	dir   string
+
+	gen     uint64
+	log     *os.File
+	records int
+	payload []byte
+	frame   []byte
+	err     error
+
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+	lastSync     time.Time
+	compactAfter int
+}
+
+// Open opens the queue persisted in dir, creating the directory if needed, and loads its
+// contents into pq, replacing whatever pq held. The pq supplies the comparator, key function,
+// backend and policies, which must match the ones used when the log was written for the
+// replay to reproduce the same queue. A comparator that snapshot.Identity cannot identify,
+// such as one returned by kmpqs.MinFirstFunc, must be named with kmpqs.SetComparatorID for
+// the queue to be compacted. Items are encoded with codec.
+func Open[K comparable, T any, P any](
+	dir string,
+	pq *kmpqs.PriorityQueue[K, T, P],
+	codec snapshot.Codec[T],
+) (*Queue[K, T, P], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	kmpqs.SetCodec(pq, codec)
+	q := &Queue[K, T, P]{
+		pq:           pq,
+		codec:        codec,
+		dir:          dir,
+		syncInterval: defaultSyncInterval,
+		compactAfter: defaultCompactionLimit,
+		lastSync:     time.Now(),
+	}
+	if err := loadSnapshot(q); err != nil {
+		return nil, err
+	}
+	path := logPath(dir, q.gen)
+	payloads, size, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, payload := range payloads {
+		if err := apply(q, payload); err != nil {
+			return nil, err
+		}
+	}
+	if err := removeStaleLogs(dir, q.gen); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop a torn record left by a crash so new records follow the intact ones.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(size, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	q.log = f
+	q.records = len(payloads)
+	return q, nil
+}
+
+// SetSyncPolicy sets when the queue flushes its log to stable storage.
+func SetSyncPolicy[K comparable, T any, P any](q *Queue[K, T, P], policy SyncPolicy) {
+	q.syncPolicy = policy
+}
+
+// SetSyncInterval sets the interval used by the SyncInterval policy. The default is one second.
+func SetSyncInterval[K comparable, T any, P any](q *Queue[K, T, P], interval time.Duration) {
+	q.syncInterval = interval
+}
+
+// SetCompactionThreshold makes the queue compact itself once its log holds n records.
+// Zero or a negative n disables automatic compaction. The default is 10000 records.
+func SetCompactionThreshold[K comparable, T any, P any](q *Queue[K, T, P], n int) {
+	q.compactAfter = n
+}
+
+// Enqueue logs and inserts a new item with the given priority, as kmpqs.Enqueue does.
+func Enqueue[K comparable, T any, P any](q *Queue[K, T, P], item T, prio P) error {
+	if err := write(q, opEnqueue, item, &prio); err != nil {
+		return err
+	}
+	if err := kmpqs.Enqueue(q.pq, item, prio); err != nil {
+		return err
+	}
+	return maybeCompact(q)
+}
+
+// Update logs and applies a priority change of an existing item, as kmpqs.Update does.
+// Returns true if the item exists and was successfully updated.
+func Update[K comparable, T any, P any](q *Queue[K, T, P], item T, newPrio P) (bool, error) {
+	if !kmpqs.Contains(q.pq, item) {
+		return false, q.err
+	}
+	if err := write(q, opUpdate, item, &newPrio); err != nil {
+		return false, err
+	}
+	// Updates rejected by the NaN policy are logged too, and rejected again on replay.
+	ok := kmpqs.Update(q.pq, item, newPrio)
+	return ok, maybeCompact(q)
+}
+
+// Delete logs and removes an item identified by its key, as kmpqs.Delete does.
+// Returns true if the item existed and was successfully removed.
+func Delete[K comparable, T any, P any](q *Queue[K, T, P], item T) (bool, error) {
+	if !kmpqs.Contains(q.pq, item) {
+		return false, q.err
+	}
+	if err := write(q, opDelete, item, nil); err != nil {
+		return false, err
+	}
+	kmpqs.Delete(q.pq, item)
+	return true, maybeCompact(q)
+}
+
+// Dequeue logs and removes the highest priority item, as kmpqs.Dequeue does.
+// The boolean return indicates whether an item was returned.
+// The log records the dequeued item, so the replay removes it by key even when expiry or
+// aging order the replayed queue differently.
+func Dequeue[K comparable, T any, P any](q *Queue[K, T, P]) (T, bool, error) {
+	item, ok := kmpqs.Peek(q.pq)
+	if !ok {
+		return item, false, q.err
+	}
+	if err := write(q, opDequeue, item, nil); err != nil {
+		var zero T
+		return zero, false, err
+	}
+	// Peek has already aged the queue and dropped expired elements, so this removes the logged item.
+	item, _ = kmpqs.Dequeue(q.pq)
+	return item, true, maybeCompact(q)
+}
+
+// Peek returns the highest priority item without removing it.
+func Peek[K comparable, T any, P any](q *Queue[K, T, P]) (T, bool) {
+	return kmpqs.Peek(q.pq)
+}
+
+// Len returns the number of items in the queue.
+func Len[K comparable, T any, P any](q *Queue[K, T, P]) int {
+	return kmpqs.Len(q.pq)
+}
+
+// ContainsKey returns true if the queue contains an item with the given key.
+func ContainsKey[K comparable, T any, P any](q *Queue[K, T, P], key K) bool {
+	return kmpqs.ContainsKey(q.pq, key)
+}
+
+// GetByKey returns the stored item identified by key without removing it.
+func GetByKey[K comparable, T any, P any](q *Queue[K, T, P], key K) (T, bool) {
+	return kmpqs.GetByKey(q.pq, key)
+}
+
+// PriorityOf returns the stored priority of the item identified by key.
+func PriorityOf[K comparable, T any, P any](q *Queue[K, T, P], key K) (P, bool) {
+	return kmpqs.PriorityOf(q.pq, key)
+}
+
+// All returns an iterator over the items and priorities in the queue in unspecified order.
+// The queue must not be modified during iteration.
+func All[K comparable, T any, P any](q *Queue[K, T, P]) iter.Seq2[T, P] {
+	return kmpqs.All(q.pq)
+}
+
+// Sync flushes the log to stable storage.
+func Sync[K comparable, T any, P any](q *Queue[K, T, P]) error {
+	if q.log == nil {
+		return ErrClosed
+	}
+	if err := q.log.Sync(); err != nil {
+		return err
+	}
+	q.lastSync = time.Now()
+	return nil
+}
+
+// Compact writes the current contents of the queue to a new snapshot and starts an empty log.
+// The snapshot replaces the previous one atomically, so a crash during compaction leaves
+// either the old snapshot and log or the new ones.
+func Compact[K comparable, T any, P any](q *Queue[K, T, P]) error {
+	if q.log == nil {
+		return ErrClosed
+	}
+	if q.err != nil {
+		return q.err
+	}
+	data, err := q.pq.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	next := q.gen + 1
+	f, err := os.OpenFile(logPath(q.dir, next), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(q.dir, next, data); err != nil {
+		f.Close()
+		os.Remove(logPath(q.dir, next))
+		return err
+	}
+	old, oldGen := q.log, q.gen
+	q.log, q.gen, q.records = f, next, 0
+	old.Close()
+	return os.Remove(logPath(q.dir, oldGen))
+}
+
+// Close flushes and closes the log. The wrapped kmpqs.PriorityQueue keeps its contents.
+func Close[K comparable, T any, P any](q *Queue[K, T, P]) error {
+	if q.log == nil {
+		return ErrClosed
+	}
+	err := q.log.Sync()
+	if cerr := q.log.Close(); err == nil {
+		err = cerr
+	}
+	q.log = nil
+	return err
+}
+
+// write appends a record for op to the log and flushes it according to the sync policy.
+// A failed write leaves the log in an unknown state, so it makes every later write fail too.
+func write[K comparable, T any, P any](q *Queue[K, T, P], op byte, item T, prio *P) error {
+	if q.log == nil {
+		return ErrClosed
+	}
+	if q.err != nil {
+		return q.err
+	}
+	payload := append(q.payload[:0], op)
+	var err error
+	if prio != nil {
+		if payload, err = snapshot.AppendValue(payload, *prio); err != nil {
+			return err
+		}
+	}
+	if payload, err = q.codec.Append(payload, item); err != nil {
+		return err
+	}
+	q.payload = payload
+	q.frame = appendRecord(q.frame[:0], payload)
+	if _, err := q.log.Write(q.frame); err != nil {
+		q.err = fmt.Errorf("durable: log write failed: %w", err)
+		return q.err
+	}
+	q.records++
+	switch {
+	case q.syncPolicy == SyncAlways,
+		q.syncPolicy == SyncInterval && time.Since(q.lastSync) >= q.syncInterval:
+		if err := Sync(q); err != nil {
+			q.err = fmt.Errorf("durable: log sync failed: %w", err)
+			return q.err
+		}
+	}
+	return nil
+}
+
+func maybeCompact[K comparable, T any, P any](q *Queue[K, T, P]) error {
+	if q.compactAfter > 0 && q.records >= q.compactAfter {
+		return Compact(q)
+	}
+	return nil
+}
+
+// apply replays a logged operation on the wrapped queue.
+func apply[K comparable, T any, P any](q *Queue[K, T, P], payload []byte) error {
+	op, data := payload[0], payload[1:]
+	var prio P
+	if op == opEnqueue || op == opUpdate {
+		var err error
+		if prio, data, err = snapshot.ReadValue[P](data); err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+	}
+	item, err := q.codec.Decode(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	switch op {
+	case opEnqueue:
+		// Errors are part of the logged history: the original call failed the same way.
+		_ = kmpqs.Enqueue(q.pq, item, prio)
+	case opUpdate:
+		kmpqs.Update(q.pq, item, prio)
+	case opDelete, opDequeue:
+		kmpqs.Delete(q.pq, item)
+	default:
+		return fmt.Errorf("%w: unknown op %d", ErrCorrupt, op)
+	}
+	return nil
+}
+
+func logPath(dir string, gen uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016x%s", gen, logSuffix))
+}
+
+// loadSnapshot restores the wrapped queue and the log generation from the snapshot in q.dir.
+// Without a snapshot the queue is cleared and the first generation is used.
+func loadSnapshot[K comparable, T any, P any](q *Queue[K, T, P]) error {
+	data, err := os.ReadFile(filepath.Join(q.dir, snapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		kmpqs.Clear(q.pq)
+		q.gen = 1
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) < 12 || crc32.Checksum(data[12:], crcTable) != binary.LittleEndian.Uint32(data[8:]) {
+		return fmt.Errorf("%w: snapshot checksum mismatch", ErrCorrupt)
+	}
+	if err := q.pq.UnmarshalBinary(data[12:]); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	q.gen = binary.LittleEndian.Uint64(data)
+	return nil
+}
+
+// writeSnapshot atomically replaces the snapshot in dir with data covering log generation gen.
+// The file holds the generation, a CRC-32C of data and data itself.
+func writeSnapshot(dir string, gen uint64, data []byte) error {
+	tmp := filepath.Join(dir, snapshotName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	buf := binary.LittleEndian.AppendUint64(nil, gen)
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(data, crcTable))
+	buf = append(buf, data...)
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, snapshotName)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// removeStaleLogs removes the logs of every generation but gen, left behind by an
+// interrupted compaction.
+func removeStaleLogs(dir string, gen uint64) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	keep := filepath.Base(logPath(dir, gen))
+	for _, e := range entries {
+		if name := e.Name(); strings.HasSuffix(name, logSuffix) && name != keep {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package durable_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/durable"
+	"github.com/byExist/priorityqueues/kmpqs"
+	"github.com/byExist/priorityqueues/snapshot"
+	"github.com/stretchr/testify/assert"
+)
+
+type Job struct {
+	ID   string
+	Name string
+}
+
+func newQueue() *kmpqs.PriorityQueue[string, Job, int] {
+	return kmpqs.New(kmpqs.StableMinFirst[Job, int], func(j Job) string { return j.ID })
+}
+
+func open(t *testing.T, dir string) *durable.Queue[string, Job, int] {
+	t.Helper()
+	q, err := durable.Open(dir, newQueue(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	return q
+}
+
+func contents(q *durable.Queue[string, Job, int]) map[string]int {
+	m := map[string]int{}
+	for j, p := range durable.All(q) {
+		m[j.ID] = p
+	}
+	return m
+}
+
+func logFile(t *testing.T, dir string) string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	return matches[0]
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 3))
+	assert.NoError(t, durable.Enqueue(q, Job{"b", "test"}, 1))
+	assert.NoError(t, durable.Enqueue(q, Job{"c", "lint"}, 2))
+	assert.NoError(t, durable.Enqueue(q, Job{"d", "deploy"}, 2))
+	ok, err := durable.Update(q, Job{"a", "build"}, 0)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ok, err = durable.Delete(q, Job{ID: "c"})
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	j, ok, err := durable.Dequeue(q)
+	assert.Equal(t, Job{"a", "build"}, j)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Close(q))
+
+	q = open(t, dir)
+	assert.Equal(t, 2, durable.Len(q))
+	assert.True(t, durable.ContainsKey(q, "d"))
+	p, ok := durable.PriorityOf(q, "d")
+	assert.True(t, ok)
+	assert.Equal(t, 2, p)
+	got, ok := durable.GetByKey(q, "b")
+	assert.True(t, ok)
+	assert.Equal(t, "test", got.Name)
+	top, _ := durable.Peek(q)
+	assert.Equal(t, "b", top.ID)
+	assert.NoError(t, durable.Close(q))
+}
+
+func TestReopenTimePriorities(t *testing.T) {
+	dir := t.TempDir()
+	newDeadlines := func() *kmpqs.PriorityQueue[string, Job, time.Time] {
+		pq := kmpqs.New(kmpqs.StableMinFirstFunc[Job](time.Time.Compare), func(j Job) string { return j.ID })
+		kmpqs.SetComparatorID(pq, "deadline")
+		return pq
+	}
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	q, err := durable.Open(dir, newDeadlines(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, base.Add(time.Hour)))
+	assert.NoError(t, durable.Compact(q))
+	assert.NoError(t, durable.Enqueue(q, Job{"b", "test"}, base.Add(2*time.Hour)))
+	_, err = durable.Update(q, Job{"b", "test"}, base)
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Close(q))
+
+	q, err = durable.Open(dir, newDeadlines(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	top, _ := durable.Peek(q)
+	assert.Equal(t, "b", top.ID)
+	p, _ := durable.PriorityOf(q, "a")
+	assert.True(t, base.Add(time.Hour).Equal(p))
+	assert.NoError(t, durable.Close(q))
+}
+
+func TestRejectedNaNReplays(t *testing.T) {
+	dir := t.TempDir()
+	newScores := func() *kmpqs.PriorityQueue[string, Job, float64] {
+		pq := kmpqs.New(kmpqs.StableMinFirst[Job, float64], func(j Job) string { return j.ID })
+		kmpqs.SetNaNPolicy(pq, kmpqs.RejectNaN)
+		return pq
+	}
+	q, err := durable.Open(dir, newScores(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 1))
+	assert.ErrorIs(t, durable.Enqueue(q, Job{"b", "test"}, math.NaN()), kmpqs.ErrNaN)
+	ok, err := durable.Update(q, Job{"a", "build"}, math.NaN())
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Close(q))
+
+	q, err = durable.Open(dir, newScores(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, durable.Len(q))
+	p, _ := durable.PriorityOf(q, "a")
+	assert.Equal(t, 1.0, p)
+	assert.NoError(t, durable.Close(q))
+}
+
+func TestReplayAfterAging(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	newAged := func() *kmpqs.PriorityQueue[string, Job, int] {
+		pq := newQueue()
+		kmpqs.SetClock(pq, func() time.Time { return now })
+		kmpqs.SetAging(pq, kmpqs.LinearAging(-1, time.Minute), time.Minute)
+		return pq
+	}
+	q, err := durable.Open(dir, newAged(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 5))
+	now = now.Add(10 * time.Minute)
+	assert.NoError(t, durable.Enqueue(q, Job{"b", "test"}, 3))
+	// a has aged past b, so it is dequeued first.
+	j, _, err := durable.Dequeue(q)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", j.ID)
+	assert.NoError(t, durable.Close(q))
+
+	// The replay enqueues both at the same time, where b would come first.
+	q, err = durable.Open(dir, newAged(), snapshot.JSONCodec[Job]())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"b": 3}, contents(q))
+	assert.NoError(t, durable.Close(q))
+}
+
+func TestMissesAreNotLogged(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	ok, err := durable.Update(q, Job{ID: "x"}, 1)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	ok, err = durable.Delete(q, Job{ID: "x"})
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	_, ok, err = durable.Dequeue(q)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Close(q))
+
+	info, err := os.Stat(logFile(t, dir))
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 3))
+	assert.NoError(t, durable.Enqueue(q, Job{"b", "test"}, 1))
+	before := contents(q)
+	path := logFile(t, dir)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Enqueue(q, Job{"c", "lint"}, 2))
+	assert.NoError(t, durable.Close(q))
+
+	full, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	// Cut the last record at every offset, as a crash in the middle of the write would.
+	for cut := info.Size(); cut < int64(len(full)); cut++ {
+		assert.NoError(t, os.WriteFile(path, full[:cut], 0o644))
+		q := open(t, dir)
+		assert.Equal(t, before, contents(q), "cut at %d", cut)
+
+		// New records follow the intact prefix and survive the next reopen.
+		assert.NoError(t, durable.Enqueue(q, Job{"d", "deploy"}, 4))
+		assert.NoError(t, durable.Close(q))
+		q = open(t, dir)
+		assert.Equal(t, map[string]int{"a": 3, "b": 1, "d": 4}, contents(q), "cut at %d", cut)
+		assert.NoError(t, durable.Close(q))
+	}
+}
+
+func TestCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 3))
+	path := logFile(t, dir)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, durable.Enqueue(q, Job{"b", "test"}, 1))
+	assert.NoError(t, durable.Enqueue(q, Job{"c", "lint"}, 2))
+	assert.NoError(t, durable.Close(q))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[info.Size()+10] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	// Everything from the damaged record on is dropped.
+	q = open(t, dir)
+	assert.Equal(t, map[string]int{"a": 3}, contents(q))
+	assert.NoError(t, durable.Close(q))
+	info, err = os.Stat(path)
+	assert.NoError(t, err)
+	assert.Less(t, info.Size(), int64(len(data)))
+}
+
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	durable.SetCompactionThreshold(q, 8)
+	for i := range 50 {
+		assert.NoError(t, durable.Enqueue(q, Job{ID: fmt.Sprint(i % 10)}, i))
+		if i%3 == 0 {
+			_, _, err := durable.Dequeue(q)
+			assert.NoError(t, err)
+		}
+	}
+	want := contents(q)
+	assert.NoError(t, durable.Close(q))
+
+	info, err := os.Stat(logFile(t, dir))
+	assert.NoError(t, err)
+	assert.Less(t, info.Size(), int64(8*64))
+
+	q = open(t, dir)
+	assert.Equal(t, want, contents(q))
+	var order []string
+	for durable.Len(q) > 0 {
+		j, _, err := durable.Dequeue(q)
+		assert.NoError(t, err)
+		order = append(order, j.ID)
+	}
+	assert.Len(t, order, len(want))
+
+	assert.NoError(t, durable.Compact(q))
+	assert.NoError(t, durable.Close(q))
+	q = open(t, dir)
+	assert.Zero(t, durable.Len(q))
+	assert.NoError(t, durable.Close(q))
+}
+
+func TestInterruptedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 3))
+	assert.NoError(t, durable.Compact(q))
+	assert.NoError(t, durable.Enqueue(q, Job{"b", "test"}, 1))
+	assert.NoError(t, durable.Close(q))
+	current := logFile(t, dir)
+
+	// A crash before the new snapshot is renamed into place leaves a temporary
+	// snapshot and the next generation's log behind.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot.tmp"), []byte("partial"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ffffffffffffffff.wal"), nil, 0o644))
+
+	q = open(t, dir)
+	assert.Equal(t, map[string]int{"a": 3, "b": 1}, contents(q))
+	assert.Equal(t, current, logFile(t, dir))
+	assert.NoError(t, durable.Close(q))
+}
+
+func TestCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir)
+	assert.NoError(t, durable.Enqueue(q, Job{"a", "build"}, 3))
+	assert.NoError(t, durable.Compact(q))
+	assert.NoError(t, durable.Close(q))
+
+	path := filepath.Join(dir, "snapshot")
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = durable.Open(dir, newQueue(), snapshot.JSONCodec[Job]())
+	assert.ErrorIs(t, err, durable.ErrCorrupt)
+}
+
+func TestSyncPolicies(t *testing.T) {
+	for _, policy := range []durable.SyncPolicy{durable.SyncAlways, durable.SyncInterval, durable.SyncNever} {
+		dir := t.TempDir()
+		q := open(t, dir)
+		durable.SetSyncPolicy(q, policy)
+		durable.SetSyncInterval(q, time.Millisecond)
+		for i := range 5 {
+			assert.NoError(t, durable.Enqueue(q, Job{ID: fmt.Sprint(i)}, i))
+			time.Sleep(time.Millisecond)
+		}
+		assert.NoError(t, durable.Sync(q))
+		assert.NoError(t, durable.Close(q))
+
+		q = open(t, dir)
+		assert.Equal(t, 5, durable.Len(q))
+		assert.NoError(t, durable.Close(q))
+	}
+}
+
+func TestClosed(t *testing.T) {
+	q := open(t, t.TempDir())
+	assert.NoError(t, durable.Enqueue(q, Job{ID: "a"}, 1))
+	assert.NoError(t, durable.Close(q))
+	assert.ErrorIs(t, durable.Close(q), durable.ErrClosed)
+	assert.ErrorIs(t, durable.Enqueue(q, Job{ID: "b"}, 1), durable.ErrClosed)
+	_, _, err := durable.Dequeue(q)
+	assert.ErrorIs(t, err, durable.ErrClosed)
+	assert.ErrorIs(t, durable.Sync(q), durable.ErrClosed)
+	assert.ErrorIs(t, durable.Compact(q), durable.ErrClosed)
+	assert.Equal(t, 1, durable.Len(q))
+}
+
+func ExampleOpen() {
+	dir, _ := os.MkdirTemp("", "jobs")
+	defer os.RemoveAll(dir)
+
+	newQueue := func() *kmpqs.PriorityQueue[string, Job, int] {
+		return kmpqs.New(kmpqs.StableMinFirst[Job, int], func(j Job) string { return j.ID })
+	}
+
+	q, _ := durable.Open(dir, newQueue(), snapshot.JSONCodec[Job]())
+	durable.Enqueue(q, Job{ID: "1", Name: "build"}, 2)
+	durable.Enqueue(q, Job{ID: "2", Name: "test"}, 1)
+	durable.Close(q)
+
+	// After a restart the queue is rebuilt from the log.
+	q, _ = durable.Open(dir, newQueue(), snapshot.JSONCodec[Job]())
+	for durable.Len(q) > 0 {
+		j, _, _ := durable.Dequeue(q)
+		fmt.Println(j.Name)
+	}
+	durable.Close(q)
+	// Output:
+	// test
+	// build
+}
@@ -0,0 +1,92 @@
+package durable
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Every log record is framed as
+//
+//	length   4 bytes, little endian, length of payload
+//	checksum 4 bytes, little endian, CRC-32C of payload
+//	payload  op byte followed by the op's fields: the priority for enqueues and
+//	         updates, and the encoded item for every op
+//
+// A record that is cut short or fails its checksum marks the end of the log.
+const headerSize = 8
+
+const (
+	opEnqueue byte = iota + 1
+	opUpdate
+	opDelete
+	opDequeue
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errTorn = errors.New("durable: torn record")
+
+// appendRecord appends a framed record holding payload to buf.
+func appendRecord(buf, payload []byte) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	return append(buf, payload...)
+}
+
+// readRecord returns the payload of the record at the start of data and its framed size.
+// It returns errTorn if data ends inside the record or the checksum does not match.
+func readRecord(data []byte) ([]byte, int, error) {
+	if len(data) < headerSize {
+		return nil, 0, errTorn
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if n == 0 || n > len(data)-headerSize {
+		return nil, 0, errTorn
+	}
+	payload := data[headerSize : headerSize+n]
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(data[4:]) {
+		return nil, 0, errTorn
+	}
+	return payload, headerSize + n, nil
+}
+
+// readLog reads every intact record of the log at path and returns their payloads with the
+// size of the intact prefix. A missing log reads as empty.
+func readLog(path string) ([][]byte, int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	var payloads [][]byte
+	off := 0
+	for off < len(data) {
+		payload, n, err := readRecord(data[off:])
+		if err != nil {
+			break
+		}
+		payloads = append(payloads, payload)
+		off += n
+	}
+	return payloads, int64(off), nil
+}
+
+// syncDir flushes the directory entry changes of dir to stable storage.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
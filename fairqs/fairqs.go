@@ -0,0 +1,175 @@
+// Package fairqs provides a weighted fair queue that shares service between tenants.
+//
+// Each tenant has its own mpqs.PriorityQueue, so items within a tenant keep the order of
+// the comparator passed to New. Across tenants, Dequeue uses weighted fair queueing with
+// self-clocked virtual finish times: every tenant with queued items has a finish tag, its
+// virtual start time plus the cost of its next item divided by its weight, and the tenant
+// with the earliest tag is served next. A tenant with weight 2 receives twice the service
+// of a tenant with weight 1 while both are backlogged, however many items either of them
+// queues, and a tenant that was idle does not build up credit.
+//
+// A Queue is not safe for concurrent use; callers must synchronize access.
+package fairqs
+
+import (
+	"github.com/byExist/priorityqueues/kmpqs"
+	"github.com/byExist/priorityqueues/mpqs"
+)
+
+type tenant[T any, P any] struct {
+	queue *mpqs.PriorityQueue[T, P]
+	start float64
+}
+
+// Queue is a weighted fair queue of items of type T with priorities of type P,
+// shared between tenants identified by keys of type K.
+type Queue[K comparable, T any, P any] struct {
+	lessFunc func(x, y mpqs.Elem[T, P]) bool
+	tenants  map[K]*tenant[T, P]
+	weights  map[K]float64
+	costFunc func(T) float64
+
+	// backlogged orders the tenants with queued items by virtual finish time.
+	backlogged *kmpqs.PriorityQueue[K, K, float64]
+	vtime      float64
+	len        int
+}
+
+// New creates an empty fair queue. Items within each tenant are ordered by lessFunc,
+// such as mpqs.StableMinFirst or mpqs.StableMaxFirst.
+func New[K comparable, T any, P any](lessFunc func(x, y mpqs.Elem[T, P]) bool) *Queue[K, T, P] {
+	return &Queue[K, T, P]{
+		lessFunc:   lessFunc,
+		tenants:    make(map[K]*tenant[T, P]),
+		weights:    make(map[K]float64),
+		backlogged: kmpqs.New(kmpqs.StableMinFirst[K, float64], func(k K) K { return k }),
+	}
+}
+
+// SetWeight sets the share of service a tenant receives relative to other tenants.
+// Tenants have weight 1 unless set otherwise. SetWeight panics if weight is not positive.
+func SetWeight[K comparable, T any, P any](q *Queue[K, T, P], tenant K, weight float64) {
+	if weight <= 0 {
+		panic("fairqs: weight must be positive")
+	}
+	q.weights[tenant] = weight
+	reschedule(q, tenant)
+}
+
+// SetCostFunc sets the function that reports how much service an item takes, such as its
+// size in bytes or its expected run time. Items cost 1 unless set otherwise, which shares
+// the number of dequeued items between tenants.
+func SetCostFunc[K comparable, T any, P any](q *Queue[K, T, P], costFunc func(T) float64) {
+	q.costFunc = costFunc
+	for k := range q.tenants {
+		reschedule(q, k)
+	}
+}
+
+// Enqueue inserts an item with the given priority into the queue of tenant.
+func Enqueue[K comparable, T any, P any](q *Queue[K, T, P], tenant K, item T, prio P) {
+	t, exists := q.tenants[tenant]
+	if !exists {
+		// An idle tenant starts at the current virtual time, so idling earns no credit.
+		t = newTenant(q)
+		q.tenants[tenant] = t
+	}
+	mpqs.Enqueue(t.queue, item, prio)
+	q.len++
+	reschedule(q, tenant)
+}
+
+// Dequeue removes and returns the next item together with its tenant: the highest priority item
+// of the tenant with the earliest virtual finish time. The boolean return value indicates whether
+// an item was returned.
+func Dequeue[K comparable, T any, P any](q *Queue[K, T, P]) (K, T, bool) {
+	key, ok := kmpqs.Peek(q.backlogged)
+	if !ok {
+		var zeroK K
+		var zeroT T
+		return zeroK, zeroT, false
+	}
+	finish, _ := kmpqs.PriorityOf(q.backlogged, key)
+	t := q.tenants[key]
+	item, _ := mpqs.Dequeue(t.queue)
+	q.len--
+	q.vtime = finish
+	if mpqs.Len(t.queue) == 0 {
+		// The tenant's last finish time equals the virtual time now, so nothing needs remembering.
+		delete(q.tenants, key)
+		kmpqs.DeleteByKey(q.backlogged, key)
+	} else {
+		t.start = finish
+		reschedule(q, key)
+	}
+	return key, item, true
+}
+
+// Peek returns the item Dequeue would return next, with its tenant, without removing it.
+// The boolean return value indicates whether the queue is non-empty.
+func Peek[K comparable, T any, P any](q *Queue[K, T, P]) (K, T, bool) {
+	key, ok := kmpqs.Peek(q.backlogged)
+	if !ok {
+		var zeroT T
+		return key, zeroT, false
+	}
+	item, _ := mpqs.Peek(q.tenants[key].queue)
+	return key, item, true
+}
+
+// Len returns the number of items queued across all tenants.
+func Len[K comparable, T any, P any](q *Queue[K, T, P]) int {
+	return q.len
+}
+
+// LenOf returns the number of items queued for tenant.
+func LenOf[K comparable, T any, P any](q *Queue[K, T, P], tenant K) int {
+	t, exists := q.tenants[tenant]
+	if !exists {
+		return 0
+	}
+	return mpqs.Len(t.queue)
+}
+
+// Tenants returns the number of tenants with queued items.
+func Tenants[K comparable, T any, P any](q *Queue[K, T, P]) int {
+	return len(q.tenants)
+}
+
+// Clear removes all items and resets the virtual time. Weights are kept.
+func Clear[K comparable, T any, P any](q *Queue[K, T, P]) {
+	clear(q.tenants)
+	kmpqs.Clear(q.backlogged)
+	q.vtime = 0
+	q.len = 0
+}
+
+func newTenant[K comparable, T any, P any](q *Queue[K, T, P]) *tenant[T, P] {
+	return &tenant[T, P]{
+		queue: mpqs.New(q.lessFunc),
+		start: q.vtime,
+	}
+}
+
+// reschedule recomputes the virtual finish time of tenant from its start time and the
+// cost of its highest priority item, which changes when a better item is enqueued.
+func reschedule[K comparable, T any, P any](q *Queue[K, T, P], tenant K) {
+	t, exists := q.tenants[tenant]
+	if !exists {
+		return
+	}
+	head, _ := mpqs.Peek(t.queue)
+	weight, ok := q.weights[tenant]
+	if !ok {
+		weight = 1
+	}
+	cost := 1.0
+	if q.costFunc != nil {
+		cost = q.costFunc(head)
+	}
+	finish := t.start + cost/weight
+	// Re-enqueueing an unchanged tag would move the tenant behind others with the same tag.
+	if queued, ok := kmpqs.PriorityOf(q.backlogged, tenant); !ok || queued != finish {
+		kmpqs.Enqueue(q.backlogged, tenant, finish)
+	}
+}
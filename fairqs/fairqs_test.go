@@ -0,0 +1,153 @@
+package fairqs_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/byExist/priorityqueues/fairqs"
+	"github.com/byExist/priorityqueues/mpqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func newQueue() *fairqs.Queue[string, string, int] {
+	return fairqs.New[string](mpqs.StableMinFirst[string, int])
+}
+
+// serve dequeues n items and counts them per tenant.
+func serve(q *fairqs.Queue[string, string, int], n int) map[string]int {
+	counts := map[string]int{}
+	for range n {
+		tenant, _, ok := fairqs.Dequeue(q)
+		if !ok {
+			break
+		}
+		counts[tenant]++
+	}
+	return counts
+}
+
+func TestNoisyTenantDoesNotMonopolize(t *testing.T) {
+	q := newQueue()
+	for i := range 100 {
+		fairqs.Enqueue(q, "noisy", fmt.Sprint("n", i), 1)
+	}
+	for i := range 5 {
+		fairqs.Enqueue(q, "quiet", fmt.Sprint("q", i), 1)
+	}
+	assert.Equal(t, 105, fairqs.Len(q))
+	assert.Equal(t, 2, fairqs.Tenants(q))
+
+	var got []string
+	for range 10 {
+		tenant, _, _ := fairqs.Dequeue(q)
+		got = append(got, tenant)
+	}
+	assert.Equal(t, []string{"noisy", "quiet", "noisy", "quiet", "noisy", "quiet", "noisy", "quiet", "noisy", "quiet"}, got)
+	assert.Equal(t, 0, fairqs.LenOf(q, "quiet"))
+	assert.Equal(t, 95, fairqs.LenOf(q, "noisy"))
+	assert.Equal(t, 1, fairqs.Tenants(q))
+}
+
+func TestWeights(t *testing.T) {
+	q := newQueue()
+	fairqs.SetWeight(q, "gold", 3)
+	for i := range 400 {
+		fairqs.Enqueue(q, "gold", fmt.Sprint(i), 1)
+		fairqs.Enqueue(q, "free", fmt.Sprint(i), 1)
+	}
+	counts := serve(q, 400)
+	assert.Equal(t, 300, counts["gold"])
+	assert.Equal(t, 100, counts["free"])
+
+	assert.Panics(t, func() { fairqs.SetWeight(q, "free", 0) })
+}
+
+func TestCostFunc(t *testing.T) {
+	q := newQueue()
+	fairqs.SetCostFunc(q, func(item string) float64 { return float64(len(item)) })
+	for range 100 {
+		fairqs.Enqueue(q, "bulk", "xxxxxxxxxx", 1)
+		fairqs.Enqueue(q, "small", "x", 1)
+	}
+	counts := serve(q, 110)
+	assert.Equal(t, 10, counts["bulk"])
+	assert.Equal(t, 100, counts["small"])
+}
+
+func TestTenantOrderingIsKept(t *testing.T) {
+	q := fairqs.New[string](mpqs.StableMaxFirst[string, int])
+	fairqs.Enqueue(q, "a", "low", 1)
+	fairqs.Enqueue(q, "a", "high", 9)
+	fairqs.Enqueue(q, "a", "high-later", 9)
+	tenant, item, ok := fairqs.Peek(q)
+	assert.True(t, ok)
+	assert.Equal(t, "a", tenant)
+	assert.Equal(t, "high", item)
+
+	var got []string
+	for fairqs.Len(q) > 0 {
+		_, item, _ := fairqs.Dequeue(q)
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"high", "high-later", "low"}, got)
+	_, _, ok = fairqs.Dequeue(q)
+	assert.False(t, ok)
+	_, _, ok = fairqs.Peek(q)
+	assert.False(t, ok)
+}
+
+func TestIdleTenantEarnsNoCredit(t *testing.T) {
+	q := newQueue()
+	for i := range 50 {
+		fairqs.Enqueue(q, "busy", fmt.Sprint(i), 1)
+	}
+	serve(q, 20)
+
+	// A tenant arriving late shares from now on instead of catching up on its idle time.
+	for i := range 50 {
+		fairqs.Enqueue(q, "late", fmt.Sprint(i), 1)
+	}
+	counts := serve(q, 20)
+	assert.Equal(t, 10, counts["busy"])
+	assert.Equal(t, 10, counts["late"])
+}
+
+func TestClear(t *testing.T) {
+	q := newQueue()
+	fairqs.SetWeight(q, "a", 2)
+	fairqs.Enqueue(q, "a", "x", 1)
+	fairqs.Enqueue(q, "b", "y", 1)
+	fairqs.Clear(q)
+	assert.Equal(t, 0, fairqs.Len(q))
+	assert.Equal(t, 0, fairqs.Tenants(q))
+	_, _, ok := fairqs.Dequeue(q)
+	assert.False(t, ok)
+
+	for i := range 30 {
+		fairqs.Enqueue(q, "a", fmt.Sprint(i), 1)
+		fairqs.Enqueue(q, "b", fmt.Sprint(i), 1)
+	}
+	counts := serve(q, 30)
+	assert.Equal(t, 20, counts["a"])
+}
+
+func Example() {
+	q := fairqs.New[string](mpqs.StableMinFirst[string, int])
+	fairqs.SetWeight(q, "tenant-a", 2)
+	for i := range 4 {
+		fairqs.Enqueue(q, "tenant-a", fmt.Sprint("a", i), 1)
+		fairqs.Enqueue(q, "tenant-b", fmt.Sprint("b", i), 1)
+	}
+
+	for range 6 {
+		_, item, _ := fairqs.Dequeue(q)
+		fmt.Println(item)
+	}
+	// Output:
+	// a0
+	// b0
+	// a1
+	// a2
+	// b1
+	// a3
+}
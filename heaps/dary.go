@@ -0,0 +1,161 @@
+package heaps
+
+import (
+	"iter"
+	"math/bits"
+)
+
+type dary[E any] struct {
+	d     int
+	nodes []*Node[E]
+	less  func(a, b E) bool
+}
+
+// Binary returns an empty binary heap ordered by less.
+// It is the default backend of every queue package.
+func Binary[E any](less func(a, b E) bool) Backend[E] {
+	return &dary[E]{d: 2, less: less}
+}
+
+// Quaternary returns an empty 4-ary heap ordered by less.
+// Its shallower tree makes pushes and priority decreases cheaper than in a binary heap.
+func Quaternary[E any](less func(a, b E) bool) Backend[E] {
+	return &dary[E]{d: 4, less: less}
+}
+
+// Dary returns a Factory of d-ary heaps, where each node has up to d children.
+// It panics if d is less than 2.
+func Dary[E any](d int) Factory[E] {
+	if d < 2 {
+		panic("heaps: d-ary heap requires d >= 2")
+	}
+	return func(less func(a, b E) bool) Backend[E] {
+		return &dary[E]{d: d, less: less}
+	}
+}
+
+func (h *dary[E]) Len() int {
+	return len(h.nodes)
+}
+
+func (h *dary[E]) Push(v E) *Node[E] {
+	n := &Node[E]{Value: v, index: len(h.nodes)}
+	h.nodes = append(h.nodes, n)
+	h.up(n.index)
+	return n
+}
+
+func (h *dary[E]) Peek() *Node[E] {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	return h.nodes[0]
+}
+
+func (h *dary[E]) Pop() *Node[E] {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	n := h.nodes[0]
+	h.removeAt(0)
+	return n
+}
+
+func (h *dary[E]) Fix(n *Node[E]) {
+	if !h.down(n.index) {
+		h.up(n.index)
+	}
+}
+
+func (h *dary[E]) Remove(n *Node[E]) {
+	h.removeAt(n.index)
+}
+
+func (h *dary[E]) Clear() {
+	h.nodes = nil
+}
+
+// Meld appends the nodes of other and restores the heap order, sifting them up one by one
+// when there are few of them and otherwise rebuilding the whole heap in linear time.
+func (h *dary[E]) Meld(other Backend[E]) {
+	nodes := take(other)
+	for _, n := range nodes {
+		n.index = len(h.nodes)
+		h.nodes = append(h.nodes, n)
+	}
+	if len(nodes)*bits.Len(uint(len(h.nodes))) < len(h.nodes) {
+		for _, n := range nodes {
+			h.up(n.index)
+		}
+		return
+	}
+	for i := (len(h.nodes) - 2) / h.d; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+func (h *dary[E]) All() iter.Seq[*Node[E]] {
+	return func(yield func(*Node[E]) bool) {
+		for _, n := range h.nodes {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+func (h *dary[E]) removeAt(i int) {
+	last := len(h.nodes) - 1
+	if i != last {
+		h.swap(i, last)
+	}
+	h.nodes[last].index = -1
+	h.nodes[last] = nil
+	h.nodes = h.nodes[:last]
+	if i != last {
+		if !h.down(i) {
+			h.up(i)
+		}
+	}
+}
+
+func (h *dary[E]) swap(i, j int) {
+	h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i]
+	h.nodes[i].index = i
+	h.nodes[j].index = j
+}
+
+func (h *dary[E]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / h.d
+		if !h.less(h.nodes[i].Value, h.nodes[parent].Value) {
+			break
+		}
+		h.swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the node at i0 towards the leaves and reports whether it moved.
+func (h *dary[E]) down(i0 int) bool {
+	n := len(h.nodes)
+	i := i0
+	for {
+		first := h.d*i + 1
+		if first >= n || first < 0 { // first < 0 after int overflow
+			break
+		}
+		best := first
+		for c := first + 1; c < first+h.d && c < n; c++ {
+			if h.less(h.nodes[c].Value, h.nodes[best].Value) {
+				best = c
+			}
+		}
+		if !h.less(h.nodes[best].Value, h.nodes[i].Value) {
+			break
+		}
+		h.swap(i, best)
+		i = best
+	}
+	return i > i0
+}
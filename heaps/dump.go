@@ -0,0 +1,158 @@
+package heaps
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+type treer[E any] interface {
+	tree(yield func(n, parent *Node[E]) bool)
+}
+
+// Tree returns an iterator over the nodes of b in depth-first order, each paired with its
+// parent in the heap tree, or nil for a root. Children are yielded in the order the backend
+// keeps them. Array based heaps have a single root, a Fibonacci heap has one for every tree
+// in its root list, and every node of a backend implemented outside this package is a root.
+// The heap must not be modified during iteration.
+func Tree[E any](b Backend[E]) iter.Seq2[*Node[E], *Node[E]] {
+	if t, ok := b.(treer[E]); ok {
+		return t.tree
+	}
+	return func(yield func(n, parent *Node[E]) bool) {
+		for n := range b.All() {
+			if !yield(n, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (h *dary[E]) tree(yield func(n, parent *Node[E]) bool) {
+	arrayTree(h.nodes, h.d, yield)
+}
+
+func (h *minMax[E]) tree(yield func(n, parent *Node[E]) bool) {
+	arrayTree(h.nodes, 2, yield)
+}
+
+func arrayTree[E any](nodes []*Node[E], d int, yield func(n, parent *Node[E]) bool) {
+	if len(nodes) == 0 {
+		return
+	}
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		var parent *Node[E]
+		if i > 0 {
+			parent = nodes[(i-1)/d]
+		}
+		if !yield(nodes[i], parent) {
+			return
+		}
+		for c := min(i*d+d, len(nodes)-1); c > i*d; c-- {
+			stack = append(stack, c)
+		}
+	}
+}
+
+func (h *pairing[E]) tree(yield func(n, parent *Node[E]) bool) {
+	if h.root != nil {
+		linkedTree(h.root, nil, false, yield)
+	}
+}
+
+func (h *fibonacci[E]) tree(yield func(n, parent *Node[E]) bool) {
+	if h.min != nil {
+		linkedTree(h.min, nil, true, yield)
+	}
+}
+
+// linkedTree yields the sibling list starting at first and their subtrees.
+// Circular lists end when they return to first, others at nil.
+func linkedTree[E any](first, parent *Node[E], circular bool, yield func(n, parent *Node[E]) bool) bool {
+	for n := first; n != nil; {
+		if !yield(n, parent) {
+			return false
+		}
+		if n.child != nil && !linkedTree(n.child, n, circular, yield) {
+			return false
+		}
+		if n = n.next; circular && n == first {
+			break
+		}
+	}
+	return true
+}
+
+// WriteTree writes the heap tree of b to w as indented text, one node per line labelled by label,
+// with the lines joined by newlines. Roots, such as the trees of a Fibonacci heap, start at the left margin.
+func WriteTree[E any](w io.Writer, b Backend[E], label func(E) string) error {
+	bw := bufio.NewWriter(w)
+	// Indentation depends on whether each ancestor is the last of its siblings,
+	// so collect the children before writing.
+	var roots []*Node[E]
+	children := make(map[*Node[E]][]*Node[E])
+	for n, parent := range Tree(b) {
+		if parent == nil {
+			roots = append(roots, n)
+		} else {
+			children[parent] = append(children[parent], n)
+		}
+	}
+	first := true
+	var write func(n *Node[E], prefix, branch, indent string)
+	write = func(n *Node[E], prefix, branch, indent string) {
+		if !first {
+			bw.WriteByte('\n')
+		}
+		first = false
+		bw.WriteString(prefix + branch + label(n.Value))
+		kids := children[n]
+		for i, c := range kids {
+			if i == len(kids)-1 {
+				write(c, prefix+indent, "└── ", "    ")
+			} else {
+				write(c, prefix+indent, "├── ", "│   ")
+			}
+		}
+	}
+	for _, r := range roots {
+		write(r, "", "", "")
+	}
+	return bw.Flush()
+}
+
+// WriteDOT writes the heap tree of b to w as a Graphviz digraph with the given name.
+// Nodes are labelled by label, and the node at the top of the heap is drawn in bold.
+func WriteDOT[E any](w io.Writer, b Backend[E], name string, label func(E) string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph " + dotQuote(name) + " {\n")
+	bw.WriteString("\tnode [shape=box];\n")
+	ids := make(map[*Node[E]]int)
+	top := b.Peek()
+	for n, parent := range Tree(b) {
+		id := len(ids)
+		ids[n] = id
+		bw.WriteString("\tn" + strconv.Itoa(id) + " [label=" + dotQuote(label(n.Value)))
+		if n == top {
+			bw.WriteString(", style=bold")
+		}
+		bw.WriteString("];\n")
+		if parent != nil {
+			bw.WriteString("\tn" + strconv.Itoa(ids[parent]) + " -> n" + strconv.Itoa(id) + ";\n")
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotQuote returns s as a quoted DOT string, with newlines as centered line breaks.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
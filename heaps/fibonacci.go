@@ -0,0 +1,294 @@
+package heaps
+
+import "iter"
+
+// fibonacci is a Fibonacci heap. Roots and siblings form circular lists linked
+// through prev and next; min points into the root list.
+type fibonacci[E any] struct {
+	min  *Node[E]
+	n    int
+	less func(a, b E) bool
+}
+
+// Fibonacci returns an empty Fibonacci heap ordered by less.
+// Push and priority decreases through Fix take amortized constant time, while
+// Pop and Remove take amortized logarithmic time.
+func Fibonacci[E any](less func(a, b E) bool) Backend[E] {
+	return &fibonacci[E]{less: less}
+}
+
+func (h *fibonacci[E]) Len() int {
+	return h.n
+}
+
+func (h *fibonacci[E]) Push(v E) *Node[E] {
+	n := &Node[E]{Value: v}
+	h.insert(n)
+	return n
+}
+
+func (h *fibonacci[E]) Peek() *Node[E] {
+	return h.min
+}
+
+func (h *fibonacci[E]) Pop() *Node[E] {
+	n := h.min
+	if n == nil {
+		return nil
+	}
+	h.extractMin()
+	return n
+}
+
+func (h *fibonacci[E]) Fix(n *Node[E]) {
+	if n == h.min {
+		// The minimum may have increased, so any root or child could replace it.
+		h.extractMin()
+		h.insert(n)
+		return
+	}
+	if p := n.parent; p != nil && (h.less(n.Value, p.Value) || h.childLess(n)) {
+		h.cut(n, p)
+		h.cascadingCut(p)
+	}
+	if h.childLess(n) {
+		// The value increased past one of its children; n is a root at this point.
+		h.promoteChildren(n)
+	}
+	if n.parent == nil && h.less(n.Value, h.min.Value) {
+		h.min = n
+	}
+}
+
+func (h *fibonacci[E]) Remove(n *Node[E]) {
+	if n != h.min {
+		if p := n.parent; p != nil {
+			h.cut(n, p)
+			h.cascadingCut(p)
+		}
+		// Treat n as the minimum; extractMin recomputes the real one.
+		h.min = n
+	}
+	h.extractMin()
+}
+
+func (h *fibonacci[E]) Clear() {
+	h.min = nil
+	h.n = 0
+}
+
+// Meld splices the root list of another Fibonacci heap in constant time.
+// Nodes of other backends are inserted one by one.
+func (h *fibonacci[E]) Meld(other Backend[E]) {
+	o, ok := other.(*fibonacci[E])
+	if !ok {
+		for _, n := range take(other) {
+			h.insert(n)
+		}
+		return
+	}
+	if o.min == nil {
+		return
+	}
+	if h.min == nil {
+		h.min = o.min
+	} else {
+		first, last := h.min.next, o.min.prev
+		h.min.next = o.min
+		o.min.prev = h.min
+		last.next = first
+		first.prev = last
+		if h.less(o.min.Value, h.min.Value) {
+			h.min = o.min
+		}
+	}
+	h.n += o.n
+	o.Clear()
+}
+
+func (h *fibonacci[E]) All() iter.Seq[*Node[E]] {
+	return func(yield func(*Node[E]) bool) {
+		if h.min == nil {
+			return
+		}
+		stack := []*Node[E]{h.min}
+		for len(stack) > 0 {
+			first := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			n := first
+			for {
+				if !yield(n) {
+					return
+				}
+				if n.child != nil {
+					stack = append(stack, n.child)
+				}
+				n = n.next
+				if n == first {
+					break
+				}
+			}
+		}
+	}
+}
+
+// insert adds the detached node n to the root list.
+func (h *fibonacci[E]) insert(n *Node[E]) {
+	n.parent, n.child = nil, nil
+	n.degree = 0
+	n.marked = false
+	h.addRoot(n)
+	if h.less(n.Value, h.min.Value) {
+		h.min = n
+	}
+	h.n++
+}
+
+// addRoot splices n into the root list without updating min, unless the list is empty.
+func (h *fibonacci[E]) addRoot(n *Node[E]) {
+	n.parent = nil
+	n.marked = false
+	if h.min == nil {
+		n.prev, n.next = n, n
+		h.min = n
+		return
+	}
+	n.prev = h.min
+	n.next = h.min.next
+	h.min.next.prev = n
+	h.min.next = n
+}
+
+// extractMin removes min from the heap and consolidates the root list.
+func (h *fibonacci[E]) extractMin() {
+	z := h.min
+	h.promoteChildren(z)
+	if z.next == z {
+		h.min = nil
+	} else {
+		z.prev.next = z.next
+		z.next.prev = z.prev
+		h.min = z.next
+		h.consolidate()
+	}
+	z.prev, z.next = nil, nil
+	h.n--
+}
+
+// promoteChildren moves every child of n into the root list.
+func (h *fibonacci[E]) promoteChildren(n *Node[E]) {
+	c := n.child
+	if c == nil {
+		return
+	}
+	children := make([]*Node[E], 0, n.degree)
+	for {
+		children = append(children, c)
+		c = c.next
+		if c == n.child {
+			break
+		}
+	}
+	for _, c := range children {
+		h.addRoot(c)
+	}
+	n.child = nil
+	n.degree = 0
+}
+
+// consolidate links roots of equal degree until all roots have distinct degrees
+// and recomputes min.
+func (h *fibonacci[E]) consolidate() {
+	var roots []*Node[E]
+	for r := h.min; ; {
+		roots = append(roots, r)
+		r = r.next
+		if r == h.min {
+			break
+		}
+	}
+	var byDegree []*Node[E]
+	for _, x := range roots {
+		d := x.degree
+		for d < len(byDegree) && byDegree[d] != nil {
+			y := byDegree[d]
+			if h.less(y.Value, x.Value) {
+				x, y = y, x
+			}
+			h.link(y, x)
+			byDegree[d] = nil
+			d++
+		}
+		for d >= len(byDegree) {
+			byDegree = append(byDegree, nil)
+		}
+		byDegree[d] = x
+	}
+	h.min = nil
+	for _, x := range byDegree {
+		if x == nil {
+			continue
+		}
+		h.addRoot(x)
+		if h.less(x.Value, h.min.Value) {
+			h.min = x
+		}
+	}
+}
+
+// link makes the root y a child of the root x. The root list itself is rebuilt by consolidate.
+func (h *fibonacci[E]) link(y, x *Node[E]) {
+	y.parent = x
+	y.marked = false
+	if x.child == nil {
+		y.prev, y.next = y, y
+		x.child = y
+	} else {
+		y.prev = x.child
+		y.next = x.child.next
+		x.child.next.prev = y
+		x.child.next = y
+	}
+	x.degree++
+}
+
+// cut moves n from the child list of p to the root list.
+func (h *fibonacci[E]) cut(n, p *Node[E]) {
+	if n.next == n {
+		p.child = nil
+	} else {
+		n.prev.next = n.next
+		n.next.prev = n.prev
+		if p.child == n {
+			p.child = n.next
+		}
+	}
+	p.degree--
+	h.addRoot(n)
+}
+
+func (h *fibonacci[E]) cascadingCut(n *Node[E]) {
+	for p := n.parent; p != nil; n, p = p, p.parent {
+		if !n.marked {
+			n.marked = true
+			return
+		}
+		h.cut(n, p)
+	}
+}
+
+func (h *fibonacci[E]) childLess(n *Node[E]) bool {
+	c := n.child
+	if c == nil {
+		return false
+	}
+	for {
+		if h.less(c.Value, n.Value) {
+			return true
+		}
+		c = c.next
+		if c == n.child {
+			return false
+		}
+	}
+}
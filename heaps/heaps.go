@@ -0,0 +1,72 @@
+// Package heaps provides the addressable heap backends used by the priority queue packages.
+//
+// A Backend keeps the element that is least under its less function at the top.
+// Every pushed element is wrapped in a Node, which stays a stable handle to that element
+// until it is popped or removed, so callers can change its value and restore the heap
+// order with Fix, or remove it with Remove.
+//
+// The queue packages accept any Factory through their NewWithBackend constructors:
+//
+//	q := kmpqs.NewWithBackend(kmpqs.StableMinFirst[*Job, int], keyFunc, heaps.Pairing)
+package heaps
+
+import "iter"
+
+// Node holds a single element of a Backend.
+// Its Value may be changed in place as long as Fix is called afterwards.
+type Node[E any] struct {
+	Value E
+
+	// index is the position of the node in array based heaps.
+	index int
+
+	// parent, child, prev and next link the node into pointer based heaps.
+	parent *Node[E]
+	child  *Node[E]
+	prev   *Node[E]
+	next   *Node[E]
+	degree int
+	marked bool
+}
+
+// Backend is an addressable heap. The element that is least under the backend's
+// less function is at the top.
+type Backend[E any] interface {
+	// Len returns the number of elements in the heap.
+	Len() int
+	// Push inserts v and returns the node holding it.
+	Push(v E) *Node[E]
+	// Peek returns the node at the top of the heap, or nil if the heap is empty.
+	Peek() *Node[E]
+	// Pop removes and returns the node at the top of the heap, or nil if the heap is empty.
+	Pop() *Node[E]
+	// Fix restores the heap order after the Value of n has changed.
+	Fix(n *Node[E])
+	// Remove removes n from the heap.
+	Remove(n *Node[E])
+	// Clear removes all elements from the heap.
+	Clear()
+	// Meld moves every node of other, which must be ordered by the same less function,
+	// into the heap and leaves other empty. The moved nodes remain valid handles.
+	Meld(other Backend[E])
+	// All returns an iterator over the nodes in the heap in unspecified order.
+	// The heap must not be modified during iteration.
+	All() iter.Seq[*Node[E]]
+}
+
+// take empties b and returns its nodes, detached from any heap structure.
+func take[E any](b Backend[E]) []*Node[E] {
+	nodes := make([]*Node[E], 0, b.Len())
+	for n := range b.All() {
+		nodes = append(nodes, n)
+	}
+	b.Clear()
+	for _, n := range nodes {
+		*n = Node[E]{Value: n.Value}
+	}
+	return nodes
+}
+
+// Factory creates an empty Backend ordered by less.
+// Binary, Quaternary, Pairing and Fibonacci can all be used as a Factory.
+type Factory[E any] func(less func(a, b E) bool) Backend[E]
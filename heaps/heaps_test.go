@@ -0,0 +1,497 @@
+package heaps_test
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/stretchr/testify/assert"
+)
+
+var backends = []struct {
+	name    string
+	factory heaps.Factory[int]
+}{
+	{"Binary", heaps.Binary[int]},
+	{"Quaternary", heaps.Quaternary[int]},
+	{"Dary3", heaps.Dary[int](3)},
+	{"Pairing", heaps.Pairing[int]},
+	{"Fibonacci", heaps.Fibonacci[int]},
+	{"MinMax", func(less func(a, b int) bool) heaps.Backend[int] { return heaps.MinMax(less) }},
+}
+
+func less(a, b int) bool {
+	return a < b
+}
+
+func values(h heaps.Backend[int]) []int {
+	var vs []int
+	for n := range h.All() {
+		vs = append(vs, n.Value)
+	}
+	slices.Sort(vs)
+	return vs
+}
+
+func TestPushPop(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			h := b.factory(less)
+			for _, v := range []int{5, 3, 8, 1, 9, 2} {
+				h.Push(v)
+			}
+			assert.Equal(t, 6, h.Len())
+			assert.Equal(t, 1, h.Peek().Value)
+			var got []int
+			for h.Len() > 0 {
+				got = append(got, h.Pop().Value)
+			}
+			assert.Equal(t, []int{1, 2, 3, 5, 8, 9}, got)
+			assert.Nil(t, h.Pop())
+			assert.Nil(t, h.Peek())
+		})
+	}
+}
+
+func TestFixAndRemove(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			h := b.factory(less)
+			nodes := map[int]*heaps.Node[int]{}
+			for _, v := range []int{10, 20, 30, 40, 50} {
+				nodes[v] = h.Push(v)
+			}
+			h.Pop()
+			nodes[40].Value = 5
+			h.Fix(nodes[40])
+			assert.Equal(t, 5, h.Peek().Value)
+			nodes[40].Value = 45
+			h.Fix(nodes[40])
+			assert.Equal(t, 20, h.Peek().Value)
+			h.Remove(nodes[30])
+			assert.Equal(t, []int{20, 45, 50}, values(h))
+			h.Clear()
+			assert.Equal(t, 0, h.Len())
+			assert.Nil(t, h.Peek())
+		})
+	}
+}
+
+func TestRandomOperations(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			h := b.factory(less)
+			var live []*heaps.Node[int]
+			for range 5000 {
+				switch op := r.IntN(10); {
+				case op < 4:
+					live = append(live, h.Push(r.IntN(1000)))
+				case op < 6 && h.Len() > 0:
+					top := h.Pop()
+					assert.Equal(t, slices.MinFunc(live, func(a, b *heaps.Node[int]) int {
+						return cmp.Compare(a.Value, b.Value)
+					}).Value, top.Value)
+					live = slices.DeleteFunc(live, func(n *heaps.Node[int]) bool { return n == top })
+				case op < 8 && len(live) > 0:
+					n := live[r.IntN(len(live))]
+					n.Value = r.IntN(1000)
+					h.Fix(n)
+				case len(live) > 0:
+					i := r.IntN(len(live))
+					h.Remove(live[i])
+					live = slices.Delete(live, i, i+1)
+				}
+				assert.Equal(t, len(live), h.Len())
+			}
+			want := make([]int, 0, len(live))
+			for _, n := range live {
+				want = append(want, n.Value)
+			}
+			slices.Sort(want)
+			assert.Equal(t, want, values(h))
+			var got []int
+			for h.Len() > 0 {
+				got = append(got, h.Pop().Value)
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestMeld(t *testing.T) {
+	for _, dst := range backends {
+		for _, src := range backends {
+			t.Run(dst.name+"/"+src.name, func(t *testing.T) {
+				r := rand.New(rand.NewPCG(5, 6))
+				a, b := dst.factory(less), src.factory(less)
+				var moved []*heaps.Node[int]
+				for range 200 {
+					a.Push(r.IntN(1000))
+					moved = append(moved, b.Push(r.IntN(1000)))
+				}
+				// Pop once so that pointer based backends hold non-trivial trees.
+				a.Pop()
+				top := b.Pop()
+				moved = slices.DeleteFunc(moved, func(n *heaps.Node[int]) bool { return n == top })
+
+				a.Meld(b)
+				assert.Equal(t, 0, b.Len())
+				assert.Nil(t, b.Peek())
+				assert.Equal(t, 398, a.Len())
+
+				// Moved nodes remain valid handles in the destination.
+				n := moved[len(moved)/2]
+				n.Value = -1
+				a.Fix(n)
+				assert.Same(t, n, a.Peek())
+				want := values(a)
+				a.Remove(moved[0])
+				want = slices.Delete(want, slices.Index(want, moved[0].Value), slices.Index(want, moved[0].Value)+1)
+
+				var got []int
+				for a.Len() > 0 {
+					got = append(got, a.Pop().Value)
+				}
+				assert.Equal(t, want, got)
+			})
+		}
+	}
+}
+
+func TestMeldUnordered(t *testing.T) {
+	greater := func(x, y int) bool { return x > y }
+	for _, dst := range backends {
+		for _, src := range backends {
+			t.Run(dst.name+"/"+src.name, func(t *testing.T) {
+				r := rand.New(rand.NewPCG(7, 8))
+				a, b := dst.factory(less), src.factory(greater)
+				var moved []*heaps.Node[int]
+				for range 100 {
+					a.Push(r.IntN(1000))
+					moved = append(moved, b.Push(r.IntN(1000)))
+				}
+				a.Pop()
+				top := b.Pop()
+				moved = slices.DeleteFunc(moved, func(n *heaps.Node[int]) bool { return n == top })
+
+				heaps.MeldUnordered(a, b)
+				assert.Equal(t, 0, b.Len())
+				assert.Equal(t, 198, a.Len())
+				assert.Empty(t, heaps.Validate(a))
+
+				n := moved[len(moved)/2]
+				n.Value = -1
+				a.Fix(n)
+				assert.Same(t, n, a.Peek())
+				want := values(a)
+				var got []int
+				for a.Len() > 0 {
+					got = append(got, a.Pop().Value)
+				}
+				assert.Equal(t, want, got)
+			})
+		}
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			desc := false
+			h := b.factory(func(x, y int) bool { return x < y && !desc || x > y && desc })
+			var nodes []*heaps.Node[int]
+			for v := range 50 {
+				nodes = append(nodes, h.Push(v))
+			}
+			h.Pop()
+			desc = true
+			heaps.Rebuild(h)
+			assert.Empty(t, heaps.Validate(h))
+
+			// Nodes remain valid handles after the rebuild.
+			nodes[10].Value = 100
+			h.Fix(nodes[10])
+			assert.Same(t, nodes[10], h.Peek())
+			var got []int
+			for h.Len() > 0 {
+				got = append(got, h.Pop().Value)
+			}
+			assert.Equal(t, 49, len(got))
+			assert.True(t, slices.IsSortedFunc(got, func(a, b int) int { return b - a }))
+		})
+	}
+}
+
+func TestRebuildForeignBackend(t *testing.T) {
+	desc := false
+	h := sliceBackend{Backend: heaps.Binary(func(x, y int) bool { return x < y && !desc || x > y && desc })}
+	for _, v := range []int{3, 1, 2} {
+		h.Push(v)
+	}
+	desc = true
+	heaps.Rebuild[int](h)
+	assert.Equal(t, 3, h.Pop().Value)
+	assert.Equal(t, 2, h.Pop().Value)
+}
+
+func TestDaryPanicsOnSmallD(t *testing.T) {
+	assert.Panics(t, func() { heaps.Dary[int](1) })
+}
+
+func TestMinMaxRandomOperations(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	h := heaps.MinMax(less)
+	var live []*heaps.Node[int]
+	byValue := func(a, b *heaps.Node[int]) int { return cmp.Compare(a.Value, b.Value) }
+	for range 5000 {
+		switch op := r.IntN(10); {
+		case op < 4:
+			live = append(live, h.Push(r.IntN(1000)))
+		case op < 5 && h.Len() > 0:
+			top := h.Pop()
+			assert.Equal(t, slices.MinFunc(live, byValue).Value, top.Value)
+			live = slices.DeleteFunc(live, func(n *heaps.Node[int]) bool { return n == top })
+		case op < 6 && h.Len() > 0:
+			top := h.PopMax()
+			assert.Equal(t, slices.MaxFunc(live, byValue).Value, top.Value)
+			live = slices.DeleteFunc(live, func(n *heaps.Node[int]) bool { return n == top })
+		case op < 8 && len(live) > 0:
+			n := live[r.IntN(len(live))]
+			n.Value = r.IntN(1000)
+			h.Fix(n)
+		case len(live) > 0:
+			i := r.IntN(len(live))
+			h.Remove(live[i])
+			live = slices.Delete(live, i, i+1)
+		}
+		if len(live) > 0 {
+			assert.Equal(t, slices.MinFunc(live, byValue).Value, h.Peek().Value)
+			assert.Equal(t, slices.MaxFunc(live, byValue).Value, h.PeekMax().Value)
+		}
+	}
+}
+
+func TestMinMaxEmpty(t *testing.T) {
+	h := heaps.MinMax(less)
+	assert.Nil(t, h.PeekMax())
+	assert.Nil(t, h.PopMax())
+	h.Push(1)
+	assert.Equal(t, 1, h.PeekMax().Value)
+	assert.Equal(t, 1, h.PopMax().Value)
+}
+
+func TestValidate(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(5, 6))
+			h := b.factory(less)
+			var live []*heaps.Node[int]
+			for range 2000 {
+				switch op := r.IntN(10); {
+				case op < 5:
+					live = append(live, h.Push(r.IntN(100)))
+				case op < 7 && h.Len() > 0:
+					top := h.Pop()
+					live = slices.DeleteFunc(live, func(n *heaps.Node[int]) bool { return n == top })
+				case op < 9 && len(live) > 0:
+					n := live[r.IntN(len(live))]
+					n.Value = r.IntN(100)
+					h.Fix(n)
+				case len(live) > 0:
+					i := r.IntN(len(live))
+					h.Remove(live[i])
+					live = slices.Delete(live, i, i+1)
+				}
+				if errs := heaps.Validate(h); !assert.Empty(t, errs) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestValidateReportsOrderViolations(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			h := b.factory(less)
+			var nodes []*heaps.Node[int]
+			for v := range 20 {
+				nodes = append(nodes, h.Push(v))
+			}
+			h.Pop()
+			// Change a value in place without Fix.
+			nodes[19].Value = -1
+
+			errs := heaps.Validate(h)
+			assert.NotEmpty(t, errs)
+			for _, err := range errs {
+				var oe *heaps.OrderError[int]
+				if assert.ErrorAs(t, err, &oe) {
+					assert.Contains(t, []*heaps.Node[int]{oe.Node, oe.Ancestor}, nodes[19])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateReportsComparatorErrors(t *testing.T) {
+	h := heaps.Binary(func(a, b float64) bool { return a < b })
+	for _, v := range []float64{1, math.NaN(), 2, 3} {
+		h.Push(v)
+	}
+	errs := heaps.Validate(h)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], heaps.ErrComparator)
+	assert.ErrorContains(t, errs[0], "incomparability is not transitive")
+
+	h2 := heaps.Pairing(func(a, b int) bool { return a <= b })
+	for v := range 5 {
+		h2.Push(v)
+	}
+	errs = heaps.Validate(h2)
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "less(x, x) is true")
+}
+
+type sliceBackend struct {
+	heaps.Backend[int]
+	extra int
+}
+
+func (s sliceBackend) Len() int {
+	return s.Backend.Len() + s.extra
+}
+
+func TestValidateForeignBackend(t *testing.T) {
+	h := sliceBackend{Backend: heaps.Binary(less)}
+	h.Push(1)
+	assert.Empty(t, heaps.Validate[int](h))
+	h.extra = 1
+	errs := heaps.Validate[int](h)
+	assert.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], heaps.ErrStructure))
+}
+
+func TestTree(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			h := b.factory(less)
+			for _, v := range rand.New(rand.NewPCG(7, 8)).Perm(50) {
+				h.Push(v)
+			}
+			h.Pop()
+			seen := make(map[*heaps.Node[int]]bool)
+			for n, parent := range heaps.Tree(h) {
+				if parent != nil {
+					assert.True(t, seen[parent], "parent yielded before child")
+				}
+				seen[n] = true
+			}
+			assert.Len(t, seen, h.Len())
+		})
+	}
+}
+
+func TestTreeStopsEarly(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			h := b.factory(less)
+			for v := range 10 {
+				h.Push(v)
+			}
+			h.Pop()
+			n := 0
+			for range heaps.Tree(h) {
+				if n++; n == 3 {
+					break
+				}
+			}
+			assert.Equal(t, 3, n)
+		})
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	h := heaps.Quaternary(less)
+	for v := range 7 {
+		h.Push(v)
+	}
+	var sb strings.Builder
+	assert.NoError(t, heaps.WriteTree(&sb, h, strconv.Itoa))
+	assert.Equal(t, `0
+├── 1
+│   ├── 5
+│   └── 6
+├── 2
+├── 3
+└── 4`, sb.String())
+
+	sb.Reset()
+	assert.NoError(t, heaps.WriteTree(&sb, heaps.Binary(less), strconv.Itoa))
+	assert.Empty(t, sb.String())
+}
+
+func TestWriteDOTEscapes(t *testing.T) {
+	h := heaps.Binary(func(a, b string) bool { return a < b })
+	h.Push("say \"hi\"\nnow")
+	var sb strings.Builder
+	assert.NoError(t, heaps.WriteDOT(&sb, h, "q", func(s string) string { return s }))
+	assert.Contains(t, sb.String(), `n0 [label="say \"hi\"\nnow", style=bold];`)
+}
+
+func ExamplePairing() {
+	h := heaps.Pairing(func(a, b string) bool { return len(a) < len(b) })
+	h.Push("banana")
+	n := h.Push("fig")
+	h.Push("kiwi")
+	n.Value = "elderberry"
+	h.Fix(n)
+	for h.Len() > 0 {
+		fmt.Println(h.Pop().Value)
+	}
+	// Output:
+	// kiwi
+	// banana
+	// elderberry
+}
+
+func ExampleWriteTree() {
+	h := heaps.Binary(less)
+	for _, v := range []int{5, 3, 8, 1, 9, 2} {
+		h.Push(v)
+	}
+	heaps.WriteTree(os.Stdout, h, strconv.Itoa)
+	// Output:
+	// 1
+	// ├── 3
+	// │   ├── 5
+	// │   └── 9
+	// └── 2
+	//     └── 8
+}
+
+func ExampleWriteDOT() {
+	h := heaps.Binary(less)
+	for _, v := range []int{3, 1, 2} {
+		h.Push(v)
+	}
+	heaps.WriteDOT(os.Stdout, h, "heap", strconv.Itoa)
+	// Output:
+	// digraph "heap" {
+	// 	node [shape=box];
+	// 	n0 [label="1", style=bold];
+	// 	n1 [label="3"];
+	// 	n0 -> n1;
+	// 	n2 [label="2"];
+	// 	n0 -> n2;
+	// }
+}
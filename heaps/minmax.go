@@ -0,0 +1,216 @@
+package heaps
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// DoubleEnded is a Backend that also gives access to its greatest element under less.
+type DoubleEnded[E any] interface {
+	Backend[E]
+	// PeekMax returns the node holding the greatest element, or nil if the heap is empty.
+	PeekMax() *Node[E]
+	// PopMax removes and returns the node holding the greatest element, or nil if the heap is empty.
+	PopMax() *Node[E]
+}
+
+// minMax is a min-max heap: nodes on even levels are no greater than their
+// descendants and nodes on odd levels are no less than their descendants.
+type minMax[E any] struct {
+	nodes []*Node[E]
+	less  func(a, b E) bool
+}
+
+// MinMax returns an empty min-max heap ordered by less.
+// Both the least and the greatest element can be inspected in O(1) and removed in O(log n).
+func MinMax[E any](less func(a, b E) bool) DoubleEnded[E] {
+	return &minMax[E]{less: less}
+}
+
+func (h *minMax[E]) Len() int {
+	return len(h.nodes)
+}
+
+func (h *minMax[E]) Push(v E) *Node[E] {
+	n := &Node[E]{Value: v, index: len(h.nodes)}
+	h.nodes = append(h.nodes, n)
+	h.fix(n.index)
+	return n
+}
+
+func (h *minMax[E]) Peek() *Node[E] {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	return h.nodes[0]
+}
+
+func (h *minMax[E]) PeekMax() *Node[E] {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	return h.nodes[h.maxIndex()]
+}
+
+func (h *minMax[E]) Pop() *Node[E] {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	n := h.nodes[0]
+	h.removeAt(0)
+	return n
+}
+
+func (h *minMax[E]) PopMax() *Node[E] {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	i := h.maxIndex()
+	n := h.nodes[i]
+	h.removeAt(i)
+	return n
+}
+
+func (h *minMax[E]) Fix(n *Node[E]) {
+	h.fix(n.index)
+}
+
+func (h *minMax[E]) Remove(n *Node[E]) {
+	h.removeAt(n.index)
+}
+
+func (h *minMax[E]) Clear() {
+	h.nodes = nil
+}
+
+// Meld appends the nodes of other and rebuilds the heap in linear time.
+func (h *minMax[E]) Meld(other Backend[E]) {
+	for _, n := range take(other) {
+		n.index = len(h.nodes)
+		h.nodes = append(h.nodes, n)
+	}
+	for i := len(h.nodes)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+func (h *minMax[E]) All() iter.Seq[*Node[E]] {
+	return func(yield func(*Node[E]) bool) {
+		for _, n := range h.nodes {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+func (h *minMax[E]) maxIndex() int {
+	switch len(h.nodes) {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	}
+	if h.less(h.nodes[1].Value, h.nodes[2].Value) {
+		return 2
+	}
+	return 1
+}
+
+func (h *minMax[E]) removeAt(i int) {
+	last := len(h.nodes) - 1
+	if i != last {
+		h.swap(i, last)
+	}
+	h.nodes[last].index = -1
+	h.nodes[last] = nil
+	h.nodes = h.nodes[:last]
+	if i != last {
+		h.fix(i)
+	}
+}
+
+func (h *minMax[E]) swap(i, j int) {
+	h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i]
+	h.nodes[i].index = i
+	h.nodes[j].index = j
+}
+
+// ordered reports whether the nodes at i and j are in order for the level of i:
+// on a min level the node at i must not be greater, on a max level not less.
+func (h *minMax[E]) ordered(i, j int, minLevel bool) bool {
+	if minLevel {
+		return !h.less(h.nodes[j].Value, h.nodes[i].Value)
+	}
+	return !h.less(h.nodes[i].Value, h.nodes[j].Value)
+}
+
+func isMinLevel(i int) bool {
+	return bits.Len(uint(i+1))%2 == 1
+}
+
+// fix restores the heap order after the node at i has been replaced or changed.
+func (h *minMax[E]) fix(i int) {
+	if i > 0 {
+		minLevel := isMinLevel(i)
+		if parent := (i - 1) / 2; !h.ordered(parent, i, !minLevel) {
+			// The node belongs on the levels of the opposite kind. The former parent
+			// satisfies every ancestor of i but may need to sink below i's descendants.
+			h.swap(i, parent)
+			h.climb(parent, !minLevel)
+			h.down(i)
+			return
+		}
+		if i > 2 {
+			if grandparent := ((i-1)/2 - 1) / 2; !h.ordered(grandparent, i, minLevel) {
+				h.climb(i, minLevel)
+				return
+			}
+		}
+	}
+	h.down(i)
+}
+
+// climb moves the node at i up through the grandparents on levels of the same kind.
+func (h *minMax[E]) climb(i int, minLevel bool) {
+	for i > 2 {
+		grandparent := ((i-1)/2 - 1) / 2
+		if h.ordered(grandparent, i, minLevel) {
+			break
+		}
+		h.swap(i, grandparent)
+		i = grandparent
+	}
+}
+
+// down moves the node at i0 towards the leaves through levels of both kinds.
+func (h *minMax[E]) down(i0 int) {
+	n := len(h.nodes)
+	minLevel := isMinLevel(i0)
+	i := i0
+	for {
+		first := 2*i + 1
+		if first >= n {
+			return
+		}
+		// Find the extreme among children and grandchildren.
+		m := first
+		for _, c := range [...]int{first + 1, 2*first + 1, 2*first + 2, 2*first + 3, 2*first + 4} {
+			if c < n && !h.ordered(m, c, minLevel) {
+				m = c
+			}
+		}
+		if h.ordered(i, m, minLevel) {
+			return
+		}
+		h.swap(i, m)
+		if m <= first+1 {
+			// m is a child: it is on a level of the opposite kind, so the swap is final.
+			return
+		}
+		if parent := (m - 1) / 2; !h.ordered(parent, m, !minLevel) {
+			h.swap(m, parent)
+		}
+		i = m
+	}
+}
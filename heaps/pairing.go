@@ -0,0 +1,192 @@
+package heaps
+
+import "iter"
+
+// pairing is a two-pass pairing heap. Children are kept in a list starting at
+// child and linked through next; prev points to the left sibling, or to the
+// parent for the leftmost child.
+type pairing[E any] struct {
+	root *Node[E]
+	n    int
+	less func(a, b E) bool
+}
+
+// Pairing returns an empty pairing heap ordered by less.
+// Push and priority decreases through Fix take amortized constant time, which
+// suits workloads dominated by decrease-key operations.
+func Pairing[E any](less func(a, b E) bool) Backend[E] {
+	return &pairing[E]{less: less}
+}
+
+func (h *pairing[E]) Len() int {
+	return h.n
+}
+
+func (h *pairing[E]) Push(v E) *Node[E] {
+	n := &Node[E]{Value: v}
+	h.root = h.meld(h.root, n)
+	h.n++
+	return n
+}
+
+func (h *pairing[E]) Peek() *Node[E] {
+	return h.root
+}
+
+func (h *pairing[E]) Pop() *Node[E] {
+	n := h.root
+	if n == nil {
+		return nil
+	}
+	h.root = h.mergePairs(n.child)
+	n.child = nil
+	h.n--
+	return n
+}
+
+func (h *pairing[E]) Fix(n *Node[E]) {
+	if h.childLess(n) {
+		// The value increased past one of its children: detach the children
+		// and meld them back separately.
+		sub := h.mergePairs(n.child)
+		n.child = nil
+		if n == h.root {
+			h.root = n
+		} else {
+			h.cut(n)
+			h.root = h.meld(h.root, n)
+		}
+		h.root = h.meld(h.root, sub)
+		return
+	}
+	if n != h.root {
+		h.cut(n)
+		h.root = h.meld(h.root, n)
+	}
+}
+
+func (h *pairing[E]) Remove(n *Node[E]) {
+	if n == h.root {
+		h.Pop()
+		return
+	}
+	h.cut(n)
+	sub := h.mergePairs(n.child)
+	n.child = nil
+	h.root = h.meld(h.root, sub)
+	h.n--
+}
+
+func (h *pairing[E]) Clear() {
+	h.root = nil
+	h.n = 0
+}
+
+// Meld links the root of another pairing heap in constant time.
+// Nodes of other backends are pushed one by one.
+func (h *pairing[E]) Meld(other Backend[E]) {
+	if o, ok := other.(*pairing[E]); ok {
+		h.root = h.meld(h.root, o.root)
+		h.n += o.n
+		o.Clear()
+		return
+	}
+	for _, n := range take(other) {
+		h.root = h.meld(h.root, n)
+		h.n++
+	}
+}
+
+func (h *pairing[E]) All() iter.Seq[*Node[E]] {
+	return func(yield func(*Node[E]) bool) {
+		if h.root == nil {
+			return
+		}
+		stack := []*Node[E]{h.root}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(n) {
+				return
+			}
+			if n.next != nil {
+				stack = append(stack, n.next)
+			}
+			if n.child != nil {
+				stack = append(stack, n.child)
+			}
+		}
+	}
+}
+
+// meld links two detached trees and returns the new root.
+func (h *pairing[E]) meld(a, b *Node[E]) *Node[E] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if h.less(b.Value, a.Value) {
+		a, b = b, a
+	}
+	b.prev = a
+	b.next = a.child
+	if a.child != nil {
+		a.child.prev = b
+	}
+	a.child = b
+	a.prev, a.next = nil, nil
+	return a
+}
+
+// mergePairs melds a list of sibling trees into one using the standard two passes.
+func (h *pairing[E]) mergePairs(first *Node[E]) *Node[E] {
+	// First pass: meld pairs from left to right, collecting the results in
+	// reverse order through next.
+	var pairs *Node[E]
+	for first != nil {
+		a, b := first, first.next
+		if b == nil {
+			first = nil
+		} else {
+			first = b.next
+			b.prev, b.next = nil, nil
+		}
+		a.prev, a.next = nil, nil
+		m := h.meld(a, b)
+		m.next = pairs
+		pairs = m
+	}
+	// Second pass: meld the pairs from right to left.
+	var root *Node[E]
+	for pairs != nil {
+		next := pairs.next
+		pairs.next = nil
+		root = h.meld(pairs, root)
+		pairs = next
+	}
+	return root
+}
+
+// cut detaches the subtree rooted at the non-root node n.
+func (h *pairing[E]) cut(n *Node[E]) {
+	if n.prev.child == n {
+		n.prev.child = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	}
+	n.prev, n.next = nil, nil
+}
+
+func (h *pairing[E]) childLess(n *Node[E]) bool {
+	for c := n.child; c != nil; c = c.next {
+		if h.less(c.Value, n.Value) {
+			return true
+		}
+	}
+	return false
+}
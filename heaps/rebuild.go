@@ -0,0 +1,41 @@
+package heaps
+
+import "slices"
+
+// Rebuild restores the heap order of b after its less function has changed the order of any
+// number of elements at once, which Fix cannot handle. Backends created by this package are
+// rebuilt in place in linear time, and their nodes remain valid handles. Backends implemented
+// outside this package are emptied and refilled with Pop and Push, so their nodes are replaced.
+func Rebuild[E any](b Backend[E]) {
+	switch b.(type) {
+	case *dary[E], *minMax[E], *pairing[E], *fibonacci[E]:
+		// Every backend melds the nodes of an array based heap one by one or
+		// by rebuilding, without relying on their order.
+		nodes := slices.Collect(b.All())
+		b.Clear()
+		b.Meld(&dary[E]{nodes: nodes})
+		return
+	}
+	values := make([]E, 0, b.Len())
+	for b.Len() > 0 {
+		values = append(values, b.Pop().Value)
+	}
+	for _, v := range values {
+		b.Push(v)
+	}
+}
+
+// MeldUnordered moves every node of other into b like Meld, but without requiring other to be
+// ordered by b's less function. Backends created by this package insert the nodes one by one or
+// rebuild in linear time, and the nodes remain valid handles. Backends implemented outside this
+// package are melded with Meld, so they must not rely on the order of other.
+func MeldUnordered[E any](b, other Backend[E]) {
+	switch b.(type) {
+	case *dary[E], *minMax[E], *pairing[E], *fibonacci[E]:
+		nodes := slices.Collect(other.All())
+		other.Clear()
+		b.Meld(&dary[E]{nodes: nodes})
+		return
+	}
+	b.Meld(other)
+}
@@ -0,0 +1,232 @@
+package heaps
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrComparator is wrapped by the errors Validate returns when the less function
+// is not a strict weak ordering, for example because it compares NaN priorities.
+var ErrComparator = errors.New("heaps: less is not a strict weak ordering")
+
+// ErrStructure is wrapped by the errors Validate returns when the internal structure
+// of a heap is inconsistent, such as a wrong length or a node that lost track of its position.
+var ErrStructure = errors.New("heaps: corrupt structure")
+
+// OrderError reports a Node that breaks the heap order with respect to its ancestor.
+type OrderError[E any] struct {
+	Ancestor *Node[E]
+	Node     *Node[E]
+	// Max is set when Ancestor is on a max level of a min-max heap, so Node is
+	// ordered after it rather than before it.
+	Max bool
+}
+
+func (e *OrderError[E]) Error() string {
+	if e.Max {
+		return "heaps: node is ordered after its ancestor on a max level"
+	}
+	return "heaps: node is ordered before its ancestor"
+}
+
+type validator interface {
+	validate() []error
+}
+
+// Validate checks the heap order and internal structure of a backend created by this package
+// and returns every violation found, or nil if there is none. Heap order violations are reported
+// as *OrderError. Along the way the less function is tested on each node, its parent and its
+// grandparent, and any failure to behave as a strict weak ordering is reported once per kind
+// as an error wrapping ErrComparator. Validate takes linear time.
+//
+// Only the length of backends implemented outside this package is checked.
+func Validate[E any](b Backend[E]) []error {
+	if v, ok := b.(validator); ok {
+		return v.validate()
+	}
+	n := 0
+	for range b.All() {
+		n++
+	}
+	if n != b.Len() {
+		return []error{lenError(b.Len(), n)}
+	}
+	return nil
+}
+
+func lenError(want, got int) error {
+	return fmt.Errorf("%w: length is %d but %d nodes are reachable", ErrStructure, want, got)
+}
+
+// checker collects the violations found by the validate methods.
+type checker[E any] struct {
+	less       func(a, b E) bool
+	errs       []error
+	comparator map[string]bool
+}
+
+func newChecker[E any](less func(a, b E) bool) *checker[E] {
+	return &checker[E]{less: less, comparator: make(map[string]bool)}
+}
+
+func (c *checker[E]) fail(err error) {
+	c.errs = append(c.errs, err)
+}
+
+func (c *checker[E]) failComparator(reason string) {
+	if !c.comparator[reason] {
+		c.comparator[reason] = true
+		c.fail(fmt.Errorf("%w: %s", ErrComparator, reason))
+	}
+}
+
+// below checks that n is not ordered before its ancestor a, or after it if maxLevel is set,
+// and tests the less function on n, a and, if not nil, a third node g on the same path.
+func (c *checker[E]) below(g, a, n *Node[E], maxLevel bool) {
+	if c.less(n.Value, n.Value) {
+		c.failComparator("less(x, x) is true")
+	}
+	before, after := c.less(n.Value, a.Value), c.less(a.Value, n.Value)
+	switch {
+	case before && after:
+		c.failComparator("less(x, y) and less(y, x) are both true")
+	case maxLevel && after, !maxLevel && before:
+		c.fail(&OrderError[E]{Ancestor: a, Node: n, Max: maxLevel})
+	}
+	if g != nil {
+		c.triple(g.Value, a.Value, n.Value)
+	}
+}
+
+// triple tests the less function for transitivity on x, y and z.
+func (c *checker[E]) triple(x, y, z E) {
+	xy, yx := c.less(x, y), c.less(y, x)
+	yz, zy := c.less(y, z), c.less(z, y)
+	xz, zx := c.less(x, z), c.less(z, x)
+	if xy && yz && !xz || yx && zy && !zx {
+		c.failComparator("less is not transitive")
+	}
+	if !xy && !yx && !yz && !zy && (xz || zx) {
+		c.failComparator("incomparability is not transitive")
+	}
+}
+
+func (h *dary[E]) validate() []error {
+	c := newChecker(h.less)
+	for i, n := range h.nodes {
+		if n.index != i {
+			c.fail(fmt.Errorf("%w: node at position %d records index %d", ErrStructure, i, n.index))
+		}
+		if i == 0 {
+			continue
+		}
+		p := (i - 1) / h.d
+		var g *Node[E]
+		if p > 0 {
+			g = h.nodes[(p-1)/h.d]
+		}
+		c.below(g, h.nodes[p], n, false)
+	}
+	return c.errs
+}
+
+func (h *minMax[E]) validate() []error {
+	c := newChecker(h.less)
+	for i, n := range h.nodes {
+		if n.index != i {
+			c.fail(fmt.Errorf("%w: node at position %d records index %d", ErrStructure, i, n.index))
+		}
+		if i == 0 {
+			continue
+		}
+		// A node must respect its parent, on a level of the other kind, and its
+		// grandparent, on a level of its own kind; transitivity covers the rest.
+		p := (i - 1) / 2
+		c.below(nil, h.nodes[p], n, !isMinLevel(p))
+		if p > 0 {
+			c.below(h.nodes[p], h.nodes[(p-1)/2], n, !isMinLevel(i))
+		}
+	}
+	return c.errs
+}
+
+func (h *pairing[E]) validate() []error {
+	c := newChecker(h.less)
+	if h.root == nil {
+		if h.n != 0 {
+			c.fail(lenError(h.n, 0))
+		}
+		return c.errs
+	}
+	if h.root.prev != nil || h.root.next != nil {
+		c.fail(fmt.Errorf("%w: root has siblings", ErrStructure))
+	}
+	type frame struct{ n, parent *Node[E] }
+	count := 1
+	stack := []frame{{n: h.root}}
+	for len(stack) > 0 && count <= h.n {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		prev := f.n
+		for ch := f.n.child; ch != nil && count <= h.n; ch = ch.next {
+			count++
+			if ch.prev != prev {
+				c.fail(fmt.Errorf("%w: child is not linked back to its left sibling or parent", ErrStructure))
+			}
+			c.below(f.parent, f.n, ch, false)
+			stack = append(stack, frame{ch, f.n})
+			prev = ch
+		}
+	}
+	if count != h.n {
+		c.fail(lenError(h.n, count))
+	}
+	return c.errs
+}
+
+func (h *fibonacci[E]) validate() []error {
+	c := newChecker(h.less)
+	if h.min == nil {
+		if h.n != 0 {
+			c.fail(lenError(h.n, 0))
+		}
+		return c.errs
+	}
+	type frame struct{ first, parent, grandparent *Node[E] }
+	count := 0
+	stack := []frame{{first: h.min}}
+	for len(stack) > 0 && count <= h.n {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		degree := 0
+		for n := f.first; count <= h.n; {
+			count++
+			degree++
+			if n.parent != f.parent {
+				c.fail(fmt.Errorf("%w: node is not linked to its parent", ErrStructure))
+			}
+			if n.next.prev != n {
+				c.fail(fmt.Errorf("%w: sibling list is not doubly linked", ErrStructure))
+			}
+			if f.parent != nil {
+				c.below(f.grandparent, f.parent, n, false)
+			} else if n != h.min {
+				// Roots are unordered among themselves, but none may precede min.
+				c.below(nil, h.min, n, false)
+			}
+			if n.child != nil {
+				stack = append(stack, frame{n.child, n, f.parent})
+			}
+			if n = n.next; n == f.first {
+				break
+			}
+		}
+		if f.parent != nil && f.parent.degree != degree {
+			c.fail(fmt.Errorf("%w: node has degree %d but %d children", ErrStructure, f.parent.degree, degree))
+		}
+	}
+	if count != h.n {
+		c.fail(lenError(h.n, count))
+	}
+	return c.errs
+}
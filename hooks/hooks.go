@@ -0,0 +1,62 @@
+// Package hooks defines the events the priority queue packages report to hooks registered
+// with their AddHook functions, and a Stats collector built on them.
+//
+// Hooks are called synchronously after each operation, with the queue already updated.
+// They must not modify the queue they observe.
+package hooks
+
+import "fmt"
+
+// Op identifies the operation an Event reports.
+type Op int
+
+const (
+	// Enqueue reports an element added to the queue, including one moved in by merging
+	// another queue into it.
+	Enqueue Op = iota
+	// Dequeue reports an element removed from the front of the queue.
+	Dequeue
+	// Update reports an element whose priority or item changed in place,
+	// including a duplicate key replaced by Enqueue or by merging another queue into it.
+	Update
+	// Delete reports an element removed other than by Dequeue: deleted by key,
+	// evicted from a full bounded queue or dropped after expiring.
+	Delete
+	// Clear reports that every element was removed at once, by Clear or by
+	// merging the queue into another.
+	Clear
+)
+
+func (op Op) String() string {
+	switch op {
+	case Enqueue:
+		return "enqueue"
+	case Dequeue:
+		return "dequeue"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	case Clear:
+		return "clear"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Event describes an operation on a priority queue.
+type Event[T any, P any] struct {
+	Op Op
+	// Item, Priority and Sequence describe the element the operation applied to.
+	// They are zero for Clear events, and a zero Sequence means the element is not numbered.
+	Item     T
+	Priority P
+	Sequence int
+	// PrevPriority and PrevSequence describe the element before an Update.
+	PrevPriority P
+	PrevSequence int
+	// Len is the length of the queue after the operation.
+	Len int
+}
+
+// Hook is a function called with every event of the queue it is registered with.
+type Hook[T any, P any] func(Event[T, P])
@@ -0,0 +1,189 @@
+package hooks_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/mpqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClock() (func() time.Time, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestOpString(t *testing.T) {
+	assert.Equal(t, "enqueue", hooks.Enqueue.String())
+	assert.Equal(t, "dequeue", hooks.Dequeue.String())
+	assert.Equal(t, "update", hooks.Update.String())
+	assert.Equal(t, "delete", hooks.Delete.String())
+	assert.Equal(t, "clear", hooks.Clear.String())
+	assert.Equal(t, "Op(9)", hooks.Op(9).String())
+}
+
+func TestStatsCountsAndDepth(t *testing.T) {
+	stats := hooks.NewStats[string, int]()
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 1, Len: 1})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 2, Len: 2})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 3, Len: 3})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Update, Sequence: 4, PrevSequence: 3, Len: 3})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Delete, Sequence: 2, Len: 2})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 1, Len: 1})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Clear, Len: 0})
+
+	s := stats.Summary()
+	assert.Equal(t, 3, s.Enqueued)
+	assert.Equal(t, 1, s.Dequeued)
+	assert.Equal(t, 1, s.Updated)
+	assert.Equal(t, 1, s.Deleted)
+	assert.Equal(t, 1, s.Cleared)
+	assert.Equal(t, 0, s.Depth)
+	assert.Equal(t, 3, s.MaxDepth)
+}
+
+func TestStatsWaits(t *testing.T) {
+	now, advance := newClock()
+	stats := hooks.NewStatsWithClock[string, int](now)
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 1, Len: 1})
+	advance(time.Second)
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 2, Len: 2})
+	advance(time.Second)
+	// An update renumbers the element but keeps its enqueue time.
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Update, Sequence: 3, PrevSequence: 1, Len: 2})
+	advance(time.Second)
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 3, Len: 1})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 2, Len: 0})
+
+	s := stats.Summary()
+	assert.Equal(t, 2, s.Waits)
+	assert.Equal(t, 5*time.Second, s.TotalWait)
+	assert.Equal(t, 3*time.Second, s.MaxWait)
+	assert.Equal(t, 2500*time.Millisecond, s.MeanWait())
+}
+
+func TestStatsForgetsRemovedElements(t *testing.T) {
+	now, _ := newClock()
+	stats := hooks.NewStatsWithClock[string, int](now)
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 1, Len: 1})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 2, Len: 2})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Delete, Sequence: 1, Len: 1})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Clear, Len: 0})
+	// Sequence numbers restart after a clear; stale entries must not be matched.
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 1, Len: 0})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 2, Len: 0})
+
+	s := stats.Summary()
+	assert.Equal(t, 0, s.Waits)
+	assert.Equal(t, time.Duration(0), s.MeanWait())
+}
+
+func TestStatsWithoutSequences(t *testing.T) {
+	stats := hooks.NewStats[int, int]()
+	stats.Observe(hooks.Event[int, int]{Op: hooks.Enqueue, Item: 1, Priority: 1, Len: 1})
+	stats.Observe(hooks.Event[int, int]{Op: hooks.Dequeue, Item: 1, Priority: 1, Len: 0})
+	s := stats.Summary()
+	assert.Equal(t, 1, s.Dequeued)
+	assert.Equal(t, 0, s.Waits)
+}
+
+func TestStatsReset(t *testing.T) {
+	now, advance := newClock()
+	stats := hooks.NewStatsWithClock[string, int](now)
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 1, Len: 1})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Enqueue, Sequence: 2, Len: 2})
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 1, Len: 1})
+	stats.Reset()
+	assert.Equal(t, hooks.Summary{Depth: 1, MaxDepth: 1}, stats.Summary())
+
+	advance(time.Second)
+	stats.Observe(hooks.Event[string, int]{Op: hooks.Dequeue, Sequence: 2, Len: 0})
+	s := stats.Summary()
+	assert.Equal(t, 1, s.Waits)
+	assert.Equal(t, time.Second, s.TotalWait)
+}
+
+func TestStatsWithQueue(t *testing.T) {
+	now, advance := newClock()
+	stats := hooks.NewStatsWithClock[string, int](now)
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.AddHook(pq, stats.Observe)
+
+	mpqs.Enqueue(pq, "a", 2)
+	advance(time.Second)
+	mpqs.Enqueue(pq, "b", 1)
+	advance(time.Second)
+	mpqs.Dequeue(pq)
+	mpqs.Dequeue(pq)
+
+	s := stats.Summary()
+	assert.Equal(t, 2, s.Enqueued)
+	assert.Equal(t, 2, s.Dequeued)
+	assert.Equal(t, 0, s.Depth)
+	assert.Equal(t, 2, s.MaxDepth)
+	assert.Equal(t, 3*time.Second, s.TotalWait)
+	assert.Equal(t, 2*time.Second, s.MaxWait)
+}
+
+func TestStatsAcrossMerge(t *testing.T) {
+	now, advance := newClock()
+	stats := hooks.NewStatsWithClock[string, int](now)
+	dst := mpqs.New(mpqs.StableMinFirst[string, int])
+	src := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.AddHook(dst, stats.Observe)
+
+	mpqs.Enqueue(dst, "a", 2)
+	mpqs.Enqueue(src, "b", 1)
+	mpqs.Enqueue(src, "c", 3)
+	advance(time.Second)
+	mpqs.Merge(dst, src)
+	assert.Equal(t, 3, stats.Summary().Depth)
+
+	advance(time.Second)
+	for range mpqs.Drain(dst) {
+	}
+
+	// Moved elements are timed from the merge and matched by their new sequence numbers.
+	s := stats.Summary()
+	assert.Equal(t, 3, s.Enqueued)
+	assert.Equal(t, 3, s.Dequeued)
+	assert.Equal(t, 0, s.Depth)
+	assert.Equal(t, 3, s.MaxDepth)
+	assert.Equal(t, 3, s.Waits)
+	assert.Equal(t, 4*time.Second, s.TotalWait)
+	assert.Equal(t, 2*time.Second, s.MaxWait)
+}
+
+func ExampleStats() {
+	stats := hooks.NewStats[string, int]()
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.AddHook(pq, stats.Observe)
+
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 3)
+	mpqs.Dequeue(pq)
+
+	s := stats.Summary()
+	fmt.Println(s.Enqueued, s.Dequeued, s.Depth, s.MaxDepth, s.Waits)
+	// Output: 3 1 2 3 1
+}
+
+func ExampleEvent() {
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.AddHook(pq, func(e hooks.Event[string, int]) {
+		fmt.Println(e.Op, e.Item, e.Priority, e.Len)
+	})
+
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Dequeue(pq)
+	mpqs.Clear(pq)
+	// Output:
+	// enqueue a 2 1
+	// enqueue b 1 2
+	// dequeue b 1 1
+	// clear  0 0
+}
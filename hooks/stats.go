@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats collects operation counts, queue depth and wait times from the events of a queue.
+// Register its Observe method as a hook. Stats is safe for concurrent use, so it can be
+// read while the queue is in use elsewhere.
+//
+// Wait times are measured from Enqueue to Dequeue by sequence number, so they are not
+// collected for packages without sequence numbers. Updates keep the original enqueue time,
+// while elements moved by Merge are timed from the merge.
+type Stats[T any, P any] struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	enqueued map[int]time.Time
+	summary  Summary
+}
+
+// Summary is a point-in-time copy of the statistics of a Stats.
+type Summary struct {
+	Enqueued int
+	Dequeued int
+	Updated  int
+	Deleted  int
+	Cleared  int
+
+	// Depth is the current length of the queue and MaxDepth the largest length seen.
+	Depth    int
+	MaxDepth int
+
+	// Waits is the number of dequeued elements whose wait time was measured.
+	Waits     int
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// MeanWait returns the average time measured elements waited between Enqueue and Dequeue.
+func (s Summary) MeanWait() time.Duration {
+	if s.Waits == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Waits)
+}
+
+// NewStats creates a Stats collector that reads time from time.Now.
+func NewStats[T any, P any]() *Stats[T, P] {
+	return NewStatsWithClock[T, P](time.Now)
+}
+
+// NewStatsWithClock creates a Stats collector that reads time from now.
+func NewStatsWithClock[T any, P any](now func() time.Time) *Stats[T, P] {
+	return &Stats[T, P]{
+		now:      now,
+		enqueued: make(map[int]time.Time),
+	}
+}
+
+// Observe records an event. Pass it to the AddHook function of a queue package.
+func (s *Stats[T, P]) Observe(e Event[T, P]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Op {
+	case Enqueue:
+		s.summary.Enqueued++
+		if e.Sequence != 0 {
+			s.enqueued[e.Sequence] = s.now()
+		}
+	case Dequeue:
+		s.summary.Dequeued++
+		if at, ok := s.enqueued[e.Sequence]; ok {
+			wait := s.now().Sub(at)
+			s.summary.Waits++
+			s.summary.TotalWait += wait
+			s.summary.MaxWait = max(s.summary.MaxWait, wait)
+			delete(s.enqueued, e.Sequence)
+		}
+	case Update:
+		s.summary.Updated++
+		if at, ok := s.enqueued[e.PrevSequence]; ok && e.PrevSequence != e.Sequence {
+			delete(s.enqueued, e.PrevSequence)
+			s.enqueued[e.Sequence] = at
+		}
+	case Delete:
+		s.summary.Deleted++
+		delete(s.enqueued, e.Sequence)
+	case Clear:
+		s.summary.Cleared++
+		clear(s.enqueued)
+	}
+	s.summary.Depth = e.Len
+	s.summary.MaxDepth = max(s.summary.MaxDepth, e.Len)
+}
+
+// Summary returns a copy of the statistics collected so far.
+func (s *Stats[T, P]) Summary() Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
+
+// Reset clears the counters, the maximum depth and the wait times, keeping the current
+// depth and the enqueue times of queued elements.
+func (s *Stats[T, P]) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary = Summary{Depth: s.summary.Depth, MaxDepth: s.summary.Depth}
+}
@@ -0,0 +1,12 @@
+// Package comparator tells whether the queues being merged order their elements the same way.
+package comparator
+
+import "github.com/byExist/priorityqueues/snapshot"
+
+// Same reports whether a and b are known to be the same comparator: they have the same
+// snapshot.Identity and it names a top-level function, such as mpqs.StableMinFirst,
+// rather than a closure like the ones returned by mpqs.MinFirstFunc.
+func Same(a, b any) bool {
+	id := snapshot.Identity(a)
+	return id != "" && id == snapshot.Identity(b)
+}
@@ -0,0 +1,40 @@
+// Package debug holds the invariant checks the queue packages run when built with the pqdebug tag:
+//
+//	go test -tags pqdebug ./...
+//
+// In that mode every mutating queue operation validates the queue afterwards and every
+// comparison tests the comparator, panicking on the first violation. The checks cost at
+// least linear time per operation, so the tag is meant for tests and debugging only.
+package debug
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/byExist/priorityqueues/heaps"
+)
+
+// Less returns less unchanged unless Enabled is set. Otherwise it returns a function that
+// panics when less reports an element less than itself, or two elements less than each other.
+func Less[E any](less func(a, b E) bool) func(a, b E) bool {
+	if !Enabled {
+		return less
+	}
+	return func(a, b E) bool {
+		if less(a, a) {
+			panic(fmt.Errorf("%w: less(x, x) is true", heaps.ErrComparator))
+		}
+		r := less(a, b)
+		if r && less(b, a) {
+			panic(fmt.Errorf("%w: less(x, y) and less(y, x) are both true", heaps.ErrComparator))
+		}
+		return r
+	}
+}
+
+// Check panics with the joined errors if errs is not empty.
+func Check(errs []error) {
+	if len(errs) > 0 {
+		panic(errors.Join(errs...))
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !pqdebug
+
+package debug
+
+// Enabled reports whether the package was built with the pqdebug tag.
+const Enabled = false
@@ -0,0 +1,56 @@
+package nan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// JSON wraps a value so that NaN and infinite floats, which encoding/json rejects, are encoded
+// as the strings "NaN", "+Inf" and "-Inf" and decoded back. Other values, and types with their
+// own JSON methods, are encoded as usual.
+type JSON[V any] struct {
+	V V
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j JSON[V]) MarshalJSON() ([]byte, error) {
+	if v := reflect.ValueOf(&j.V).Elem(); plainFloat(v.Type()) {
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return json.Marshal(j.V)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *JSON[V]) UnmarshalJSON(data []byte) error {
+	v := reflect.ValueOf(&j.V).Elem()
+	if !plainFloat(v.Type()) || len(data) == 0 || data[0] != '"' {
+		return json.Unmarshal(data, &j.V)
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return fmt.Errorf("json: invalid non-finite float %q", s)
+	}
+	v.SetFloat(f)
+	return nil
+}
+
+var (
+	marshaler   = reflect.TypeFor[json.Marshaler]()
+	unmarshaler = reflect.TypeFor[json.Unmarshaler]()
+)
+
+// plainFloat reports whether t is a floating-point type without its own JSON methods.
+func plainFloat(t reflect.Type) bool {
+	k := t.Kind()
+	return (k == reflect.Float32 || k == reflect.Float64) &&
+		!t.Implements(marshaler) && !reflect.PointerTo(t).Implements(unmarshaler)
+}
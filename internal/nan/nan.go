@@ -0,0 +1,63 @@
+// Package nan implements the NaN policies of the queue packages for floating-point priorities.
+package nan
+
+import "reflect"
+
+// Order determines where elements with NaN priorities are placed.
+type Order int
+
+const (
+	// Unordered leaves NaN priorities to the less function.
+	Unordered Order = iota
+	// First places NaN priorities before all others.
+	First
+	// Last places NaN priorities after all others.
+	Last
+)
+
+// Func returns a function that reports whether a value of type V is NaN,
+// or nil if V is not a floating-point type.
+func Func[V any]() func(V) bool {
+	var zero V
+	switch any(zero).(type) {
+	case float64:
+		return func(v V) bool {
+			f := any(v).(float64)
+			return f != f
+		}
+	case float32:
+		return func(v V) bool {
+			f := any(v).(float32)
+			return f != f
+		}
+	}
+	t := reflect.TypeFor[V]()
+	if k := t.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+		return nil
+	}
+	return func(v V) bool {
+		f := reflect.ValueOf(v).Float()
+		return f != f
+	}
+}
+
+// Less returns less with the elements for which isNaN reports true placed according to *order,
+// which is read on every call so it can be changed after the heap is built. Elements that are
+// both NaN are ordered by tie. If isNaN is nil, less is returned unchanged.
+func Less[E any](less func(a, b E) bool, isNaN func(E) bool, tie func(a, b E) bool, order *Order) func(a, b E) bool {
+	if isNaN == nil {
+		return less
+	}
+	return func(a, b E) bool {
+		if *order == Unordered {
+			return less(a, b)
+		}
+		switch an, bn := isNaN(a), isNaN(b); {
+		case an && bn:
+			return tie(a, b)
+		case an != bn:
+			return an == (*order == First)
+		}
+		return less(a, b)
+	}
+}
@@ -0,0 +1,448 @@
+package kdmpqs
+
+import (
+	"cmp"
+	"errors"
+	"iter"
+	"maps"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+// ErrDuplicateKey is returned by Enqueue when an item with the same key is already
+// in the queue and the duplicate policy is Reject.
+var ErrDuplicateKey = errors.New("kdmpqs: duplicate key")
+
+// DuplicatePolicy determines how Enqueue handles an item whose key is already in the queue.
+type DuplicatePolicy int
+
+const (
+	// Upsert replaces the queued item and its priority, as Update does. This is the default.
+	Upsert DuplicatePolicy = iota
+	// Reject leaves the queue unchanged and makes Enqueue return ErrDuplicateKey.
+	Reject
+	// KeepLower keeps whichever of the queued and the new element is closer to the min end.
+	KeepLower
+	// KeepHigher keeps whichever of the queued and the new element is closer to the max end.
+	KeepHigher
+)
+
+// Elem represents an element in the priority queue with an item, its priority, and a sequence number.
+type Elem[T any, P any] struct {
+	item T
+	prio P
+	seq  int
+}
+
+// Item returns the item stored in the element.
+func (e Elem[T, P]) Item() T {
+	return e.item
+}
+
+// Priority returns the priority of the element.
+func (e Elem[T, P]) Priority() P {
+	return e.prio
+}
+
+// Sequence returns the sequence number of the element.
+func (e Elem[T, P]) Sequence() int {
+	return e.seq
+}
+
+type heapImpl[K comparable, T any, P any] struct {
+	backend heaps.DoubleEnded[Elem[T, P]]
+	lookup  map[K]*heaps.Node[Elem[T, P]]
+
+	keyFunc  func(T) K
+	lessFunc func(i, j Elem[T, P]) bool
+	// less is lessFunc with NaN priorities placed by the NaN policy.
+	less func(i, j Elem[T, P]) bool
+}
+
+func (h *heapImpl[K, T, P]) Len() int {
+	return h.backend.Len()
+}
+
+func (h *heapImpl[K, T, P]) push(elem Elem[T, P]) {
+	h.lookup[h.keyFunc(elem.item)] = h.backend.Push(elem)
+}
+
+func (h *heapImpl[K, T, P]) popMin() Elem[T, P] {
+	node := h.backend.Pop()
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	return node.Value
+}
+
+func (h *heapImpl[K, T, P]) popMax() Elem[T, P] {
+	node := h.backend.PopMax()
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	return node.Value
+}
+
+func (h *heapImpl[K, T, P]) fix(node *heaps.Node[Elem[T, P]], elem Elem[T, P]) {
+	node.Value = elem
+	h.backend.Fix(node)
+}
+
+func (h *heapImpl[K, T, P]) remove(node *heaps.Node[Elem[T, P]]) Elem[T, P] {
+	h.backend.Remove(node)
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	return node.Value
+}
+
+func counter() func() int {
+	return counterFrom(0)
+}
+
+// counterFrom returns a counter whose first value is i+1.
+func counterFrom(i int) func() int {
+	return func() int {
+		i++
+		return i
+	}
+}
+
+// PriorityQueue implements a double-ended priority queue with efficient update, delete, and lookup operations.
+type PriorityQueue[K comparable, T any, P any] struct {
+	heap      *heapImpl[K, T, P]
+	counter   func() int
+	dupPolicy DuplicatePolicy
+	hooks     []hooks.Hook[T, P]
+
+	nanPolicy NaNPolicy
+	nanOrder  nan.Order
+	isNaN     func(P) bool
+}
+
+// Ascending compares two elements and returns true if x has a lower priority value than y.
+func Ascending[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
+	return x.prio < y.prio
+}
+
+// StableAscending compares two elements and returns true if x has a lower priority value than y,
+// or if priorities are equal, if x was inserted earlier (lower sequence number).
+func StableAscending[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
+	if x.prio == y.prio {
+		return x.seq < y.seq
+	}
+	return x.prio < y.prio
+}
+
+// AscendingFunc returns a comparator that orders elements by ascending priority as determined
+// by compare, such as time.Time.Compare, for priority types that are not cmp.Ordered.
+func AscendingFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) < 0
+	}
+}
+
+// StableAscendingFunc is like AscendingFunc, but elements with equal priorities are ordered by insertion.
+func StableAscendingFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c < 0
+		}
+		return x.seq < y.seq
+	}
+}
+
+// New creates a new PriorityQueue with the provided less and key functions.
+// The lessFunc defines the order from the min end to the max end: it should return true if x sorts before y.
+func New[K comparable, T any, P any](
+	lessFunc func(x, y Elem[T, P]) bool,
+	keyFunc func(T) K,
+) *PriorityQueue[K, T, P] {
+	pq := &PriorityQueue[K, T, P]{
+		counter: counter(),
+		isNaN:   nan.Func[P](),
+	}
+	less := nanLess(pq, lessFunc)
+	pq.heap = &heapImpl[K, T, P]{
+		backend:  heaps.MinMax(debug.Less(less)),
+		lookup:   make(map[K]*heaps.Node[Elem[T, P]]),
+		lessFunc: lessFunc,
+		less:     less,
+		keyFunc:  keyFunc,
+	}
+	return pq
+}
+
+// SetDuplicatePolicy sets how Enqueue handles items whose key is already in the queue.
+func SetDuplicatePolicy[K comparable, T any, P any](pq *PriorityQueue[K, T, P], policy DuplicatePolicy) {
+	pq.dupPolicy = policy
+}
+
+// Clear removes all elements from the priority queue.
+func Clear[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	pq.heap.backend.Clear()
+	pq.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]])
+	pq.counter = counter()
+	emit(pq, hooks.Clear, Elem[T, P]{})
+}
+
+// Enqueue inserts a new item with the given priority into the priority queue.
+// If an item with the same key is already queued, the queue's DuplicatePolicy decides
+// which one is kept; ErrDuplicateKey is returned only under the Reject policy.
+// ErrNaN is returned if prio is NaN and the NaN policy is RejectNaN.
+func Enqueue[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, prio P) error {
+	if rejected(pq, prio) {
+		return ErrNaN
+	}
+	elem := Elem[T, P]{
+		item: item,
+		prio: prio,
+		seq:  pq.counter(),
+	}
+	node, exists := pq.heap.lookup[pq.heap.keyFunc(item)]
+	if !exists {
+		pq.heap.push(elem)
+		emit(pq, hooks.Enqueue, elem)
+		return nil
+	}
+	if pq.dupPolicy == Reject {
+		return ErrDuplicateKey
+	}
+	if replaces(pq, node.Value, elem) {
+		prev := node.Value
+		pq.heap.fix(node, elem)
+		emitUpdate(pq, hooks.Update, elem, prev)
+	}
+	return nil
+}
+
+// replaces reports whether elem should take the place of the queued element with the same key.
+func replaces[K comparable, T any, P any](pq *PriorityQueue[K, T, P], queued, elem Elem[T, P]) bool {
+	switch pq.dupPolicy {
+	case KeepLower:
+		return pq.heap.less(elem, queued)
+	case KeepHigher:
+		return pq.heap.less(queued, elem)
+	}
+	return true
+}
+
+// PeekMin returns the item at the min end without removing it.
+func PeekMin[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.heap.backend.Peek().Value.item, true
+}
+
+// PeekMax returns the item at the max end without removing it.
+func PeekMax[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.heap.backend.PeekMax().Value.item, true
+}
+
+// DequeueMin removes and returns the item at the min end.
+func DequeueMin[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.popMin()
+	emit(pq, hooks.Dequeue, elem)
+	return elem.item, true
+}
+
+// DequeueMax removes and returns the item at the max end.
+func DequeueMax[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
+	if pq.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.popMax()
+	emit(pq, hooks.Dequeue, elem)
+	return elem.item, true
+}
+
+// Update replaces an existing item and changes its priority.
+// Returns true if the item exists and was successfully updated, and false if newPrio is rejected by the NaN policy.
+func Update[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, newPrio P) bool {
+	node, exists := pq.heap.lookup[pq.heap.keyFunc(item)]
+	if !exists || rejected(pq, newPrio) {
+		return false
+	}
+	elem := Elem[T, P]{
+		item: item,
+		prio: newPrio,
+		seq:  pq.counter(),
+	}
+	prev := node.Value
+	pq.heap.fix(node, elem)
+	emitUpdate(pq, hooks.Update, elem, prev)
+	return true
+}
+
+// Delete removes an item identified by its key from the priority queue.
+// Returns true if the item existed and was successfully removed.
+func Delete[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
+	_, ok := DeleteByKey(pq, pq.heap.keyFunc(item))
+	return ok
+}
+
+// Len returns the number of items currently in the priority queue.
+func Len[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) int {
+	return pq.heap.Len()
+}
+
+// Contains returns true if the queue contains an item identified by its key.
+func Contains[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
+	return ContainsKey(pq, pq.heap.keyFunc(item))
+}
+
+// ContainsKey returns true if the queue contains an item with the given key.
+func ContainsKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) bool {
+	_, exists := pq.heap.lookup[key]
+	return exists
+}
+
+// GetByKey returns the stored item identified by key without removing it.
+// The boolean return indicates whether the key exists.
+func GetByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (T, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	return node.Value.item, true
+}
+
+// PriorityOf returns the stored priority of the item identified by key.
+// The boolean return indicates whether the key exists.
+func PriorityOf[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (P, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero P
+		return zero, false
+	}
+	return node.Value.prio, true
+}
+
+// UpdateByKey changes the priority of the item identified by key, keeping the stored item.
+// Returns true if the key exists and the item was successfully updated, and false if newPrio is rejected
+// by the NaN policy.
+func UpdateByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K, newPrio P) bool {
+	node, exists := pq.heap.lookup[key]
+	if !exists || rejected(pq, newPrio) {
+		return false
+	}
+	elem := Elem[T, P]{
+		item: node.Value.item,
+		prio: newPrio,
+		seq:  pq.counter(),
+	}
+	prev := node.Value
+	pq.heap.fix(node, elem)
+	emitUpdate(pq, hooks.Update, elem, prev)
+	return true
+}
+
+// DeleteByKey removes the item identified by key from the priority queue and returns it.
+// The boolean return indicates whether the key existed and the item was removed.
+func DeleteByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (T, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.remove(node)
+	emit(pq, hooks.Delete, elem)
+	return elem.item, true
+}
+
+// Merge moves all elements of src into dst and leaves src empty. Keys in both queues are resolved by
+// dst's DuplicatePolicy; under Reject, Merge returns ErrDuplicateKey and leaves both queues unchanged.
+func Merge[K comparable, T any, P any](dst, src *PriorityQueue[K, T, P]) error {
+	if dst == src {
+		return nil
+	}
+	if dst.dupPolicy == Reject {
+		for key := range src.heap.lookup {
+			if _, exists := dst.heap.lookup[key]; exists {
+				return ErrDuplicateKey
+			}
+		}
+	}
+	// Order src by dst's NaN policy while it is moved, and restore its own policy afterwards.
+	if policy := src.nanPolicy; policy != dst.nanPolicy {
+		SetNaNPolicy(src, dst.nanPolicy)
+		defer SetNaNPolicy(src, policy)
+	}
+	// Renumber src to follow dst, so stable comparators put dst's elements first on ties.
+	// All of src is renumbered before any of it is removed, so its heap stays ordered throughout.
+	base, last := dst.counter(), src.counter()
+	for node := range src.heap.backend.All() {
+		node.Value.seq += base
+	}
+	// Elements of dst replaced by ones from src are reported as updated, like an upsert.
+	replaced := make(map[K]Elem[T, P])
+	for key, node := range src.heap.lookup {
+		queued, exists := dst.heap.lookup[key]
+		if !exists {
+			continue
+		}
+		if replaces(dst, queued.Value, node.Value) {
+			replaced[key] = dst.heap.remove(queued)
+		} else {
+			src.heap.remove(node)
+		}
+	}
+	// Elements moved into dst are reported there once the meld is done.
+	var moved []Elem[T, P]
+	if len(dst.hooks) > 0 {
+		for node := range src.heap.backend.All() {
+			moved = append(moved, node.Value)
+		}
+	}
+	// The combined min-max heap is rebuilt in linear time.
+	dst.heap.backend.Meld(src.heap.backend)
+	maps.Copy(dst.heap.lookup, src.heap.lookup)
+	src.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]])
+	dst.counter = counterFrom(base + last)
+	src.counter = counter()
+	emit(src, hooks.Clear, Elem[T, P]{})
+	for _, elem := range moved {
+		if prev, ok := replaced[dst.heap.keyFunc(elem.item)]; ok {
+			emitUpdate(dst, hooks.Update, elem, prev)
+		} else {
+			emit(dst, hooks.Enqueue, elem)
+		}
+	}
+	check(dst)
+	return nil
+}
+
+// All returns an iterator over the items and priorities currently in the priority queue.
+// Elements are yielded in unspecified order and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func All[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for node := range pq.heap.backend.All() {
+			if !yield(node.Value.item, node.Value.prio) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the items currently in the priority queue.
+// Keys are yielded in unspecified order and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func Keys[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for node := range pq.heap.backend.All() {
+			if !yield(pq.heap.keyFunc(node.Value.item)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package kdmpqs_test
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/kdmpqs"
+	"github.com/stretchr/testify/assert"
+)
+
+type Order struct {
+	ID    string
+	Price int
+}
+
+func newBook() *kdmpqs.PriorityQueue[string, *Order, int] {
+	return kdmpqs.New(
+		kdmpqs.StableAscending[*Order, int],
+		func(o *Order) string { return o.ID },
+	)
+}
+
+func TestNew(t *testing.T) {
+	q := newBook()
+	assert.NotNil(t, q)
+	assert.Equal(t, 0, kdmpqs.Len(q))
+}
+
+func TestClear(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 1)
+	kdmpqs.Clear(q)
+	assert.Equal(t, 0, kdmpqs.Len(q))
+	assert.False(t, kdmpqs.ContainsKey(q, "a"))
+}
+
+func TestPeekAndDequeueBothEnds(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 30)
+	kdmpqs.Enqueue(q, &Order{ID: "c"}, 20)
+
+	minItem, ok := kdmpqs.PeekMin(q)
+	assert.True(t, ok)
+	assert.Equal(t, "a", minItem.ID)
+	maxItem, ok := kdmpqs.PeekMax(q)
+	assert.True(t, ok)
+	assert.Equal(t, "b", maxItem.ID)
+
+	maxItem, _ = kdmpqs.DequeueMax(q)
+	assert.Equal(t, "b", maxItem.ID)
+	assert.False(t, kdmpqs.ContainsKey(q, "b"))
+	minItem, _ = kdmpqs.DequeueMin(q)
+	assert.Equal(t, "a", minItem.ID)
+	assert.False(t, kdmpqs.ContainsKey(q, "a"))
+	assert.Equal(t, 1, kdmpqs.Len(q))
+}
+
+func TestFuncOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	q := kdmpqs.New(kdmpqs.StableAscendingFunc[*Order](time.Time.Compare), func(o *Order) string { return o.ID })
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, base.Add(time.Hour))
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, base)
+	kdmpqs.Enqueue(q, &Order{ID: "c"}, base.Add(time.Hour))
+	assert.True(t, kdmpqs.UpdateByKey(q, "a", base.Add(2*time.Hour)))
+
+	minItem, _ := kdmpqs.DequeueMin(q)
+	assert.Equal(t, "b", minItem.ID)
+	maxItem, _ := kdmpqs.DequeueMax(q)
+	assert.Equal(t, "a", maxItem.ID)
+
+	q = kdmpqs.New(kdmpqs.AscendingFunc[*Order](time.Time.Compare), func(o *Order) string { return o.ID })
+	kdmpqs.Enqueue(q, &Order{ID: "late"}, base.Add(time.Hour))
+	kdmpqs.Enqueue(q, &Order{ID: "early"}, base)
+	minItem, _ = kdmpqs.PeekMin(q)
+	assert.Equal(t, "early", minItem.ID)
+}
+
+func TestElemAccessors(t *testing.T) {
+	var seen []string
+	q := kdmpqs.New(
+		func(x, y kdmpqs.Elem[*Order, int]) bool {
+			seen = append(seen, fmt.Sprintf("%s:%d:%d", x.Item().ID, x.Priority(), x.Sequence()))
+			return x.Priority() < y.Priority()
+		},
+		func(o *Order) string { return o.ID },
+	)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 2)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 1)
+	assert.Contains(t, seen, "b:1:2")
+}
+
+func TestEmpty(t *testing.T) {
+	q := newBook()
+	_, ok := kdmpqs.PeekMin(q)
+	assert.False(t, ok)
+	_, ok = kdmpqs.PeekMax(q)
+	assert.False(t, ok)
+	_, ok = kdmpqs.DequeueMin(q)
+	assert.False(t, ok)
+	_, ok = kdmpqs.DequeueMax(q)
+	assert.False(t, ok)
+}
+
+func TestUpdate(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a", Price: 1}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b", Price: 2}, 20)
+
+	assert.True(t, kdmpqs.Update(q, &Order{ID: "a", Price: 3}, 30))
+	assert.False(t, kdmpqs.Update(q, &Order{ID: "x"}, 1))
+
+	maxItem, _ := kdmpqs.PeekMax(q)
+	assert.Equal(t, "a", maxItem.ID)
+	assert.Equal(t, 3, maxItem.Price)
+	minItem, _ := kdmpqs.PeekMin(q)
+	assert.Equal(t, "b", minItem.ID)
+}
+
+func TestDelete(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 20)
+
+	assert.True(t, kdmpqs.Delete(q, &Order{ID: "b"}))
+	assert.False(t, kdmpqs.Delete(q, &Order{ID: "b"}))
+	maxItem, _ := kdmpqs.PeekMax(q)
+	assert.Equal(t, "a", maxItem.ID)
+}
+
+func TestContains(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	assert.True(t, kdmpqs.Contains(q, &Order{ID: "a"}))
+	assert.False(t, kdmpqs.Contains(q, &Order{ID: "b"}))
+}
+
+func TestEnqueueDuplicateUpsert(t *testing.T) {
+	q := newBook()
+	assert.NoError(t, kdmpqs.Enqueue(q, &Order{ID: "a", Price: 1}, 10))
+	assert.NoError(t, kdmpqs.Enqueue(q, &Order{ID: "a", Price: 2}, 5))
+	assert.Equal(t, 1, kdmpqs.Len(q))
+	prio, _ := kdmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 5, prio)
+}
+
+func TestEnqueueDuplicateReject(t *testing.T) {
+	q := newBook()
+	kdmpqs.SetDuplicatePolicy(q, kdmpqs.Reject)
+	assert.NoError(t, kdmpqs.Enqueue(q, &Order{ID: "a"}, 10))
+	assert.ErrorIs(t, kdmpqs.Enqueue(q, &Order{ID: "a"}, 5), kdmpqs.ErrDuplicateKey)
+	prio, _ := kdmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 10, prio)
+}
+
+func TestEnqueueDuplicateKeepLower(t *testing.T) {
+	q := newBook()
+	kdmpqs.SetDuplicatePolicy(q, kdmpqs.KeepLower)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 20)
+	prio, _ := kdmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 10, prio)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 5)
+	prio, _ = kdmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 5, prio)
+}
+
+func TestEnqueueDuplicateKeepHigher(t *testing.T) {
+	q := newBook()
+	kdmpqs.SetDuplicatePolicy(q, kdmpqs.KeepHigher)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 5)
+	prio, _ := kdmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 10, prio)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 20)
+	prio, _ = kdmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 20, prio)
+}
+
+func TestGetByKey(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a", Price: 7}, 10)
+	item, ok := kdmpqs.GetByKey(q, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 7, item.Price)
+	_, ok = kdmpqs.GetByKey(q, "b")
+	assert.False(t, ok)
+}
+
+func TestUpdateByKey(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 20)
+	assert.True(t, kdmpqs.UpdateByKey(q, "b", 1))
+	assert.False(t, kdmpqs.UpdateByKey(q, "x", 1))
+	minItem, _ := kdmpqs.PeekMin(q)
+	assert.Equal(t, "b", minItem.ID)
+	maxItem, _ := kdmpqs.PeekMax(q)
+	assert.Equal(t, "a", maxItem.ID)
+}
+
+func TestDeleteByKey(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	item, ok := kdmpqs.DeleteByKey(q, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", item.ID)
+	_, ok = kdmpqs.DeleteByKey(q, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, kdmpqs.Len(q))
+}
+
+func TestAllAndKeys(t *testing.T) {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 20)
+
+	prios := map[string]int{}
+	for item, prio := range kdmpqs.All(q) {
+		prios[item.ID] = prio
+	}
+	assert.Equal(t, map[string]int{"a": 10, "b": 20}, prios)
+
+	var keys []string
+	for key := range kdmpqs.Keys(q) {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+}
+
+func TestMerge(t *testing.T) {
+	dst, src := newBook(), newBook()
+	kdmpqs.Enqueue(dst, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(src, &Order{ID: "b"}, 30)
+	kdmpqs.Enqueue(src, &Order{ID: "c"}, 5)
+
+	assert.NoError(t, kdmpqs.Merge(dst, src))
+	assert.Equal(t, 0, kdmpqs.Len(src))
+	minItem, _ := kdmpqs.PeekMin(dst)
+	maxItem, _ := kdmpqs.PeekMax(dst)
+	assert.Equal(t, "c", minItem.ID)
+	assert.Equal(t, "b", maxItem.ID)
+	assert.True(t, kdmpqs.UpdateByKey(dst, "b", 1))
+	minItem, _ = kdmpqs.PeekMin(dst)
+	assert.Equal(t, "b", minItem.ID)
+}
+
+func TestMergeCollisions(t *testing.T) {
+	dst, src := newBook(), newBook()
+	kdmpqs.SetDuplicatePolicy(dst, kdmpqs.KeepHigher)
+	kdmpqs.Enqueue(dst, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(src, &Order{ID: "a"}, 5)
+	assert.NoError(t, kdmpqs.Merge(dst, src))
+	prio, _ := kdmpqs.PriorityOf(dst, "a")
+	assert.Equal(t, 10, prio)
+	assert.Equal(t, 1, kdmpqs.Len(dst))
+
+	src = newBook()
+	kdmpqs.Enqueue(src, &Order{ID: "a"}, 1)
+	kdmpqs.SetDuplicatePolicy(dst, kdmpqs.Reject)
+	assert.ErrorIs(t, kdmpqs.Merge(dst, src), kdmpqs.ErrDuplicateKey)
+	assert.Equal(t, 1, kdmpqs.Len(src))
+}
+
+func TestMergeRandomized(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, policy := range []kdmpqs.DuplicatePolicy{kdmpqs.Upsert, kdmpqs.KeepLower, kdmpqs.KeepHigher} {
+		for range 200 {
+			dst, src := newBook(), newBook()
+			keys := make(map[string]bool)
+			for _, q := range []*kdmpqs.PriorityQueue[string, *Order, int]{dst, src} {
+				kdmpqs.SetDuplicatePolicy(q, policy)
+				for range r.IntN(20) {
+					kdmpqs.Enqueue(q, &Order{ID: fmt.Sprint(r.IntN(15))}, r.IntN(5))
+					if r.IntN(4) == 0 {
+						kdmpqs.DequeueMax(q)
+					}
+				}
+				for key := range kdmpqs.Keys(q) {
+					keys[key] = true
+				}
+			}
+			assert.NoError(t, kdmpqs.Merge(dst, src))
+			assert.Empty(t, kdmpqs.Validate(dst))
+			assert.Equal(t, len(keys), kdmpqs.Len(dst))
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	q := newBook()
+	var ops []hooks.Op
+	kdmpqs.AddHook(q, func(e hooks.Event[*Order, int]) { ops = append(ops, e.Op) })
+
+	kdmpqs.Enqueue(q, &Order{ID: "a", Price: 10}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b", Price: 20}, 20)
+	kdmpqs.Enqueue(q, &Order{ID: "a", Price: 15}, 15)
+	kdmpqs.UpdateByKey(q, "b", 25)
+	kdmpqs.DequeueMax(q)
+	kdmpqs.DeleteByKey(q, "a")
+	kdmpqs.Clear(q)
+
+	assert.Equal(t, []hooks.Op{
+		hooks.Enqueue, hooks.Enqueue, hooks.Update, hooks.Update, hooks.Dequeue, hooks.Delete, hooks.Clear,
+	}, ops)
+}
+
+func TestValidate(t *testing.T) {
+	q := newBook()
+	a := &Order{ID: "a", Price: 10}
+	kdmpqs.Enqueue(q, a, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b", Price: 20}, 20)
+	assert.Empty(t, kdmpqs.Validate(q))
+
+	corrupt := func() {
+		a.ID = "c"
+		kdmpqs.Enqueue(q, &Order{ID: "d", Price: 30}, 30)
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	errs := kdmpqs.Validate(q)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "kdmpqs: key a maps to an element with key c")
+}
+
+func TestFormat(t *testing.T) {
+	q := kdmpqs.New(
+		kdmpqs.StableAscending[string, int],
+		func(s string) string { return s },
+	)
+	kdmpqs.Enqueue(q, "b", 2)
+	kdmpqs.Enqueue(q, "a", 1)
+	assert.Equal(t, "a (priority 1, seq 2)\n└── b (priority 2, seq 1)", q.String())
+
+	var sb strings.Builder
+	assert.NoError(t, q.WriteDOT(&sb))
+	assert.Contains(t, sb.String(), `n0 -> n1;`)
+}
+
+func TestNaNPolicy(t *testing.T) {
+	nan := math.NaN()
+	pq := kdmpqs.New(
+		kdmpqs.StableAscending[string, float64],
+		func(s string) string { return s },
+	)
+	kdmpqs.SetNaNPolicy(pq, kdmpqs.NaNLast)
+	for i, prio := range []float64{2, nan, 1, 3} {
+		assert.NoError(t, kdmpqs.Enqueue(pq, fmt.Sprint(i), prio))
+	}
+	assert.True(t, kdmpqs.Update(pq, "0", nan))
+	assert.Empty(t, kdmpqs.Validate(pq))
+	item, _ := kdmpqs.PeekMin(pq)
+	assert.Equal(t, "2", item)
+	item, _ = kdmpqs.PeekMax(pq)
+	assert.Equal(t, "0", item)
+
+	kdmpqs.SetNaNPolicy(pq, kdmpqs.NaNFirst)
+	item, _ = kdmpqs.PeekMin(pq)
+	assert.Equal(t, "1", item)
+	item, _ = kdmpqs.PeekMax(pq)
+	assert.Equal(t, "3", item)
+
+	kdmpqs.SetNaNPolicy(pq, kdmpqs.RejectNaN)
+	assert.Equal(t, 2, kdmpqs.Len(pq))
+	assert.ErrorIs(t, kdmpqs.Enqueue(pq, "4", nan), kdmpqs.ErrNaN)
+	assert.False(t, kdmpqs.Update(pq, "2", nan))
+	assert.False(t, kdmpqs.UpdateByKey(pq, "2", nan))
+	prio, _ := kdmpqs.PriorityOf(pq, "2")
+	assert.Equal(t, 1.0, prio)
+}
+
+func ExampleNew() {
+	q := kdmpqs.New(
+		kdmpqs.Ascending[*Order, int],
+		func(o *Order) string { return o.ID },
+	)
+	kdmpqs.Enqueue(q, &Order{ID: "ask1"}, 101)
+	kdmpqs.Enqueue(q, &Order{ID: "ask2"}, 99)
+	best, _ := kdmpqs.PeekMin(q)
+	worst, _ := kdmpqs.PeekMax(q)
+	fmt.Println(best.ID, worst.ID)
+	// Output: ask2 ask1
+}
+
+func ExampleUpdate() {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 20)
+	kdmpqs.Update(q, &Order{ID: "a"}, 30)
+	item, _ := kdmpqs.DequeueMax(q)
+	fmt.Println(item.ID)
+	// Output: a
+}
+
+func ExampleDelete() {
+	q := newBook()
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "b"}, 20)
+	kdmpqs.Delete(q, &Order{ID: "a"})
+	item, _ := kdmpqs.DequeueMin(q)
+	fmt.Println(item.ID)
+	// Output: b
+}
+
+func ExampleSetDuplicatePolicy() {
+	q := newBook()
+	kdmpqs.SetDuplicatePolicy(q, kdmpqs.KeepHigher)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 10)
+	kdmpqs.Enqueue(q, &Order{ID: "a"}, 5)
+	prio, _ := kdmpqs.PriorityOf(q, "a")
+	fmt.Println(prio)
+	// Output: 10
+}
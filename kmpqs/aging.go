@@ -0,0 +1,121 @@
+package kmpqs
+
+import (
+	"math"
+	"time"
+)
+
+// Number is the set of priority types the built-in aging functions support.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// AgingFunc returns the effective priority of an element enqueued with priority prio
+// that has waited for the given duration. It must return prio when waited is zero.
+type AgingFunc[P any] func(prio P, waited time.Duration) P
+
+// LinearAging returns an AgingFunc that adds rate to the priority for every per that
+// an element waits, in proportion to the time waited.
+// Use a negative rate with min-first comparators and a positive one with max-first comparators.
+// It panics if per is not positive.
+func LinearAging[P Number](rate P, per time.Duration) AgingFunc[P] {
+	if per <= 0 {
+		panic("kmpqs: aging period must be positive")
+	}
+	return func(prio P, waited time.Duration) P {
+		return prio + P(float64(rate)*float64(waited)/float64(per))
+	}
+}
+
+// StepAging returns an AgingFunc that adds step to the priority once for every full
+// interval an element waits.
+// Use a negative step with min-first comparators and a positive one with max-first comparators.
+// It panics if every is not positive.
+func StepAging[P Number](step P, every time.Duration) AgingFunc[P] {
+	if every <= 0 {
+		panic("kmpqs: aging period must be positive")
+	}
+	return func(prio P, waited time.Duration) P {
+		return prio + step*P(waited/every)
+	}
+}
+
+// ExponentialAging returns an AgingFunc that multiplies the priority by factor for every
+// interval an element waits, in proportion to the time waited.
+// Use a factor below 1 with min-first comparators and above 1 with max-first comparators,
+// on positive priorities. It panics if every is not positive.
+func ExponentialAging[P Number](factor float64, every time.Duration) AgingFunc[P] {
+	if every <= 0 {
+		panic("kmpqs: aging period must be positive")
+	}
+	return func(prio P, waited time.Duration) P {
+		return P(float64(prio) * math.Pow(factor, float64(waited)/float64(every)))
+	}
+}
+
+// SetAging makes the priority of queued elements improve with the time they have waited,
+// so low priority elements are eventually served. Aging is not applied on every comparison:
+// Rebalance recomputes the effective priority of every element with aging and restores the
+// heap order, and a positive interval makes Peek, Dequeue and Drain call Rebalance once the
+// interval has passed since the last pass. With a zero interval only explicit Rebalance calls age elements.
+//
+// The effective priority is what orders the queue and what Priority, PriorityOf and All report;
+// Elem.BasePriority reports the priority given to Enqueue or Update. Update keeps the time an
+// element has waited, while replacing it through Enqueue starts over. Snapshots record effective
+// priorities, and restored elements age from there. Passing a nil aging function disables aging
+// and leaves the current priorities in place. Time is read from the clock set by SetClock.
+func SetAging[K comparable, T any, P any](pq *PriorityQueue[K, T, P], aging AgingFunc[P], interval time.Duration) {
+	pq.aging = aging
+	pq.agingInterval = interval
+	if aging == nil {
+		for node := range pq.heap.backend.All() {
+			node.Value.base, node.Value.since = *new(P), time.Time{}
+		}
+		return
+	}
+	Rebalance(pq)
+}
+
+// Rebalance recomputes the effective priority of every element from the aging function set by
+// SetAging and restores the heap order. It costs O(n) and does nothing without aging.
+func Rebalance[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	if pq.aging == nil {
+		return
+	}
+	now := pq.now()
+	pq.lastRebalance = now
+	// Aging changes any number of priorities at once, so the heap is rebuilt rather than fixed node by node.
+	for node := range pq.heap.backend.All() {
+		elem := &node.Value
+		if elem.since.IsZero() {
+			elem.base, elem.since = elem.prio, now
+		}
+		elem.prio = pq.aging(elem.base, now.Sub(elem.since))
+	}
+	pq.heap.rebuild()
+	check(pq)
+}
+
+// age stamps an element entering the queue, or changing its priority, for aging.
+// The element's priority is taken as its base priority and since is when it started waiting;
+// the zero time means now.
+func age[K comparable, T any, P any](pq *PriorityQueue[K, T, P], elem *Elem[T, P], since time.Time) {
+	if pq.aging == nil {
+		return
+	}
+	now := pq.now()
+	if since.IsZero() {
+		since = now
+	}
+	elem.base, elem.since = elem.prio, since
+	elem.prio = pq.aging(elem.base, now.Sub(since))
+}
+
+// maybeRebalance runs Rebalance if the aging interval has passed since the last pass.
+func maybeRebalance[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	if pq.aging != nil && pq.agingInterval > 0 && pq.now().Sub(pq.lastRebalance) >= pq.agingInterval {
+		Rebalance(pq)
+	}
+}
@@ -0,0 +1,104 @@
+package kmpqs
+
+import (
+	"slices"
+	"time"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+)
+
+// SetClock sets the function the priority queue uses to tell whether elements have expired.
+// The default is time.Now.
+func SetClock[K comparable, T any, P any](pq *PriorityQueue[K, T, P], now func() time.Time) {
+	pq.now = now
+}
+
+// SetExpiredFunc sets a function called with every expired element the queue drops, whether it is
+// skipped by Peek, Dequeue or Drain, reaped by Expire, or dropped to make room in a full queue.
+// It can be used to dead-letter stale work. The function must not modify the queue.
+func SetExpiredFunc[K comparable, T any, P any](pq *PriorityQueue[K, T, P], expiredFunc func(Elem[T, P])) {
+	pq.expiredFunc = expiredFunc
+}
+
+// EnqueueWithExpiry inserts a new item with the given priority that expires at expiresAt,
+// following the same duplicate and eviction rules as Enqueue. Once the queue's clock reaches
+// expiresAt, the element is no longer served by Peek, Dequeue or Drain.
+func EnqueueWithExpiry[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, prio P, expiresAt time.Time) error {
+	_, _, err := enqueue(pq, Elem[T, P]{
+		item:   item,
+		prio:   prio,
+		seq:    pq.counter(),
+		expiry: expiresAt,
+	})
+	return err
+}
+
+// EnqueueWithTTL inserts a new item with the given priority that expires after ttl has elapsed
+// on the queue's clock.
+func EnqueueWithTTL[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, prio P, ttl time.Duration) error {
+	return EnqueueWithExpiry(pq, item, prio, pq.now().Add(ttl))
+}
+
+// Expire removes every element that has expired at now and returns them in priority order.
+// It scans the whole queue, so it costs O(n) plus O(log n) per removed element.
+func Expire[K comparable, T any, P any](pq *PriorityQueue[K, T, P], now time.Time) []Elem[T, P] {
+	expired := expire(pq, now)
+	slices.SortFunc(expired, func(x, y Elem[T, P]) int {
+		switch {
+		case pq.heap.less(x, y):
+			return -1
+		case pq.heap.less(y, x):
+			return 1
+		}
+		return 0
+	})
+	return expired
+}
+
+func expire[K comparable, T any, P any](pq *PriorityQueue[K, T, P], now time.Time) []Elem[T, P] {
+	var nodes []*heaps.Node[Elem[T, P]]
+	for node := range pq.heap.backend.All() {
+		if expiredAt(node.Value, now) {
+			nodes = append(nodes, node)
+		}
+	}
+	expired := make([]Elem[T, P], 0, len(nodes))
+	for _, node := range nodes {
+		elem := pq.heap.remove(node)
+		expired = append(expired, elem)
+		reportExpired(pq, elem)
+	}
+	return expired
+}
+
+// skipExpired drops expired elements from the top of the heap.
+func skipExpired[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	var now time.Time
+	for pq.heap.Len() > 0 {
+		elem := pq.heap.backend.Peek().Value
+		if elem.expiry.IsZero() {
+			return
+		}
+		if now.IsZero() {
+			now = pq.now()
+		}
+		if !expiredAt(elem, now) {
+			return
+		}
+		pq.heap.pop()
+		reportExpired(pq, elem)
+	}
+}
+
+// expiredAt reports whether elem has an expiry and it is not after now.
+func expiredAt[T any, P any](elem Elem[T, P], now time.Time) bool {
+	return !elem.expiry.IsZero() && !now.Before(elem.expiry)
+}
+
+func reportExpired[K comparable, T any, P any](pq *PriorityQueue[K, T, P], elem Elem[T, P]) {
+	emit(pq, hooks.Delete, elem)
+	if pq.expiredFunc != nil {
+		pq.expiredFunc(elem)
+	}
+}
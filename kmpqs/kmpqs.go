@@ -2,55 +2,159 @@ package kmpqs
 
 import (
 	"cmp"
-	"container/heap"
+	"errors"
+	"iter"
+	"maps"
+	"time"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/comparator"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/internal/nan"
+	"github.com/byExist/priorityqueues/snapshot"
+)
+
+// ErrDuplicateKey is returned by Enqueue when an item with the same key is already
+// in the queue and the duplicate policy is Reject.
+var ErrDuplicateKey = errors.New("kmpqs: duplicate key")
+
+// DuplicatePolicy determines how Enqueue handles an item whose key is already in the queue.
+type DuplicatePolicy int
+
+const (
+	// Upsert replaces the queued item and its priority, as Update does. This is the default.
+	Upsert DuplicatePolicy = iota
+	// Reject leaves the queue unchanged and makes Enqueue return ErrDuplicateKey.
+	Reject
+	// KeepBetter keeps whichever of the queued and the new element would be dequeued first.
+	KeepBetter
+	// KeepWorse keeps whichever of the queued and the new element would be dequeued last.
+	KeepWorse
+)
+
+// EvictionPolicy determines what a queue that has reached its MaxLen does with an element under a new key.
+type EvictionPolicy int
+
+const (
+	// EvictWorst keeps the better of the new element and the queued element that would be
+	// dequeued last, and evicts the other. This is the default.
+	EvictWorst EvictionPolicy = iota
+	// RejectNew leaves the queue unchanged and evicts the new element.
+	RejectNew
 )
 
 // Elem represents an element in the priority queue with an item, its priority, and a sequence number.
-type Elem[T any, P cmp.Ordered] struct {
-	item T
-	prio P
-	seq  int
+type Elem[T any, P any] struct {
+	item   T
+	prio   P
+	seq    int
+	expiry time.Time
+
+	// base and since are only set while aging is enabled.
+	base  P
+	since time.Time
+}
+
+// Item returns the item stored in the element.
+func (e Elem[T, P]) Item() T {
+	return e.item
+}
+
+// Priority returns the priority of the element.
+func (e Elem[T, P]) Priority() P {
+	return e.prio
+}
+
+// Sequence returns the sequence number of the element.
+func (e Elem[T, P]) Sequence() int {
+	return e.seq
+}
+
+// BasePriority returns the priority the element was enqueued or last updated with.
+// It differs from Priority only while aging is enabled, see SetAging.
+func (e Elem[T, P]) BasePriority() P {
+	if e.since.IsZero() {
+		return e.prio
+	}
+	return e.base
+}
+
+// Expiry returns the time the element expires, or the zero time if it never expires.
+func (e Elem[T, P]) Expiry() time.Time {
+	return e.expiry
 }
 
-type heapImpl[K comparable, T any, P cmp.Ordered] struct {
-	elems  []Elem[T, P]
-	lookup map[K]int
+type heapImpl[K comparable, T any, P any] struct {
+	backend heaps.Backend[Elem[T, P]]
+	lookup  map[K]*heaps.Node[Elem[T, P]]
 
 	keyFunc  func(T) K
 	lessFunc func(i, j Elem[T, P]) bool
+	// less is lessFunc with NaN priorities placed by the NaN policy.
+	less func(i, j Elem[T, P]) bool
+	// expiring counts the elements that have an expiry.
+	expiring int
 }
 
 func (h *heapImpl[K, T, P]) Len() int {
-	return len(h.elems)
+	return h.backend.Len()
+}
+
+func (h *heapImpl[K, T, P]) push(elem Elem[T, P]) {
+	h.lookup[h.keyFunc(elem.item)] = h.backend.Push(elem)
+	h.count(elem, 1)
+}
+
+func (h *heapImpl[K, T, P]) pop() Elem[T, P] {
+	node := h.backend.Pop()
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	h.count(node.Value, -1)
+	return node.Value
 }
 
-func (h *heapImpl[K, T, P]) Less(i, j int) bool {
-	return h.lessFunc(h.elems[i], h.elems[j])
+func (h *heapImpl[K, T, P]) fix(node *heaps.Node[Elem[T, P]], elem Elem[T, P]) {
+	h.count(node.Value, -1)
+	h.count(elem, 1)
+	node.Value = elem
+	h.backend.Fix(node)
 }
 
-func (h *heapImpl[K, T, P]) Swap(i, j int) {
-	h.elems[i], h.elems[j] = h.elems[j], h.elems[i]
-	h.lookup[h.keyFunc(h.elems[i].item)] = i
-	h.lookup[h.keyFunc(h.elems[j].item)] = j
+func (h *heapImpl[K, T, P]) remove(node *heaps.Node[Elem[T, P]]) Elem[T, P] {
+	h.backend.Remove(node)
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	h.count(node.Value, -1)
+	return node.Value
 }
 
-func (h *heapImpl[K, T, P]) Push(x any) {
-	item := x.(Elem[T, P])
-	h.lookup[h.keyFunc(item.item)] = len(h.elems)
-	h.elems = append(h.elems, item)
+func (h *heapImpl[K, T, P]) clear() {
+	h.backend.Clear()
+	h.lookup = make(map[K]*heaps.Node[Elem[T, P]])
+	h.expiring = 0
 }
 
-func (h *heapImpl[K, T, P]) Pop() any {
-	old := h.elems
-	n := len(old)
-	item := old[n-1]
-	h.elems = old[0 : n-1]
-	delete(h.lookup, h.keyFunc(item.item))
-	return item
+// rebuild restores the heap order after the comparator or any number of priorities changed and
+// refreshes the lookup, since backends implemented outside the heaps package replace their nodes.
+func (h *heapImpl[K, T, P]) rebuild() {
+	heaps.Rebuild(h.backend)
+	for node := range h.backend.All() {
+		h.lookup[h.keyFunc(node.Value.item)] = node
+	}
+}
+
+// count adds d to the number of expiring elements if elem has an expiry.
+func (h *heapImpl[K, T, P]) count(elem Elem[T, P], d int) {
+	if !elem.expiry.IsZero() {
+		h.expiring += d
+	}
 }
 
 func counter() func() int {
-	i := 0
+	return counterFrom(0)
+}
+
+// counterFrom returns a counter whose first value is i+1.
+func counterFrom(i int) func() int {
 	return func() int {
 		i++
 		return i
@@ -58,9 +162,29 @@ func counter() func() int {
 }
 
 // PriorityQueue implements a priority queue with efficient update, delete, and lookup operations.
-type PriorityQueue[K comparable, T any, P cmp.Ordered] struct {
-	heap    *heapImpl[K, T, P]
-	counter func() int
+type PriorityQueue[K comparable, T any, P any] struct {
+	heap         *heapImpl[K, T, P]
+	counter      func() int
+	dupPolicy    DuplicatePolicy
+	codec        snapshot.Codec[T]
+	comparatorID string
+
+	maxLen      int
+	evictPolicy EvictionPolicy
+	evictFunc   func(incoming, worst Elem[T, P]) bool
+
+	now         func() time.Time
+	expiredFunc func(Elem[T, P])
+
+	aging         AgingFunc[P]
+	agingInterval time.Duration
+	lastRebalance time.Time
+
+	nanPolicy NaNPolicy
+	nanOrder  nan.Order
+	isNaN     func(P) bool
+
+	hooks []hooks.Hook[T, P]
 }
 
 // MinFirst compares two elements and returns true if x has lower priority than y.
@@ -93,99 +217,466 @@ func StableMaxFirst[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
 	return x.prio > y.prio
 }
 
+// MinFirstFunc returns a comparator that orders elements by ascending priority as determined
+// by compare, such as time.Time.Compare, for priority types that are not cmp.Ordered.
+func MinFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) < 0
+	}
+}
+
+// MaxFirstFunc returns a comparator that orders elements by descending priority as determined by compare.
+func MaxFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) > 0
+	}
+}
+
+// StableMinFirstFunc is like MinFirstFunc, but elements with equal priorities are ordered by insertion.
+func StableMinFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c < 0
+		}
+		return x.seq < y.seq
+	}
+}
+
+// StableMaxFirstFunc is like MaxFirstFunc, but elements with equal priorities are ordered by insertion.
+func StableMaxFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c > 0
+		}
+		return x.seq < y.seq
+	}
+}
+
 // New creates a new PriorityQueue with the provided less function.
 // The lessFunc determines the priority order: it should return true if x has higher priority than y.
 // For a min-priority queue, use: func(x, y Elem[T, P]) bool { return x.prio < y.prio }
-func New[K comparable, T any, P cmp.Ordered](
+func New[K comparable, T any, P any](
 	lessFunc func(x, y Elem[T, P]) bool,
 	keyFunc func(T) K,
 ) *PriorityQueue[K, T, P] {
-	return &PriorityQueue[K, T, P]{
-		heap: &heapImpl[K, T, P]{
-			elems:    []Elem[T, P]{},
-			lookup:   make(map[K]int),
-			lessFunc: lessFunc,
-			keyFunc:  keyFunc,
-		},
+	return NewWithBackend(lessFunc, keyFunc, heaps.Binary)
+}
+
+// NewWithBackend creates a new PriorityQueue like New, storing its elements in a heap created by backend.
+// For example, heaps.Pairing or heaps.Fibonacci make Update and UpdateByKey cheaper for decrease-key heavy workloads.
+func NewWithBackend[K comparable, T any, P any](
+	lessFunc func(x, y Elem[T, P]) bool,
+	keyFunc func(T) K,
+	backend heaps.Factory[Elem[T, P]],
+) *PriorityQueue[K, T, P] {
+	pq := &PriorityQueue[K, T, P]{
 		counter: counter(),
+		now:     time.Now,
+		isNaN:   nan.Func[P](),
+	}
+	less := nanLess(pq, lessFunc)
+	pq.heap = &heapImpl[K, T, P]{
+		backend:  backend(debug.Less(less)),
+		lookup:   make(map[K]*heaps.Node[Elem[T, P]]),
+		lessFunc: lessFunc,
+		less:     less,
+		keyFunc:  keyFunc,
 	}
+	return pq
+}
+
+// SetDuplicatePolicy sets how Enqueue handles items whose key is already in the queue.
+func SetDuplicatePolicy[K comparable, T any, P any](pq *PriorityQueue[K, T, P], policy DuplicatePolicy) {
+	pq.dupPolicy = policy
+}
+
+// SetMaxLen bounds the priority queue to at most maxLen elements; zero or a negative value removes the bound.
+// Once the queue is full, enqueuing an item under a new key drops expired elements and then consults
+// the eviction policy before inserting.
+// If the queue already holds more than maxLen elements, the ones that would be dequeued last are removed.
+// A bounded queue keeps its elements in a heaps.MinMax heap, so the element to evict is found in O(1)
+// and removed in O(log n). Bounding a queue with another backend moves its elements there in linear
+// time, and they stay there if the bound is removed later.
+func SetMaxLen[K comparable, T any, P any](pq *PriorityQueue[K, T, P], maxLen int) {
+	pq.maxLen = maxLen
+	if maxLen > 0 {
+		bound(pq)
+	}
+	for pq.maxLen > 0 && pq.heap.Len() > pq.maxLen {
+		emit(pq, hooks.Delete, pq.heap.remove(worst(pq)))
+	}
+}
+
+// SetEvictionPolicy sets how a full priority queue makes room for items under new keys.
+func SetEvictionPolicy[K comparable, T any, P any](pq *PriorityQueue[K, T, P], policy EvictionPolicy) {
+	pq.evictPolicy = policy
+	pq.evictFunc = nil
+}
+
+// SetEvictFunc sets a custom eviction policy, overriding the EvictionPolicy.
+// When the queue is full, evictFunc is called with the new element and the queued element that
+// would be dequeued last; returning true evicts worst and inserts incoming, false evicts incoming.
+func SetEvictFunc[K comparable, T any, P any](pq *PriorityQueue[K, T, P], evictFunc func(incoming, worst Elem[T, P]) bool) {
+	pq.evictFunc = evictFunc
+}
+
+// bound moves the elements of pq into a min-max heap unless its backend is already double-ended.
+// The nodes are kept, so the lookup stays valid.
+func bound[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	if _, ok := pq.heap.backend.(heaps.DoubleEnded[Elem[T, P]]); ok {
+		return
+	}
+	h := heaps.MinMax(debug.Less(pq.heap.less))
+	heaps.MeldUnordered(h, pq.heap.backend)
+	pq.heap.backend = h
+}
+
+// worst returns the node that would be dequeued last. Only bounded queues, whose backend
+// is double-ended, need it.
+func worst[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) *heaps.Node[Elem[T, P]] {
+	return pq.heap.backend.(heaps.DoubleEnded[Elem[T, P]]).PeekMax()
 }
 
 // Clear removes all elements from the priority queue.
-func Clear[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) {
-	pq.heap.elems = []Elem[T, P]{}
-	pq.heap.lookup = make(map[K]int)
+func Clear[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	pq.heap.clear()
 	pq.counter = counter()
+	emit(pq, hooks.Clear, Elem[T, P]{})
 }
 
 // Enqueue inserts a new item with the given priority into the priority queue.
-func Enqueue[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T, prio P) {
+// If an item with the same key is already queued, the queue's DuplicatePolicy decides
+// which one is kept; ErrDuplicateKey is returned only under the Reject policy.
+// A queued element that has expired is dropped and does not count as a duplicate.
+// If the queue is full, the eviction policy decides which element is dropped; use Offer to learn which one.
+// ErrNaN is returned if prio is NaN and the NaN policy is RejectNaN.
+func Enqueue[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, prio P) error {
+	_, _, err := Offer(pq, item, prio)
+	return err
+}
+
+// Offer inserts a new item with the given priority like Enqueue and returns the element evicted to
+// keep the queue within its MaxLen. The evicted element is either a queued one or the new one itself.
+// The boolean return value indicates whether an element was evicted. Items whose key is already
+// queued never cause an eviction. A full Offer costs O(log n), plus a scan for expired elements if
+// any queued element has an expiry.
+func Offer[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, prio P) (Elem[T, P], bool, error) {
 	elem := Elem[T, P]{
 		item: item,
 		prio: prio,
 		seq:  pq.counter(),
 	}
-	heap.Push(pq.heap, elem)
+	return enqueue(pq, elem)
+}
+
+func enqueue[K comparable, T any, P any](pq *PriorityQueue[K, T, P], elem Elem[T, P]) (Elem[T, P], bool, error) {
+	if rejected(pq, elem.prio) {
+		return Elem[T, P]{}, false, ErrNaN
+	}
+	age(pq, &elem, time.Time{})
+	node, exists := pq.heap.lookup[pq.heap.keyFunc(elem.item)]
+	if exists && !node.Value.expiry.IsZero() && expiredAt(node.Value, pq.now()) {
+		reportExpired(pq, pq.heap.remove(node))
+		exists = false
+	}
+	if !exists {
+		return insert(pq, elem)
+	}
+	if pq.dupPolicy == Reject {
+		return Elem[T, P]{}, false, ErrDuplicateKey
+	}
+	if replaces(pq, node.Value, elem) {
+		prev := node.Value
+		pq.heap.fix(node, elem)
+		emitUpdate(pq, hooks.Update, elem, prev)
+	}
+	return Elem[T, P]{}, false, nil
+}
+
+// replaces reports whether elem should take the place of the queued element with the same key.
+func replaces[K comparable, T any, P any](pq *PriorityQueue[K, T, P], queued, elem Elem[T, P]) bool {
+	switch pq.dupPolicy {
+	case KeepBetter:
+		return pq.heap.less(elem, queued)
+	case KeepWorse:
+		return pq.heap.less(queued, elem)
+	}
+	return true
+}
+
+func insert[K comparable, T any, P any](pq *PriorityQueue[K, T, P], elem Elem[T, P]) (Elem[T, P], bool, error) {
+	if pq.maxLen > 0 && pq.heap.Len() >= pq.maxLen && pq.heap.expiring > 0 {
+		expire(pq, pq.now())
+	}
+	if pq.maxLen <= 0 || pq.heap.Len() < pq.maxLen {
+		pq.heap.push(elem)
+		emit(pq, hooks.Enqueue, elem)
+		return Elem[T, P]{}, false, nil
+	}
+	last := worst(pq)
+	if !admit(pq, elem, last.Value) {
+		return elem, true, nil
+	}
+	evicted := pq.heap.remove(last)
+	emit(pq, hooks.Delete, evicted)
+	pq.heap.push(elem)
+	emit(pq, hooks.Enqueue, elem)
+	return evicted, true, nil
+}
+
+func admit[K comparable, T any, P any](pq *PriorityQueue[K, T, P], incoming, worst Elem[T, P]) bool {
+	if pq.evictFunc != nil {
+		return pq.evictFunc(incoming, worst)
+	}
+	if pq.evictPolicy == RejectNew {
+		return false
+	}
+	return pq.heap.less(incoming, worst)
 }
 
-// Dequeue removes and returns the highest priority item from the priority queue.
-func Dequeue[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) (T, bool) {
+// Dequeue removes and returns the highest priority item from the priority queue,
+// dropping expired elements on the way.
+func Dequeue[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
+	maybeRebalance(pq)
+	skipExpired(pq)
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := heap.Pop(pq.heap).(Elem[T, P])
+	elem := pq.heap.pop()
+	emit(pq, hooks.Dequeue, elem)
 	return elem.item, true
 }
 
 // Peek returns the highest priority item without removing it from the priority queue.
-func Peek[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) (T, bool) {
+// Expired elements ahead of it are dropped.
+func Peek[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
+	maybeRebalance(pq)
+	skipExpired(pq)
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := pq.heap.elems[0]
-	return elem.item, true
+	return pq.heap.backend.Peek().Value.item, true
 }
 
-// Update modifies the priority of an existing item using the queue's prioFunc.
-// Returns true if the item exists and was successfully updated.
-func Update[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T, newPrio P) bool {
+// Update modifies the priority of an existing item, keeping its expiry and the time it has waited for aging.
+// Returns true if the item exists and was successfully updated, and false if newPrio is rejected by the NaN policy.
+func Update[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T, newPrio P) bool {
 	key := pq.heap.keyFunc(item)
-	loc, exists := pq.heap.lookup[key]
-	if !exists {
+	node, exists := pq.heap.lookup[key]
+	if !exists || rejected(pq, newPrio) {
 		return false
 	}
 	elem := Elem[T, P]{
-		item: item,
-		prio: newPrio,
-		seq:  pq.counter(),
+		item:   item,
+		prio:   newPrio,
+		seq:    pq.counter(),
+		expiry: node.Value.expiry,
 	}
-	pq.heap.elems[loc] = elem
-	heap.Fix(pq.heap, loc)
+	age(pq, &elem, node.Value.since)
+	prev := node.Value
+	pq.heap.fix(node, elem)
+	emitUpdate(pq, hooks.Update, elem, prev)
 	return true
 }
 
 // Delete removes an item identified by its key from the priority queue.
 // Returns true if the item existed and was successfully removed.
-func Delete[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T) bool {
-	key := pq.heap.keyFunc(item)
-	loc, exists := pq.heap.lookup[key]
-	if !exists {
-		return false
-	}
-	heap.Remove(pq.heap, loc)
-	return true
+func Delete[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
+	_, ok := DeleteByKey(pq, pq.heap.keyFunc(item))
+	return ok
 }
 
-// Len returns the number of items currently in the priority queue.
-func Len[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) int {
+// Len returns the number of items currently in the priority queue,
+// including expired ones that have not been dropped yet.
+func Len[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) int {
 	return pq.heap.Len()
 }
 
 // Contains returns true if the queue contains an item identified by its key.
-func Contains[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T) bool {
-	key := pq.heap.keyFunc(item)
+func Contains[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
+	return ContainsKey(pq, pq.heap.keyFunc(item))
+}
+
+// ContainsKey returns true if the queue contains an item with the given key.
+// Expired items remain addressable by key until they are dropped.
+func ContainsKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) bool {
 	_, exists := pq.heap.lookup[key]
 	return exists
 }
+
+// GetByKey returns the stored item identified by key without removing it.
+// The boolean return indicates whether the key exists.
+func GetByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (T, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	return node.Value.item, true
+}
+
+// PriorityOf returns the stored priority of the item identified by key.
+// The boolean return indicates whether the key exists.
+func PriorityOf[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (P, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero P
+		return zero, false
+	}
+	return node.Value.prio, true
+}
+
+// UpdateByKey changes the priority of the item identified by key, keeping the stored item and its expiry.
+// Returns true if the key exists and the item was successfully updated, and false if newPrio is rejected
+// by the NaN policy.
+func UpdateByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K, newPrio P) bool {
+	node, exists := pq.heap.lookup[key]
+	if !exists || rejected(pq, newPrio) {
+		return false
+	}
+	elem := Elem[T, P]{
+		item:   node.Value.item,
+		prio:   newPrio,
+		seq:    pq.counter(),
+		expiry: node.Value.expiry,
+	}
+	age(pq, &elem, node.Value.since)
+	prev := node.Value
+	pq.heap.fix(node, elem)
+	emitUpdate(pq, hooks.Update, elem, prev)
+	return true
+}
+
+// DeleteByKey removes the item identified by key from the priority queue and returns it.
+// The boolean return indicates whether the key existed and the item was removed.
+func DeleteByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (T, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.remove(node)
+	emit(pq, hooks.Delete, elem)
+	return elem.item, true
+}
+
+// Merge moves all elements of src into dst and leaves src empty. Keys in both queues are resolved by
+// dst's DuplicatePolicy; under Reject, Merge returns ErrDuplicateKey and leaves both queues unchanged.
+// If dst has a MaxLen, the elements that would be dequeued last are dropped until it fits.
+func Merge[K comparable, T any, P any](dst, src *PriorityQueue[K, T, P]) error {
+	if dst == src {
+		return nil
+	}
+	if dst.dupPolicy == Reject {
+		for key := range src.heap.lookup {
+			if _, exists := dst.heap.lookup[key]; exists {
+				return ErrDuplicateKey
+			}
+		}
+	}
+	// Order src by dst's NaN policy while it is moved, and restore its own policy afterwards.
+	if policy := src.nanPolicy; policy != dst.nanPolicy {
+		SetNaNPolicy(src, dst.nanPolicy)
+		defer SetNaNPolicy(src, policy)
+	}
+	// Renumber src to follow dst, so stable comparators put dst's elements first on ties.
+	// All of src is renumbered before any of it is removed, so its heap stays ordered throughout.
+	base, last := dst.counter(), src.counter()
+	for node := range src.heap.backend.All() {
+		node.Value.seq += base
+	}
+	// Elements of dst replaced by ones from src are reported as updated, like an upsert.
+	replaced := make(map[K]Elem[T, P])
+	for key, node := range src.heap.lookup {
+		queued, exists := dst.heap.lookup[key]
+		if !exists {
+			continue
+		}
+		if replaces(dst, queued.Value, node.Value) {
+			replaced[key] = dst.heap.remove(queued)
+		} else {
+			src.heap.remove(node)
+		}
+	}
+	// Elements moved into dst are reported there once the meld is done.
+	var moved []Elem[T, P]
+	if len(dst.hooks) > 0 {
+		for node := range src.heap.backend.All() {
+			moved = append(moved, node.Value)
+		}
+	}
+	// Pairing and Fibonacci heaps link src in constant time, which is only safe when both queues
+	// are known to order alike; other comparators, such as closures, get src's elements inserted
+	// one by one. Array based heaps are rebuilt in linear time either way.
+	if comparator.Same(dst.heap.lessFunc, src.heap.lessFunc) {
+		dst.heap.backend.Meld(src.heap.backend)
+	} else {
+		heaps.MeldUnordered(dst.heap.backend, src.heap.backend)
+	}
+	maps.Copy(dst.heap.lookup, src.heap.lookup)
+	src.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]])
+	dst.heap.expiring += src.heap.expiring
+	src.heap.expiring = 0
+	dst.counter = counterFrom(base + last)
+	src.counter = counter()
+	emit(src, hooks.Clear, Elem[T, P]{})
+	for _, elem := range moved {
+		if prev, ok := replaced[dst.heap.keyFunc(elem.item)]; ok {
+			emitUpdate(dst, hooks.Update, elem, prev)
+		} else {
+			emit(dst, hooks.Enqueue, elem)
+		}
+	}
+	SetMaxLen(dst, dst.maxLen)
+	check(dst)
+	return nil
+}
+
+// All returns an iterator over the items and priorities currently in the priority queue.
+// Elements are yielded in unspecified order, not priority order, and the queue is left unchanged.
+// Expired elements that have not been dropped yet are included.
+// The queue must not be modified during iteration.
+func All[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for node := range pq.heap.backend.All() {
+			if !yield(node.Value.item, node.Value.prio) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the items currently in the priority queue.
+// Keys are yielded in unspecified order and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func Keys[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for node := range pq.heap.backend.All() {
+			if !yield(pq.heap.keyFunc(node.Value.item)) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues items and their priorities in priority order
+// until the queue is empty, skipping expired elements like Dequeue.
+// Stopping the iteration early leaves the remaining items in the queue.
+func Drain[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for {
+			maybeRebalance(pq)
+			skipExpired(pq)
+			if pq.heap.Len() == 0 {
+				return
+			}
+			elem := pq.heap.pop()
+			emit(pq, hooks.Dequeue, elem)
+			if !yield(elem.item, elem.prio) {
+				return
+			}
+		}
+	}
+}
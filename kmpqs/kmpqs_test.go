@@ -1,10 +1,19 @@
 package kmpqs_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
 	"github.com/byExist/priorityqueues/kmpqs"
+	"github.com/byExist/priorityqueues/snapshot"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -132,6 +141,1189 @@ func TestContains(t *testing.T) {
 	assert.False(t, kmpqs.Contains(q, processes["102"]))
 }
 
+func TestAll(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 2)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 1)
+	got := map[string]int{}
+	for p, prio := range kmpqs.All(q) {
+		got[p.PID] = prio
+	}
+	assert.Equal(t, map[string]int{"101": 2, "102": 1}, got)
+	assert.Equal(t, 2, kmpqs.Len(q))
+}
+
+func TestKeys(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 2)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 1)
+	var keys []string
+	for key := range kmpqs.Keys(q) {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"101", "102"}, keys)
+}
+
+func TestDrain(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 2)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 1)
+	kmpqs.Enqueue(q, &Process{PID: "103", Name: "redis"}, 2)
+	var pids []string
+	for p := range kmpqs.Drain(q) {
+		pids = append(pids, p.PID)
+	}
+	assert.Equal(t, []string{"102", "101", "103"}, pids)
+	assert.Equal(t, 0, kmpqs.Len(q))
+}
+
+func TestDrainStopEarly(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	nginx := &Process{PID: "101", Name: "nginx"}
+	kmpqs.Enqueue(q, nginx, 2)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 1)
+	for range kmpqs.Drain(q) {
+		break
+	}
+	assert.Equal(t, 1, kmpqs.Len(q))
+	assert.True(t, kmpqs.Contains(q, nginx))
+}
+
+func TestEnqueueDuplicateUpsert(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	assert.NoError(t, kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 5))
+	assert.NoError(t, kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 3))
+	assert.NoError(t, kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx-v2"}, 1))
+	assert.Equal(t, 2, kmpqs.Len(q))
+	p, _ := kmpqs.Dequeue(q)
+	assert.Equal(t, "nginx-v2", p.Name)
+	p, _ = kmpqs.Dequeue(q)
+	assert.Equal(t, "postgres", p.Name)
+	assert.False(t, kmpqs.Contains(q, p))
+}
+
+func TestEnqueueDuplicateReject(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetDuplicatePolicy(q, kmpqs.Reject)
+	assert.NoError(t, kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 5))
+	err := kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx-v2"}, 1)
+	assert.ErrorIs(t, err, kmpqs.ErrDuplicateKey)
+	assert.Equal(t, 1, kmpqs.Len(q))
+	p, _ := kmpqs.Peek(q)
+	assert.Equal(t, "nginx", p.Name)
+}
+
+func TestEnqueueDuplicateKeepBetter(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetDuplicatePolicy(q, kmpqs.KeepBetter)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "first"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "worse"}, 7)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "tie"}, 5)
+	p, _ := kmpqs.Peek(q)
+	assert.Equal(t, "first", p.Name)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "better"}, 2)
+	p, _ = kmpqs.Peek(q)
+	assert.Equal(t, "better", p.Name)
+	assert.Equal(t, 1, kmpqs.Len(q))
+}
+
+func TestEnqueueDuplicateKeepWorse(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetDuplicatePolicy(q, kmpqs.KeepWorse)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "first"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "better"}, 7)
+	p, _ := kmpqs.Peek(q)
+	assert.Equal(t, "first", p.Name)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "worse"}, 2)
+	p, _ = kmpqs.Peek(q)
+	assert.Equal(t, "worse", p.Name)
+	assert.Equal(t, 1, kmpqs.Len(q))
+}
+
+func TestGetByKey(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 4)
+	p, ok := kmpqs.GetByKey(q, "101")
+	assert.True(t, ok)
+	assert.Equal(t, "nginx", p.Name)
+	_, ok = kmpqs.GetByKey(q, "102")
+	assert.False(t, ok)
+}
+
+func TestPriorityOf(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 4)
+	prio, ok := kmpqs.PriorityOf(q, "101")
+	assert.True(t, ok)
+	assert.Equal(t, 4, prio)
+	_, ok = kmpqs.PriorityOf(q, "102")
+	assert.False(t, ok)
+}
+
+func TestUpdateByKey(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 4)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 2)
+	assert.True(t, kmpqs.UpdateByKey(q, "101", 1))
+	assert.False(t, kmpqs.UpdateByKey(q, "103", 1))
+	p, _ := kmpqs.Peek(q)
+	assert.Equal(t, "nginx", p.Name)
+	prio, _ := kmpqs.PriorityOf(q, "101")
+	assert.Equal(t, 1, prio)
+}
+
+func TestDeleteByKey(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 4)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 2)
+	p, ok := kmpqs.DeleteByKey(q, "102")
+	assert.True(t, ok)
+	assert.Equal(t, "postgres", p.Name)
+	_, ok = kmpqs.DeleteByKey(q, "102")
+	assert.False(t, ok)
+	assert.Equal(t, 1, kmpqs.Len(q))
+	p, _ = kmpqs.Peek(q)
+	assert.Equal(t, "nginx", p.Name)
+}
+
+func TestContainsKey(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 4)
+	assert.True(t, kmpqs.ContainsKey(q, "101"))
+	assert.False(t, kmpqs.ContainsKey(q, "102"))
+}
+
+func TestTimePriorities(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMinFirstFunc[string](time.Time.Compare), func(s string) string { return s })
+	assert.NoError(t, kmpqs.Enqueue(q, "report", epoch.Add(time.Hour)))
+	assert.NoError(t, kmpqs.Enqueue(q, "backup", epoch.Add(2*time.Hour)))
+	assert.NoError(t, kmpqs.Enqueue(q, "deploy", epoch.Add(time.Hour)))
+	assert.True(t, kmpqs.UpdateByKey(q, "backup", epoch))
+
+	var got []string
+	for item := range kmpqs.Drain(q) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"backup", "report", "deploy"}, got)
+
+	q = kmpqs.New(kmpqs.MaxFirstFunc[string](time.Time.Compare), func(s string) string { return s })
+	assert.NoError(t, kmpqs.Enqueue(q, "early", epoch))
+	assert.NoError(t, kmpqs.Enqueue(q, "late", epoch.Add(time.Hour)))
+	item, _ := kmpqs.Peek(q)
+	assert.Equal(t, "late", item)
+}
+
+func TestNewWithBackend(t *testing.T) {
+	backends := []heaps.Factory[kmpqs.Elem[string, int]]{
+		heaps.Quaternary[kmpqs.Elem[string, int]],
+		heaps.Pairing[kmpqs.Elem[string, int]],
+		heaps.Fibonacci[kmpqs.Elem[string, int]],
+	}
+	for _, backend := range backends {
+		q := kmpqs.NewWithBackend(
+			kmpqs.StableMinFirst[string, int],
+			func(s string) string { return s },
+			backend,
+		)
+		for i, id := range []string{"a", "b", "c", "d", "e"} {
+			kmpqs.Enqueue(q, id, 10+i)
+		}
+		kmpqs.UpdateByKey(q, "e", 1)
+		kmpqs.UpdateByKey(q, "a", 20)
+		kmpqs.DeleteByKey(q, "c")
+		var ids []string
+		for id := range kmpqs.Drain(q) {
+			ids = append(ids, id)
+		}
+		assert.Equal(t, []string{"e", "b", "d", "a"}, ids)
+		assert.Equal(t, 0, kmpqs.Len(q))
+	}
+}
+
+func TestSetMaxLenEvictWorst(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetMaxLen(q, 2)
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "2"}, 3)
+
+	evicted, ok, err := kmpqs.Offer(q, &Process{PID: "3"}, 4)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", evicted.Item().PID)
+	assert.Equal(t, 3, evicted.Priority())
+	assert.False(t, kmpqs.ContainsKey(q, "2"))
+
+	evicted, ok, _ = kmpqs.Offer(q, &Process{PID: "4"}, 1)
+	assert.True(t, ok)
+	assert.Equal(t, "4", evicted.Item().PID)
+	assert.False(t, kmpqs.ContainsKey(q, "4"))
+	assert.Equal(t, 2, kmpqs.Len(q))
+}
+
+func TestSetMaxLenStableTie(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetMaxLen(q, 2)
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 1)
+	kmpqs.Enqueue(q, &Process{PID: "2"}, 1)
+
+	evicted, _, _ := kmpqs.Offer(q, &Process{PID: "3"}, 1)
+	assert.Equal(t, "3", evicted.Item().PID)
+	evicted, _, _ = kmpqs.Offer(q, &Process{PID: "4"}, 2)
+	assert.Equal(t, "2", evicted.Item().PID)
+}
+
+func TestSetMaxLenExistingKey(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.MaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetMaxLen(q, 1)
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 1)
+
+	_, ok, err := kmpqs.Offer(q, &Process{PID: "1"}, 7)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	prio, _ := kmpqs.PriorityOf(q, "1")
+	assert.Equal(t, 7, prio)
+
+	kmpqs.SetDuplicatePolicy(q, kmpqs.Reject)
+	_, ok, err = kmpqs.Offer(q, &Process{PID: "1"}, 9)
+	assert.ErrorIs(t, err, kmpqs.ErrDuplicateKey)
+	assert.False(t, ok)
+}
+
+func TestSetMaxLenRejectNew(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.MaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetMaxLen(q, 1)
+	kmpqs.SetEvictionPolicy(q, kmpqs.RejectNew)
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 1)
+
+	evicted, ok, _ := kmpqs.Offer(q, &Process{PID: "2"}, 9)
+	assert.True(t, ok)
+	assert.Equal(t, "2", evicted.Item().PID)
+	assert.True(t, kmpqs.ContainsKey(q, "1"))
+}
+
+func TestSetEvictFunc(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.MaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetMaxLen(q, 1)
+	kmpqs.SetEvictFunc(q, func(incoming, worst kmpqs.Elem[*Process, int]) bool {
+		return incoming.Item().Name != ""
+	})
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 5)
+
+	evicted, _, _ := kmpqs.Offer(q, &Process{PID: "2"}, 9)
+	assert.Equal(t, "2", evicted.Item().PID)
+	evicted, _, _ = kmpqs.Offer(q, &Process{PID: "3", Name: "nginx"}, 1)
+	assert.Equal(t, "1", evicted.Item().PID)
+	assert.True(t, kmpqs.ContainsKey(q, "3"))
+}
+
+func TestSetMaxLenShrinks(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.MinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	for i := range 5 {
+		kmpqs.Enqueue(q, &Process{PID: fmt.Sprint(i)}, i)
+	}
+	kmpqs.SetMaxLen(q, 2)
+	assert.Equal(t, 2, kmpqs.Len(q))
+	assert.True(t, kmpqs.ContainsKey(q, "0"))
+	assert.True(t, kmpqs.ContainsKey(q, "1"))
+	assert.False(t, kmpqs.ContainsKey(q, "4"))
+}
+
+func newMergeQueue(policy kmpqs.DuplicatePolicy) *kmpqs.PriorityQueue[string, *Process, int] {
+	q := kmpqs.NewWithBackend(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+		heaps.Fibonacci,
+	)
+	kmpqs.SetDuplicatePolicy(q, policy)
+	return q
+}
+
+func TestMerge(t *testing.T) {
+	dst := newMergeQueue(kmpqs.Upsert)
+	src := newMergeQueue(kmpqs.Upsert)
+	kmpqs.Enqueue(src, &Process{PID: "1"}, 1)
+	kmpqs.Enqueue(dst, &Process{PID: "2"}, 1)
+	kmpqs.Enqueue(src, &Process{PID: "3"}, 1)
+	kmpqs.Enqueue(dst, &Process{PID: "4"}, 0)
+
+	assert.NoError(t, kmpqs.Merge(dst, src))
+	assert.Equal(t, 0, kmpqs.Len(src))
+	kmpqs.Enqueue(dst, &Process{PID: "5"}, 1)
+
+	var got []string
+	for p := range kmpqs.Drain(dst) {
+		got = append(got, p.PID)
+	}
+	assert.Equal(t, []string{"4", "2", "1", "3", "5"}, got)
+}
+
+func TestMergeCollisions(t *testing.T) {
+	cases := []struct {
+		policy kmpqs.DuplicatePolicy
+		want   int
+	}{
+		{kmpqs.Upsert, 9},
+		{kmpqs.KeepBetter, 5},
+		{kmpqs.KeepWorse, 9},
+	}
+	for _, c := range cases {
+		dst := newMergeQueue(c.policy)
+		src := newMergeQueue(c.policy)
+		kmpqs.Enqueue(dst, &Process{PID: "1"}, 5)
+		kmpqs.Enqueue(src, &Process{PID: "1"}, 9)
+		kmpqs.Enqueue(src, &Process{PID: "2"}, 7)
+
+		assert.NoError(t, kmpqs.Merge(dst, src))
+		assert.Equal(t, 2, kmpqs.Len(dst))
+		prio, _ := kmpqs.PriorityOf(dst, "1")
+		assert.Equal(t, c.want, prio)
+		_, ok := kmpqs.DeleteByKey(dst, "1")
+		assert.True(t, ok)
+		_, ok = kmpqs.DeleteByKey(dst, "2")
+		assert.True(t, ok)
+		assert.Equal(t, 0, kmpqs.Len(dst))
+	}
+}
+
+func TestMergeRandomized(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, backend := range []heaps.Factory[kmpqs.Elem[*Process, int]]{
+		heaps.Binary[kmpqs.Elem[*Process, int]],
+		heaps.Quaternary[kmpqs.Elem[*Process, int]],
+		heaps.Pairing[kmpqs.Elem[*Process, int]],
+		heaps.Fibonacci[kmpqs.Elem[*Process, int]],
+	} {
+		for _, policy := range []kmpqs.DuplicatePolicy{kmpqs.Upsert, kmpqs.KeepBetter, kmpqs.KeepWorse} {
+			for range 100 {
+				queues := make([]*kmpqs.PriorityQueue[string, *Process, int], 2)
+				keys := make(map[string]bool)
+				for i := range queues {
+					queues[i] = kmpqs.NewWithBackend(
+						kmpqs.StableMinFirst[*Process, int],
+						func(p *Process) string { return p.PID },
+						backend,
+					)
+					kmpqs.SetDuplicatePolicy(queues[i], policy)
+					for range r.IntN(20) {
+						pid := fmt.Sprint(r.IntN(15))
+						kmpqs.Enqueue(queues[i], &Process{PID: pid}, r.IntN(5))
+						if r.IntN(4) == 0 {
+							kmpqs.Dequeue(queues[i])
+						}
+					}
+					for key := range kmpqs.Keys(queues[i]) {
+						keys[key] = true
+					}
+				}
+				dst, src := queues[0], queues[1]
+				assert.NoError(t, kmpqs.Merge(dst, src))
+				assert.Empty(t, kmpqs.Validate(dst))
+				assert.Equal(t, len(keys), kmpqs.Len(dst))
+			}
+		}
+	}
+}
+
+func TestMergeDifferentComparators(t *testing.T) {
+	for _, backend := range []heaps.Factory[kmpqs.Elem[*Process, int]]{heaps.Pairing[kmpqs.Elem[*Process, int]], heaps.Fibonacci[kmpqs.Elem[*Process, int]]} {
+		keyFunc := func(p *Process) string { return p.PID }
+		dst := kmpqs.NewWithBackend(kmpqs.StableMinFirst[*Process, int], keyFunc, backend)
+		src := kmpqs.NewWithBackend(kmpqs.StableMaxFirst[*Process, int], keyFunc, backend)
+		for _, prio := range []int{5, 1, 8} {
+			kmpqs.Enqueue(dst, &Process{PID: fmt.Sprint(prio)}, prio)
+		}
+		for _, prio := range []int{2, 9, 3, 7} {
+			kmpqs.Enqueue(src, &Process{PID: fmt.Sprint(prio)}, prio)
+		}
+		kmpqs.Dequeue(src)
+		assert.NoError(t, kmpqs.Merge(dst, src))
+		assert.Empty(t, kmpqs.Validate(dst))
+		var got []string
+		for p := range kmpqs.Drain(dst) {
+			got = append(got, p.PID)
+		}
+		assert.Equal(t, []string{"1", "2", "3", "5", "7", "8"}, got)
+	}
+}
+
+func TestMergeReject(t *testing.T) {
+	dst := newMergeQueue(kmpqs.Reject)
+	src := newMergeQueue(kmpqs.Reject)
+	kmpqs.Enqueue(dst, &Process{PID: "1"}, 5)
+	kmpqs.Enqueue(src, &Process{PID: "1"}, 9)
+
+	assert.ErrorIs(t, kmpqs.Merge(dst, src), kmpqs.ErrDuplicateKey)
+	assert.Equal(t, 1, kmpqs.Len(dst))
+	assert.Equal(t, 1, kmpqs.Len(src))
+}
+
+func TestMergeBounded(t *testing.T) {
+	dst := newMergeQueue(kmpqs.Upsert)
+	kmpqs.SetMaxLen(dst, 2)
+	src := newMergeQueue(kmpqs.Upsert)
+	kmpqs.Enqueue(dst, &Process{PID: "1"}, 3)
+	kmpqs.Enqueue(src, &Process{PID: "2"}, 1)
+	kmpqs.Enqueue(src, &Process{PID: "3"}, 2)
+
+	assert.NoError(t, kmpqs.Merge(dst, src))
+	assert.Equal(t, 2, kmpqs.Len(dst))
+	assert.False(t, kmpqs.ContainsKey(dst, "1"))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	newQueue := func() *kmpqs.PriorityQueue[string, *Process, int] {
+		return kmpqs.New(
+			kmpqs.StableMaxFirst[*Process, int],
+			func(p *Process) string { return p.PID },
+		)
+	}
+	q := newQueue()
+	kmpqs.Enqueue(q, &Process{PID: "1", Name: "nginx"}, 1)
+	kmpqs.Enqueue(q, &Process{PID: "2", Name: "redis"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "3", Name: "postgres"}, 1)
+	kmpqs.UpdateByKey(q, "1", 1)
+
+	data, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	restored := newQueue()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	kmpqs.Enqueue(restored, &Process{PID: "4", Name: "mysql"}, 1)
+	var got []string
+	for p := range kmpqs.Drain(restored) {
+		got = append(got, p.Name)
+	}
+	assert.Equal(t, []string{"redis", "postgres", "nginx", "mysql"}, got)
+}
+
+func TestJSONNonFinitePriorities(t *testing.T) {
+	newQueue := func() *kmpqs.PriorityQueue[string, *Process, float64] {
+		q := kmpqs.New(kmpqs.StableMinFirst[*Process, float64], func(p *Process) string { return p.PID })
+		kmpqs.SetNaNPolicy(q, kmpqs.NaNFirst)
+		return q
+	}
+	q := newQueue()
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 1)
+	kmpqs.Enqueue(q, &Process{PID: "nan"}, math.NaN())
+	kmpqs.Enqueue(q, &Process{PID: "inf"}, math.Inf(1))
+	data, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"priority":"NaN"`)
+
+	restored := newQueue()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	var got []string
+	for p := range kmpqs.Drain(restored) {
+		got = append(got, p.PID)
+	}
+	assert.Equal(t, []string{"nan", "1", "inf"}, got)
+}
+
+func TestRestoreRejectsNaN(t *testing.T) {
+	newQueue := func() *kmpqs.PriorityQueue[string, *Process, float64] {
+		q := kmpqs.New(kmpqs.StableMinFirst[*Process, float64], func(p *Process) string { return p.PID })
+		kmpqs.SetCodec(q, processCodec())
+		return q
+	}
+	q := newQueue()
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 1)
+	kmpqs.Enqueue(q, &Process{PID: "nan"}, math.NaN())
+	kmpqs.Enqueue(q, &Process{PID: "2"}, 2)
+	text, err := json.Marshal(q)
+	assert.NoError(t, err)
+	data, err := q.MarshalBinary()
+	assert.NoError(t, err)
+
+	for _, restore := range []func(*kmpqs.PriorityQueue[string, *Process, float64]) error{
+		func(r *kmpqs.PriorityQueue[string, *Process, float64]) error { return json.Unmarshal(text, r) },
+		func(r *kmpqs.PriorityQueue[string, *Process, float64]) error { return r.UnmarshalBinary(data) },
+	} {
+		restored := newQueue()
+		kmpqs.SetNaNPolicy(restored, kmpqs.RejectNaN)
+		assert.NoError(t, restore(restored))
+		assert.Equal(t, 2, kmpqs.Len(restored))
+		assert.False(t, kmpqs.ContainsKey(restored, "nan"))
+		assert.Empty(t, kmpqs.Validate(restored))
+	}
+}
+
+func TestUnmarshalJSONDuplicateKey(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.MinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	data := []byte(`[{"item":{"PID":"1"},"priority":1,"seq":1},{"item":{"PID":"1"},"priority":2,"seq":2}]`)
+	assert.ErrorIs(t, json.Unmarshal(data, q), kmpqs.ErrDuplicateKey)
+	assert.Equal(t, 0, kmpqs.Len(q))
+
+	var zero kmpqs.PriorityQueue[string, *Process, int]
+	assert.Error(t, json.Unmarshal([]byte(`[]`), &zero))
+}
+
+func processCodec() snapshot.Codec[*Process] {
+	return snapshot.Codec[*Process]{
+		Append: func(buf []byte, p *Process) ([]byte, error) {
+			buf = snapshot.AppendBytes(buf, []byte(p.PID))
+			return snapshot.AppendBytes(buf, []byte(p.Name)), nil
+		},
+		Decode: func(data []byte) (*Process, error) {
+			pid, data, err := snapshot.ReadBytes(data)
+			if err != nil {
+				return nil, err
+			}
+			name, _, err := snapshot.ReadBytes(data)
+			if err != nil {
+				return nil, err
+			}
+			return &Process{PID: string(pid), Name: string(name)}, nil
+		},
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	newQueue := func() *kmpqs.PriorityQueue[string, *Process, int] {
+		q := kmpqs.New(
+			kmpqs.StableMaxFirst[*Process, int],
+			func(p *Process) string { return p.PID },
+		)
+		kmpqs.SetCodec(q, processCodec())
+		return q
+	}
+	q := newQueue()
+	for i := range 1000 {
+		kmpqs.Enqueue(q, &Process{PID: fmt.Sprint(i), Name: fmt.Sprint("p", i)}, i%7)
+	}
+	data, err := q.MarshalBinary()
+	assert.NoError(t, err)
+	jsonData, _ := json.Marshal(q)
+	assert.Less(t, len(data), len(jsonData)/2)
+
+	restored := newQueue()
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, 1000, kmpqs.Len(restored))
+	for p := range kmpqs.Drain(q) {
+		got, _ := kmpqs.Dequeue(restored)
+		assert.Equal(t, p, got)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	q := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetCodec(q, processCodec())
+	kmpqs.Enqueue(q, &Process{PID: "1"}, 1)
+	data, _ := q.MarshalBinary()
+
+	other := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, string],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetCodec(other, processCodec())
+	assert.ErrorIs(t, other.UnmarshalBinary(data), snapshot.ErrMismatch)
+
+	kmpqs.Enqueue(q, &Process{PID: "2"}, 1)
+	data2, _ := q.MarshalBinary()
+	sameKey := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, int],
+		func(p *Process) string { return "" },
+	)
+	kmpqs.SetCodec(sameKey, processCodec())
+	assert.ErrorIs(t, sameKey.UnmarshalBinary(data2), kmpqs.ErrDuplicateKey)
+
+	kmpqs.DeleteByKey(q, "2")
+	assert.ErrorIs(t, q.UnmarshalBinary(data[:len(data)-2]), snapshot.ErrFormat)
+	assert.Equal(t, 1, kmpqs.Len(q))
+}
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newClock returns a clock for SetClock and a function that advances it.
+func newClock() (func() time.Time, func(time.Duration)) {
+	now := epoch
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func newExpiringQueue() (*kmpqs.PriorityQueue[string, string, int], func(time.Duration), *[]string) {
+	q := kmpqs.New(kmpqs.StableMinFirst[string, int], func(s string) string { return s })
+	now, advance := newClock()
+	kmpqs.SetClock(q, now)
+	dropped := &[]string{}
+	kmpqs.SetExpiredFunc(q, func(e kmpqs.Elem[string, int]) { *dropped = append(*dropped, e.Item()) })
+	return q, advance, dropped
+}
+
+func TestExpirySkippedByPeekAndDequeue(t *testing.T) {
+	q, advance, dropped := newExpiringQueue()
+	assert.NoError(t, kmpqs.EnqueueWithTTL(q, "a", 1, time.Second))
+	assert.NoError(t, kmpqs.EnqueueWithExpiry(q, "b", 2, epoch.Add(2*time.Second)))
+	assert.NoError(t, kmpqs.Enqueue(q, "c", 3))
+
+	advance(time.Second)
+	item, ok := kmpqs.Peek(q)
+	assert.True(t, ok)
+	assert.Equal(t, "b", item)
+	assert.Equal(t, []string{"a"}, *dropped)
+	assert.False(t, kmpqs.ContainsKey(q, "a"))
+
+	advance(time.Second)
+	item, _ = kmpqs.Dequeue(q)
+	assert.Equal(t, "c", item)
+	assert.Equal(t, []string{"a", "b"}, *dropped)
+	assert.Equal(t, 0, kmpqs.Len(q))
+}
+
+func TestUpdateKeepsExpiry(t *testing.T) {
+	q, advance, _ := newExpiringQueue()
+	assert.NoError(t, kmpqs.EnqueueWithTTL(q, "a", 5, time.Second))
+	assert.NoError(t, kmpqs.Enqueue(q, "b", 2))
+	assert.True(t, kmpqs.Update(q, "a", 1))
+	assert.True(t, kmpqs.UpdateByKey(q, "a", 0))
+
+	advance(time.Second)
+	item, _ := kmpqs.Dequeue(q)
+	assert.Equal(t, "b", item)
+
+	// Enqueue replaces the whole element, expiry included.
+	assert.NoError(t, kmpqs.EnqueueWithTTL(q, "c", 1, time.Second))
+	assert.NoError(t, kmpqs.Enqueue(q, "c", 1))
+	advance(time.Hour)
+	item, _ = kmpqs.Dequeue(q)
+	assert.Equal(t, "c", item)
+}
+
+func TestExpiredKeyIsNotDuplicate(t *testing.T) {
+	q, advance, dropped := newExpiringQueue()
+	kmpqs.SetDuplicatePolicy(q, kmpqs.Reject)
+	assert.NoError(t, kmpqs.EnqueueWithTTL(q, "a", 1, time.Second))
+	assert.ErrorIs(t, kmpqs.Enqueue(q, "a", 2), kmpqs.ErrDuplicateKey)
+
+	advance(time.Second)
+	assert.NoError(t, kmpqs.Enqueue(q, "a", 2))
+	assert.Equal(t, []string{"a"}, *dropped)
+	p, _ := kmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 2, p)
+}
+
+func TestExpire(t *testing.T) {
+	q, _, dropped := newExpiringQueue()
+	kmpqs.EnqueueWithExpiry(q, "a", 3, epoch)
+	kmpqs.EnqueueWithExpiry(q, "b", 1, epoch.Add(time.Second))
+	kmpqs.EnqueueWithExpiry(q, "c", 2, epoch.Add(time.Hour))
+	kmpqs.Enqueue(q, "d", 4)
+
+	expired := kmpqs.Expire(q, epoch.Add(time.Second))
+	var got []string
+	for _, e := range expired {
+		got = append(got, e.Item())
+	}
+	assert.Equal(t, []string{"b", "a"}, got)
+	assert.Equal(t, epoch, expired[1].Expiry())
+	assert.ElementsMatch(t, got, *dropped)
+	assert.Equal(t, 2, kmpqs.Len(q))
+	assert.False(t, kmpqs.ContainsKey(q, "a"))
+	assert.False(t, kmpqs.ContainsKey(q, "b"))
+}
+
+func TestSetMaxLenBackends(t *testing.T) {
+	for _, backend := range []heaps.Factory[kmpqs.Elem[*Process, int]]{
+		heaps.Pairing[kmpqs.Elem[*Process, int]],
+		heaps.Fibonacci[kmpqs.Elem[*Process, int]],
+	} {
+		q := kmpqs.NewWithBackend(kmpqs.StableMinFirst[*Process, int], func(p *Process) string { return p.PID }, backend)
+		for i := range 10 {
+			kmpqs.Enqueue(q, &Process{PID: fmt.Sprint(i)}, (i*7)%10)
+		}
+		kmpqs.Dequeue(q)
+		kmpqs.SetMaxLen(q, 5)
+		assert.Empty(t, kmpqs.Validate(q))
+		assert.True(t, kmpqs.UpdateByKey(q, "5", 0))
+		for i := 10; i < 20; i++ {
+			kmpqs.Offer(q, &Process{PID: fmt.Sprint(i)}, i%10)
+		}
+		assert.Empty(t, kmpqs.Validate(q))
+		var got []string
+		for p := range kmpqs.Drain(q) {
+			got = append(got, p.PID)
+		}
+		assert.Equal(t, []string{"5", "10", "3", "11", "6"}, got)
+	}
+}
+
+func TestBoundedDropsExpiredFirst(t *testing.T) {
+	q, advance, dropped := newExpiringQueue()
+	kmpqs.SetMaxLen(q, 2)
+	kmpqs.EnqueueWithTTL(q, "a", 1, time.Second)
+	kmpqs.Enqueue(q, "b", 5)
+	advance(time.Second)
+
+	_, evicted, err := kmpqs.Offer(q, "c", 9)
+	assert.NoError(t, err)
+	assert.False(t, evicted)
+	assert.Equal(t, []string{"a"}, *dropped)
+	assert.Equal(t, 2, kmpqs.Len(q))
+}
+
+func TestExpiryRoundTrip(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMinFirst[string, int], func(s string) string { return s })
+	kmpqs.SetCodec(q, snapshot.OrderedCodec[string]())
+	kmpqs.EnqueueWithExpiry(q, "a", 1, epoch.Add(time.Second))
+	kmpqs.Enqueue(q, "b", 2)
+
+	jsonData, err := json.Marshal(q)
+	assert.NoError(t, err)
+	binData, err := q.MarshalBinary()
+	assert.NoError(t, err)
+
+	for name, restore := range map[string]func(*kmpqs.PriorityQueue[string, string, int]) error{
+		"JSON":   func(q *kmpqs.PriorityQueue[string, string, int]) error { return json.Unmarshal(jsonData, q) },
+		"Binary": func(q *kmpqs.PriorityQueue[string, string, int]) error { return q.UnmarshalBinary(binData) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			restored, advance, _ := newExpiringQueue()
+			kmpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+			assert.NoError(t, restore(restored))
+			item, _ := kmpqs.Peek(restored)
+			assert.Equal(t, "a", item)
+			advance(time.Second)
+			item, _ = kmpqs.Peek(restored)
+			assert.Equal(t, "b", item)
+		})
+	}
+}
+
+func TestAgingFunctions(t *testing.T) {
+	linear := kmpqs.LinearAging(-2, time.Minute)
+	assert.Equal(t, 10, linear(10, 0))
+	assert.Equal(t, 9, linear(10, 30*time.Second))
+	assert.Equal(t, 4, linear(10, 3*time.Minute))
+
+	step := kmpqs.StepAging(-1, time.Minute)
+	assert.Equal(t, 10, step(10, 59*time.Second))
+	assert.Equal(t, 8, step(10, 2*time.Minute))
+
+	exp := kmpqs.ExponentialAging[float64](2, time.Minute)
+	assert.Equal(t, 1.0, exp(1, 0))
+	assert.Equal(t, 8.0, exp(1, 3*time.Minute))
+	assert.InDelta(t, 1.414, exp(1, 30*time.Second), 0.001)
+
+	assert.Panics(t, func() { kmpqs.LinearAging(-1, 0) })
+	assert.Panics(t, func() { kmpqs.StepAging(-1, 0) })
+	assert.Panics(t, func() { kmpqs.ExponentialAging[float64](0.5, -time.Minute) })
+}
+
+func TestAgingPreventsStarvation(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMinFirst[string, int], func(s string) string { return s })
+	now, advance := newClock()
+	kmpqs.SetClock(q, now)
+	kmpqs.SetAging(q, kmpqs.LinearAging(-1, time.Second), time.Second)
+
+	assert.NoError(t, kmpqs.Enqueue(q, "batch", 10))
+	var served []string
+	for i := range 20 {
+		assert.NoError(t, kmpqs.Enqueue(q, fmt.Sprint("interactive-", i), 1))
+		item, _ := kmpqs.Dequeue(q)
+		served = append(served, item)
+		advance(time.Second)
+	}
+	// After nine seconds the batch job has aged to priority 1 and, being older, wins the tie.
+	assert.Contains(t, served, "batch")
+	assert.Equal(t, "batch", served[9])
+}
+
+func TestAgingTimePriorities(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMinFirstFunc[string](time.Time.Compare), func(s string) string { return s })
+	now, advance := newClock()
+	kmpqs.SetClock(q, now)
+	// Every second of waiting moves a deadline a minute earlier.
+	kmpqs.SetAging(q, func(deadline time.Time, waited time.Duration) time.Time {
+		return deadline.Add(-time.Duration(waited.Seconds()) * time.Minute)
+	}, time.Second)
+	assert.NoError(t, kmpqs.Enqueue(q, "batch", epoch.Add(time.Hour)))
+	advance(30 * time.Second)
+	assert.NoError(t, kmpqs.Enqueue(q, "interactive", epoch.Add(45*time.Minute)))
+
+	item, _ := kmpqs.Peek(q)
+	assert.Equal(t, "batch", item)
+	prio, _ := kmpqs.PriorityOf(q, "batch")
+	assert.True(t, epoch.Add(30*time.Minute).Equal(prio))
+}
+
+func TestRebalanceBackends(t *testing.T) {
+	for _, backend := range []heaps.Factory[kmpqs.Elem[string, int]]{
+		heaps.Binary[kmpqs.Elem[string, int]],
+		heaps.Pairing[kmpqs.Elem[string, int]],
+		func(less func(a, b kmpqs.Elem[string, int]) bool) heaps.Backend[kmpqs.Elem[string, int]] {
+			return foreignBackend[kmpqs.Elem[string, int]]{heaps.Binary(less)}
+		},
+	} {
+		q := kmpqs.NewWithBackend(kmpqs.StableMinFirst[string, int], func(s string) string { return s }, backend)
+		now, advance := newClock()
+		kmpqs.SetClock(q, now)
+		kmpqs.SetAging(q, kmpqs.LinearAging(-1, time.Second), 0)
+		for i := range 10 {
+			assert.NoError(t, kmpqs.Enqueue(q, fmt.Sprint(i), 20-i))
+			advance(time.Second)
+		}
+		kmpqs.Rebalance(q)
+		assert.Empty(t, kmpqs.Validate(q))
+		// Every element has aged to 10, so they are served in insertion order.
+		p, _ := kmpqs.PriorityOf(q, "3")
+		assert.Equal(t, 10, p)
+		assert.True(t, kmpqs.UpdateByKey(q, "5", 0))
+		var got []string
+		for item := range kmpqs.Drain(q) {
+			got = append(got, item)
+		}
+		assert.Equal(t, []string{"5", "0", "1", "2", "3", "4", "6", "7", "8", "9"}, got)
+	}
+}
+
+func TestAgingIsLazy(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMinFirst[string, int], func(s string) string { return s })
+	now, advance := newClock()
+	kmpqs.SetClock(q, now)
+	kmpqs.SetAging(q, kmpqs.StepAging(-5, time.Second), time.Minute)
+	assert.NoError(t, kmpqs.Enqueue(q, "old", 10))
+	advance(10 * time.Second)
+	assert.NoError(t, kmpqs.Enqueue(q, "new", 6))
+
+	// Aged priorities are not recomputed before the interval has passed.
+	item, _ := kmpqs.Peek(q)
+	assert.Equal(t, "new", item)
+	p, _ := kmpqs.PriorityOf(q, "old")
+	assert.Equal(t, 10, p)
+
+	// An explicit Rebalance applies them at once.
+	kmpqs.Rebalance(q)
+	p, _ = kmpqs.PriorityOf(q, "old")
+	assert.Equal(t, -40, p)
+	item, _ = kmpqs.Peek(q)
+	assert.Equal(t, "old", item)
+
+	// Otherwise the first Peek once the interval has passed since the last pass does.
+	assert.NoError(t, kmpqs.Enqueue(q, "newest", 0))
+	advance(time.Minute - time.Second)
+	kmpqs.Peek(q)
+	p, _ = kmpqs.PriorityOf(q, "newest")
+	assert.Equal(t, 0, p)
+	advance(time.Second)
+	item, _ = kmpqs.Peek(q)
+	assert.Equal(t, "old", item)
+	p, _ = kmpqs.PriorityOf(q, "newest")
+	assert.Equal(t, -300, p)
+}
+
+func TestAgingUpdateKeepsWaitingTime(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMinFirst[string, int], func(s string) string { return s })
+	now, advance := newClock()
+	kmpqs.SetClock(q, now)
+	var elems []kmpqs.Elem[string, int]
+	kmpqs.SetExpiredFunc(q, func(e kmpqs.Elem[string, int]) { elems = append(elems, e) })
+	kmpqs.SetAging(q, kmpqs.LinearAging(-1, time.Second), 0)
+
+	assert.NoError(t, kmpqs.EnqueueWithExpiry(q, "a", 100, epoch.Add(time.Hour)))
+	advance(10 * time.Second)
+	assert.True(t, kmpqs.UpdateByKey(q, "a", 50))
+	p, _ := kmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 40, p)
+
+	// Replacing the element through Enqueue starts its wait over.
+	assert.NoError(t, kmpqs.Enqueue(q, "a", 50))
+	p, _ = kmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 50, p)
+
+	kmpqs.EnqueueWithExpiry(q, "b", 30, epoch.Add(20*time.Second))
+	advance(10 * time.Second)
+	kmpqs.Rebalance(q)
+	kmpqs.Expire(q, epoch.Add(time.Hour))
+	assert.Len(t, elems, 1)
+	assert.Equal(t, 20, elems[0].Priority())
+	assert.Equal(t, 30, elems[0].BasePriority())
+}
+
+func TestSetAgingExistingAndDisable(t *testing.T) {
+	q := kmpqs.New(kmpqs.StableMaxFirst[string, float64], func(s string) string { return s })
+	now, advance := newClock()
+	kmpqs.SetClock(q, now)
+	assert.NoError(t, kmpqs.Enqueue(q, "a", 1))
+	assert.NoError(t, kmpqs.Enqueue(q, "b", 3))
+
+	// Elements queued before aging was enabled start waiting when it is.
+	advance(time.Hour)
+	kmpqs.SetAging(q, kmpqs.ExponentialAging[float64](2, time.Second), 0)
+	p, _ := kmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 1.0, p)
+	advance(2 * time.Second)
+	kmpqs.Rebalance(q)
+	p, _ = kmpqs.PriorityOf(q, "a")
+	assert.Equal(t, 4.0, p)
+
+	kmpqs.SetAging[string](q, nil, 0)
+	advance(time.Hour)
+	kmpqs.Rebalance(q)
+	p, _ = kmpqs.PriorityOf(q, "b")
+	assert.Equal(t, 12.0, p)
+	item, _ := kmpqs.Dequeue(q)
+	assert.Equal(t, "b", item)
+}
+
+func TestHooks(t *testing.T) {
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	var events []hooks.Event[*Process, int]
+	kmpqs.AddHook(pq, func(e hooks.Event[*Process, int]) { events = append(events, e) })
+
+	a, b := &Process{PID: "a"}, &Process{PID: "b"}
+	kmpqs.Enqueue(pq, a, 5)
+	kmpqs.Enqueue(pq, b, 3)
+	kmpqs.Enqueue(pq, a, 4)
+	kmpqs.UpdateByKey(pq, "b", 6)
+	kmpqs.DeleteByKey(pq, "b")
+	kmpqs.Dequeue(pq)
+	kmpqs.Clear(pq)
+
+	assert.Equal(t, []hooks.Event[*Process, int]{
+		{Op: hooks.Enqueue, Item: a, Priority: 5, Sequence: 1, Len: 1},
+		{Op: hooks.Enqueue, Item: b, Priority: 3, Sequence: 2, Len: 2},
+		{Op: hooks.Update, Item: a, Priority: 4, Sequence: 3, PrevPriority: 5, PrevSequence: 1, Len: 2},
+		{Op: hooks.Update, Item: b, Priority: 6, Sequence: 4, PrevPriority: 3, PrevSequence: 2, Len: 2},
+		{Op: hooks.Delete, Item: b, Priority: 6, Sequence: 4, Len: 1},
+		{Op: hooks.Dequeue, Item: a, Priority: 4, Sequence: 3, Len: 0},
+		{Op: hooks.Clear},
+	}, events)
+}
+
+func TestHooksRejectedAndExpired(t *testing.T) {
+	now, advance := newClock()
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetClock(pq, now)
+	kmpqs.SetDuplicatePolicy(pq, kmpqs.KeepBetter)
+	var ops []hooks.Op
+	kmpqs.AddHook(pq, func(e hooks.Event[*Process, int]) { ops = append(ops, e.Op) })
+
+	kmpqs.EnqueueWithTTL(pq, &Process{PID: "a"}, 1, time.Second)
+	kmpqs.Enqueue(pq, &Process{PID: "a"}, 2) // kept the better queued element
+	advance(time.Second)
+	kmpqs.Enqueue(pq, &Process{PID: "a"}, 2) // the expired element no longer counts
+	assert.Equal(t, []hooks.Op{hooks.Enqueue, hooks.Delete, hooks.Enqueue}, ops)
+}
+
+func TestHooksStats(t *testing.T) {
+	now, advance := newClock()
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	stats := hooks.NewStatsWithClock[*Process, int](now)
+	kmpqs.AddHook(pq, stats.Observe)
+
+	kmpqs.Enqueue(pq, &Process{PID: "a"}, 5)
+	advance(time.Second)
+	kmpqs.UpdateByKey(pq, "a", 1)
+	advance(time.Second)
+	kmpqs.Dequeue(pq)
+
+	s := stats.Summary()
+	assert.Equal(t, 1, s.Waits)
+	assert.Equal(t, 2*time.Second, s.MaxWait)
+}
+
+func TestValidate(t *testing.T) {
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, float64],
+		func(p *Process) string { return p.PID },
+	)
+	a := &Process{PID: "a"}
+	kmpqs.Enqueue(pq, a, 1)
+	kmpqs.Enqueue(pq, &Process{PID: "b"}, 2)
+	kmpqs.Enqueue(pq, &Process{PID: "c"}, 3)
+	assert.Empty(t, kmpqs.Validate(pq))
+
+	// Changing a key in place breaks the lookup table.
+	corrupt := func() {
+		a.PID = "z"
+		kmpqs.Enqueue(pq, &Process{PID: "d"}, 4)
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	errs := kmpqs.Validate(pq)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "kmpqs: key a maps to an element with key z")
+}
+
+func TestValidateNaN(t *testing.T) {
+	pq := kmpqs.New(
+		kmpqs.MinFirst[*Process, float64],
+		func(p *Process) string { return p.PID },
+	)
+	corrupt := func() {
+		kmpqs.Enqueue(pq, &Process{PID: "a"}, 1)
+		kmpqs.Enqueue(pq, &Process{PID: "b"}, math.NaN())
+		kmpqs.Enqueue(pq, &Process{PID: "c"}, 2)
+		kmpqs.Enqueue(pq, &Process{PID: "d"}, 3)
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	errs := kmpqs.Validate(pq)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], heaps.ErrComparator)
+}
+
+// foreignBackend hides the backend type from the heaps package.
+type foreignBackend[E any] struct {
+	heaps.Backend[E]
+}
+
+func TestNaNPolicy(t *testing.T) {
+	nan := math.NaN()
+	for _, backend := range []heaps.Factory[kmpqs.Elem[*Process, float64]]{
+		heaps.Binary[kmpqs.Elem[*Process, float64]],
+		heaps.Fibonacci[kmpqs.Elem[*Process, float64]],
+		func(less func(a, b kmpqs.Elem[*Process, float64]) bool) heaps.Backend[kmpqs.Elem[*Process, float64]] {
+			return foreignBackend[kmpqs.Elem[*Process, float64]]{heaps.Binary(less)}
+		},
+	} {
+		pq := kmpqs.NewWithBackend(
+			kmpqs.StableMinFirst[*Process, float64],
+			func(p *Process) string { return p.PID },
+			backend,
+		)
+		kmpqs.SetNaNPolicy(pq, kmpqs.NaNFirst)
+		for i, prio := range []float64{2, nan, 1, 3} {
+			assert.NoError(t, kmpqs.Enqueue(pq, &Process{PID: fmt.Sprint(i)}, prio))
+		}
+		assert.True(t, kmpqs.UpdateByKey(pq, "3", nan))
+		assert.Empty(t, kmpqs.Validate(pq))
+		item, _ := kmpqs.Peek(pq)
+		assert.Equal(t, "1", item.PID)
+
+		kmpqs.SetNaNPolicy(pq, kmpqs.NaNLast)
+		item, _ = kmpqs.Peek(pq)
+		assert.Equal(t, "2", item.PID)
+		// The lookup follows the rebuilt heap.
+		assert.True(t, kmpqs.UpdateByKey(pq, "1", 0))
+		assert.Empty(t, kmpqs.Validate(pq))
+
+		var got []string
+		for item := range kmpqs.Drain(pq) {
+			got = append(got, item.PID)
+		}
+		assert.Equal(t, []string{"1", "2", "0", "3"}, got)
+	}
+}
+
+func TestRejectNaN(t *testing.T) {
+	nan := math.NaN()
+	pq := kmpqs.New(
+		kmpqs.MinFirst[*Process, float64],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(pq, &Process{PID: "a"}, 1)
+	kmpqs.Enqueue(pq, &Process{PID: "b"}, 2)
+	kmpqs.SetNaNPolicy(pq, kmpqs.NaNLast)
+	kmpqs.UpdateByKey(pq, "b", nan)
+
+	kmpqs.SetNaNPolicy(pq, kmpqs.RejectNaN)
+	assert.False(t, kmpqs.ContainsKey(pq, "b"))
+	assert.ErrorIs(t, kmpqs.Enqueue(pq, &Process{PID: "c"}, nan), kmpqs.ErrNaN)
+	_, _, err := kmpqs.Offer(pq, &Process{PID: "c"}, nan)
+	assert.ErrorIs(t, err, kmpqs.ErrNaN)
+	assert.ErrorIs(t, kmpqs.EnqueueWithTTL(pq, &Process{PID: "c"}, nan, time.Minute), kmpqs.ErrNaN)
+	assert.False(t, kmpqs.Update(pq, &Process{PID: "a"}, nan))
+	assert.False(t, kmpqs.UpdateByKey(pq, "a", nan))
+	prio, _ := kmpqs.PriorityOf(pq, "a")
+	assert.Equal(t, 1.0, prio)
+	assert.Equal(t, 1, kmpqs.Len(pq))
+}
+
+func TestFormat(t *testing.T) {
+	now, advance := newClock()
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[string, int],
+		func(s string) string { return s },
+	)
+	kmpqs.SetClock(pq, now)
+	kmpqs.Enqueue(pq, "b", 20)
+	kmpqs.Enqueue(pq, "a", 10)
+	kmpqs.SetAging(pq, kmpqs.LinearAging(-1, time.Second), 0)
+	advance(5 * time.Second)
+	kmpqs.Rebalance(pq)
+
+	assert.Equal(t, "a (priority 5, seq 2)\n└── b (priority 15, seq 1)", pq.String())
+	assert.Equal(t, "a (priority 5, seq 2, base 10)\n└── b (priority 15, seq 1, base 20)", fmt.Sprintf("%+v", pq))
+	assert.Equal(t, "%!x(*kmpqs.PriorityQueue)", fmt.Sprintf("%x", pq))
+}
+
 func ExampleNew() {
 	type Process struct {
 		PID  string
@@ -296,3 +1488,300 @@ func ExampleContains() {
 	// true
 	// false
 }
+
+func ExampleKeys() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 1)
+	for key := range kmpqs.Keys(q) {
+		fmt.Println(key)
+	}
+	// Output: 101
+}
+
+func ExampleDrain() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 2)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 1)
+	for p, prio := range kmpqs.Drain(q) {
+		fmt.Println(p.Name, prio)
+	}
+	// Output:
+	// postgres 1
+	// nginx 2
+}
+
+func ExampleSetDuplicatePolicy() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetDuplicatePolicy(q, kmpqs.Reject)
+	fmt.Println(kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 1))
+	fmt.Println(kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 2))
+	// Output:
+	// <nil>
+	// kmpqs: duplicate key
+}
+
+func ExampleDeleteByKey() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 1)
+	p, ok := kmpqs.DeleteByKey(q, "101")
+	fmt.Println(ok, p.Name, kmpqs.Len(q))
+	// Output: true nginx 0
+}
+
+func ExampleUpdateByKey() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 3)
+	kmpqs.UpdateByKey(q, "101", 1)
+	p, _ := kmpqs.Peek(q)
+	prio, _ := kmpqs.PriorityOf(q, "101")
+	fmt.Println(p.Name, prio)
+	// Output: nginx 1
+}
+
+func ExampleNewWithBackend() {
+	q := kmpqs.NewWithBackend(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+		heaps.Pairing,
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 3)
+	kmpqs.UpdateByKey(q, "101", 1)
+	p, _ := kmpqs.Peek(q)
+	fmt.Println(p.Name)
+	// Output: nginx
+}
+
+func ExampleOffer() {
+	q := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetMaxLen(q, 2)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 5)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 3)
+	evicted, ok, _ := kmpqs.Offer(q, &Process{PID: "103", Name: "redis"}, 4)
+	fmt.Println(evicted.Item().Name, ok)
+	// Output: postgres true
+}
+
+func ExampleMerge() {
+	newQueue := func() *kmpqs.PriorityQueue[string, *Process, int] {
+		return kmpqs.New(
+			kmpqs.StableMinFirst[*Process, int],
+			func(p *Process) string { return p.PID },
+		)
+	}
+	shard1, shard2 := newQueue(), newQueue()
+	kmpqs.Enqueue(shard1, &Process{PID: "101", Name: "nginx"}, 2)
+	kmpqs.Enqueue(shard2, &Process{PID: "102", Name: "postgres"}, 1)
+	kmpqs.Merge(shard1, shard2)
+	for p := range kmpqs.Drain(shard1) {
+		fmt.Println(p.Name)
+	}
+	// Output:
+	// postgres
+	// nginx
+}
+
+func ExamplePriorityQueue_UnmarshalJSON() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 1)
+	kmpqs.Enqueue(q, &Process{PID: "102", Name: "postgres"}, 1)
+	data, _ := json.Marshal(q)
+
+	// Supply the comparator and key function by creating the queue before restoring into it.
+	restored := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	_ = json.Unmarshal(data, restored)
+	for p := range kmpqs.Drain(restored) {
+		fmt.Println(p.Name)
+	}
+	// Output:
+	// nginx
+	// postgres
+}
+
+func ExampleSetCodec() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetCodec(q, snapshot.JSONCodec[*Process]())
+	kmpqs.Enqueue(q, &Process{PID: "101", Name: "nginx"}, 1)
+	data, _ := q.MarshalBinary()
+
+	restored := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetCodec(restored, snapshot.JSONCodec[*Process]())
+	_ = restored.UnmarshalBinary(data)
+	p, _ := kmpqs.GetByKey(restored, "101")
+	fmt.Println(p.Name)
+	// Output: nginx
+}
+
+func ExampleSetExpiredFunc() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetExpiredFunc(q, func(e kmpqs.Elem[*Process, int]) {
+		fmt.Println("dead-letter:", e.Item().Name)
+	})
+
+	kmpqs.EnqueueWithExpiry(q, &Process{PID: "101", Name: "nginx"}, 1, time.Now().Add(-time.Second))
+	kmpqs.EnqueueWithTTL(q, &Process{PID: "102", Name: "postgres"}, 2, time.Hour)
+
+	p, _ := kmpqs.Dequeue(q)
+	fmt.Println(p.Name)
+	// Output:
+	// dead-letter: nginx
+	// postgres
+}
+
+func ExampleSetAging() {
+	q := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	kmpqs.SetClock(q, func() time.Time { return now })
+	// Improve priorities by one every minute, recomputing them at most once a minute.
+	kmpqs.SetAging(q, kmpqs.LinearAging(-1, time.Minute), time.Minute)
+
+	kmpqs.Enqueue(q, &Process{PID: "1", Name: "backup"}, 5)
+	now = now.Add(5 * time.Minute)
+	kmpqs.Enqueue(q, &Process{PID: "2", Name: "shell"}, 1)
+
+	p, _ := kmpqs.Dequeue(q)
+	fmt.Println(p.Name)
+	// Output:
+	// backup
+}
+
+func ExampleAddHook() {
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[*Process, int],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.AddHook(pq, func(e hooks.Event[*Process, int]) {
+		fmt.Println(e.Op, e.Item.PID, e.Priority, e.Len)
+	})
+	stats := hooks.NewStats[*Process, int]()
+	kmpqs.AddHook(pq, stats.Observe)
+
+	kmpqs.Enqueue(pq, &Process{PID: "101"}, 3)
+	kmpqs.Enqueue(pq, &Process{PID: "102"}, 2)
+	kmpqs.UpdateByKey(pq, "101", 1)
+	kmpqs.Dequeue(pq)
+
+	s := stats.Summary()
+	fmt.Println(s.Enqueued, s.Updated, s.Dequeued, s.MaxDepth)
+	// Output:
+	// enqueue 101 3 1
+	// enqueue 102 2 2
+	// update 101 1 2
+	// dequeue 101 1 1
+	// 2 1 1 2
+}
+
+func ExamplePriorityQueue_String() {
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[string, int],
+		func(s string) string { return s },
+	)
+	kmpqs.Enqueue(pq, "nginx", 3)
+	kmpqs.Enqueue(pq, "redis", 1)
+	kmpqs.Enqueue(pq, "mysql", 2)
+	kmpqs.Enqueue(pq, "cron", 5)
+	fmt.Printf("%q\n", pq)
+	// Output:
+	// "redis" (priority 1, seq 2)
+	// ├── "nginx" (priority 3, seq 1)
+	// │   └── "cron" (priority 5, seq 4)
+	// └── "mysql" (priority 2, seq 3)
+}
+
+func ExamplePriorityQueue_WriteDOT() {
+	pq := kmpqs.New(
+		kmpqs.StableMinFirst[string, int],
+		func(s string) string { return s },
+	)
+	kmpqs.Enqueue(pq, "nginx", 3)
+	kmpqs.Enqueue(pq, "redis", 1)
+	pq.WriteDOT(os.Stdout)
+	// Output:
+	// digraph "kmpqs" {
+	// 	node [shape=box];
+	// 	n0 [label="redis\npriority 1\nseq 2", style=bold];
+	// 	n1 [label="nginx\npriority 3\nseq 1"];
+	// 	n0 -> n1;
+	// }
+}
+
+func ExampleStableMinFirstFunc() {
+	type Job struct {
+		ID       string
+		Deadline time.Time
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	q := kmpqs.New(
+		kmpqs.StableMinFirstFunc[*Job](time.Time.Compare),
+		func(j *Job) string { return j.ID },
+	)
+	for _, j := range []*Job{
+		{ID: "report", Deadline: start.Add(2 * time.Hour)},
+		{ID: "backup", Deadline: start.Add(30 * time.Minute)},
+		{ID: "deploy", Deadline: start.Add(time.Hour)},
+	} {
+		kmpqs.Enqueue(q, j, j.Deadline)
+	}
+	for j, deadline := range kmpqs.Drain(q) {
+		fmt.Println(j.ID, deadline.Format(time.Kitchen))
+	}
+	// Output:
+	// backup 9:30AM
+	// deploy 10:00AM
+	// report 11:00AM
+}
+
+func ExampleSetNaNPolicy() {
+	pq := kmpqs.New(
+		kmpqs.StableMaxFirst[*Process, float64],
+		func(p *Process) string { return p.PID },
+	)
+	kmpqs.SetNaNPolicy(pq, kmpqs.NaNLast)
+	kmpqs.Enqueue(pq, &Process{PID: "1", Name: "unscored"}, math.NaN())
+	kmpqs.Enqueue(pq, &Process{PID: "2", Name: "nginx"}, 0.9)
+	kmpqs.Enqueue(pq, &Process{PID: "3", Name: "redis"}, 0.4)
+	for p, score := range kmpqs.Drain(pq) {
+		fmt.Println(p.Name, score)
+	}
+	// Output:
+	// nginx 0.9
+	// redis 0.4
+	// unscored NaN
+}
@@ -0,0 +1,109 @@
+package kpqs
+
+import (
+	"errors"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/snapshot"
+)
+
+// SetCodec sets the codec MarshalBinary and UnmarshalBinary use to encode and decode items.
+func SetCodec[K comparable, T any, P any](pq *PriorityQueue[K, T, P], codec snapshot.Codec[T]) {
+	pq.codec = codec
+}
+
+// SetComparatorID sets the identity MarshalBinary records for the comparator of the priority queue,
+// and UnmarshalBinary expects, in place of snapshot.Identity. It is required for comparators that
+// snapshot.Identity cannot identify, such as the closures returned by MinFirstFunc.
+func SetComparatorID[K comparable, T any, P any](pq *PriorityQueue[K, T, P], id string) {
+	pq.comparatorID = id
+}
+
+// MarshalBinary encodes the priority queue in the versioned snapshot format described by
+// package snapshot, recording the identity of its comparator or the ID set by SetComparatorID;
+// without either, snapshot.ErrUnidentified is returned. Items are encoded with the
+// codec set by SetCodec; without one, snapshot.ErrNoCodec is returned. Priorities are
+// encoded with snapshot.AppendValue.
+func (pq *PriorityQueue[K, T, P]) MarshalBinary() ([]byte, error) {
+	if pq.codec.Append == nil {
+		return nil, snapshot.ErrNoCodec
+	}
+	id := identity(pq)
+	if id == "" {
+		return nil, snapshot.ErrUnidentified
+	}
+	buf := snapshot.AppendHeader(nil, snapshot.Header{
+		Kind:       "kpqs",
+		Comparator: id,
+		Count:      pq.heap.Len(),
+	})
+	var err error
+	for node := range pq.heap.backend.All() {
+		buf, err = snapshot.AppendElem(buf, pq.codec, node.Value.item, node.Value.prio, node.Value.seq)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the contents of the priority queue with a snapshot produced by MarshalBinary,
+// keeping priorities and sequence numbers so stable comparators preserve FIFO order. The queue must have
+// been created by New or NewWithBackend with the same comparator the snapshot was taken with, otherwise
+// an error wrapping snapshot.ErrMismatch is returned, and have a codec set by SetCodec.
+// It returns ErrDuplicateKey if two elements share a key; the queue is left unchanged on error.
+// Elements with NaN priorities are dropped if the NaN policy is RejectNaN.
+func (pq *PriorityQueue[K, T, P]) UnmarshalBinary(data []byte) error {
+	if pq.heap == nil {
+		return errors.New("kpqs: UnmarshalBinary on a queue not created by New")
+	}
+	if pq.codec.Decode == nil {
+		return snapshot.ErrNoCodec
+	}
+	h, data, err := snapshot.ReadHeader(data)
+	if err != nil {
+		return err
+	}
+	if err := h.Check("kpqs", identity(pq)); err != nil {
+		return err
+	}
+	elems := make([]Elem[T, P], 0, h.Count)
+	keys := make(map[K]struct{}, h.Count)
+	for range h.Count {
+		var elem Elem[T, P]
+		elem.item, elem.prio, elem.seq, data, err = snapshot.ReadElem[T, P](data, pq.codec)
+		if err != nil {
+			return err
+		}
+		key := pq.heap.keyFunc(elem.item)
+		if _, exists := keys[key]; exists {
+			return ErrDuplicateKey
+		}
+		keys[key] = struct{}{}
+		elems = append(elems, elem)
+	}
+	if len(data) != 0 {
+		return snapshot.ErrFormat
+	}
+	pq.heap.backend.Clear()
+	pq.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]], len(elems))
+	last := 0
+	for _, elem := range elems {
+		if rejected(pq, elem.prio) {
+			continue
+		}
+		pq.heap.push(elem)
+		last = max(last, elem.seq)
+	}
+	pq.counter = counterFrom(last)
+	check(pq)
+	return nil
+}
+
+// identity returns the comparator identity recorded in snapshots of pq.
+func identity[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) string {
+	if pq.comparatorID != "" {
+		return pq.comparatorID
+	}
+	return snapshot.Identity(pq.heap.lessFunc)
+}
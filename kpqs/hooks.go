@@ -0,0 +1,36 @@
+package kpqs
+
+import "github.com/byExist/priorityqueues/hooks"
+
+// AddHook registers a function called after every enqueue, dequeue, update, delete and clear
+// on the priority queue. An item replacing a queued one with the same key is reported as an
+// update; an item rejected by the duplicate policy is not reported. Merge reports a clear on
+// src and the elements it moves into dst as enqueues, or as updates where they replace an
+// element of dst, and restoring a snapshot reports nothing.
+// Hooks must not modify the queue.
+func AddHook[K comparable, T any, P any](pq *PriorityQueue[K, T, P], hook hooks.Hook[T, P]) {
+	pq.hooks = append(pq.hooks, hook)
+}
+
+func emit[K comparable, T any, P any](pq *PriorityQueue[K, T, P], op hooks.Op, elem Elem[T, P]) {
+	emitUpdate(pq, op, elem, Elem[T, P]{})
+}
+
+func emitUpdate[K comparable, T any, P any](pq *PriorityQueue[K, T, P], op hooks.Op, elem, prev Elem[T, P]) {
+	check(pq)
+	if len(pq.hooks) == 0 {
+		return
+	}
+	e := hooks.Event[T, P]{
+		Op:           op,
+		Item:         elem.item,
+		Priority:     elem.prio,
+		Sequence:     elem.seq,
+		PrevPriority: prev.prio,
+		PrevSequence: prev.seq,
+		Len:          pq.heap.Len(),
+	}
+	for _, hook := range pq.hooks {
+		hook(e)
+	}
+}
@@ -0,0 +1,67 @@
+package kpqs
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+type jsonElem[T any, P any] struct {
+	Item     T           `json:"item"`
+	Priority nan.JSON[P] `json:"priority"`
+	Sequence int         `json:"seq"`
+}
+
+// MarshalJSON encodes the elements of the priority queue as a JSON array of objects
+// with item, priority and seq fields, in insertion order.
+// NaN and infinite priorities are encoded as the strings "NaN", "+Inf" and "-Inf".
+func (pq *PriorityQueue[K, T, P]) MarshalJSON() ([]byte, error) {
+	elems := make([]jsonElem[T, P], 0, pq.heap.Len())
+	for node := range pq.heap.backend.All() {
+		elems = append(elems, jsonElem[T, P]{node.Value.item, nan.JSON[P]{V: node.Value.prio}, node.Value.seq})
+	}
+	slices.SortFunc(elems, func(a, b jsonElem[T, P]) int {
+		return cmp.Compare(a.Sequence, b.Sequence)
+	})
+	return json.Marshal(elems)
+}
+
+// UnmarshalJSON replaces the contents of the priority queue with the elements of a JSON array
+// produced by MarshalJSON, keeping their priorities and sequence numbers so stable comparators
+// preserve FIFO order. The queue must have been created by New or NewWithBackend, which supply the comparator, key and priority functions.
+// It returns ErrDuplicateKey if two elements share a key; the queue is left unchanged if decoding fails.
+// Elements with NaN priorities are dropped if the NaN policy is RejectNaN.
+func (pq *PriorityQueue[K, T, P]) UnmarshalJSON(data []byte) error {
+	if pq.heap == nil {
+		return errors.New("kpqs: UnmarshalJSON on a queue not created by New")
+	}
+	var elems []jsonElem[T, P]
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	keys := make(map[K]struct{}, len(elems))
+	for _, e := range elems {
+		key := pq.heap.keyFunc(e.Item)
+		if _, exists := keys[key]; exists {
+			return ErrDuplicateKey
+		}
+		keys[key] = struct{}{}
+	}
+	pq.heap.backend.Clear()
+	pq.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]], len(elems))
+	last := 0
+	for _, e := range elems {
+		if rejected(pq, e.Priority.V) {
+			continue
+		}
+		pq.heap.push(Elem[T, P]{item: e.Item, prio: e.Priority.V, seq: e.Sequence})
+		last = max(last, e.Sequence)
+	}
+	pq.counter = counterFrom(last)
+	check(pq)
+	return nil
+}
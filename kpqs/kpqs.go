@@ -2,55 +2,99 @@ package kpqs
 
 import (
 	"cmp"
-	"container/heap"
+	"errors"
+	"iter"
+	"maps"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/comparator"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/internal/nan"
+	"github.com/byExist/priorityqueues/snapshot"
+)
+
+// ErrDuplicateKey is returned by Enqueue when an item with the same key is already
+// in the queue and the duplicate policy is Reject.
+var ErrDuplicateKey = errors.New("kpqs: duplicate key")
+
+// DuplicatePolicy determines how Enqueue handles an item whose key is already in the queue.
+type DuplicatePolicy int
+
+const (
+	// Upsert replaces the queued item and its priority, as Update does. This is the default.
+	Upsert DuplicatePolicy = iota
+	// Reject leaves the queue unchanged and makes Enqueue return ErrDuplicateKey.
+	Reject
+	// KeepBetter keeps whichever of the queued and the new element would be dequeued first.
+	KeepBetter
+	// KeepWorse keeps whichever of the queued and the new element would be dequeued last.
+	KeepWorse
 )
 
 // Elem represents an element in the priority queue with an item, its priority, and a sequence number.
-type Elem[T any, P cmp.Ordered] struct {
+type Elem[T any, P any] struct {
 	item T
 	prio P
 	seq  int
 }
 
-type heapImpl[K comparable, T any, P cmp.Ordered] struct {
-	elems  []Elem[T, P]
-	lookup map[K]int
+// Item returns the item stored in the element.
+func (e Elem[T, P]) Item() T {
+	return e.item
+}
+
+// Priority returns the priority of the element.
+func (e Elem[T, P]) Priority() P {
+	return e.prio
+}
+
+// Sequence returns the sequence number of the element.
+func (e Elem[T, P]) Sequence() int {
+	return e.seq
+}
+
+type heapImpl[K comparable, T any, P any] struct {
+	backend heaps.Backend[Elem[T, P]]
+	lookup  map[K]*heaps.Node[Elem[T, P]]
 
 	keyFunc  func(T) K
 	lessFunc func(i, j Elem[T, P]) bool
+	// less is lessFunc with NaN priorities placed by the NaN policy.
+	less func(i, j Elem[T, P]) bool
 }
 
 func (h *heapImpl[K, T, P]) Len() int {
-	return len(h.elems)
+	return h.backend.Len()
 }
 
-func (h *heapImpl[K, T, P]) Less(i, j int) bool {
-	return h.lessFunc(h.elems[i], h.elems[j])
+func (h *heapImpl[K, T, P]) push(elem Elem[T, P]) {
+	h.lookup[h.keyFunc(elem.item)] = h.backend.Push(elem)
 }
 
-func (h *heapImpl[K, T, P]) Swap(i, j int) {
-	h.elems[i], h.elems[j] = h.elems[j], h.elems[i]
-	h.lookup[h.keyFunc(h.elems[i].item)] = i
-	h.lookup[h.keyFunc(h.elems[j].item)] = j
+func (h *heapImpl[K, T, P]) pop() Elem[T, P] {
+	node := h.backend.Pop()
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	return node.Value
 }
 
-func (h *heapImpl[K, T, P]) Push(x any) {
-	item := x.(Elem[T, P])
-	h.lookup[h.keyFunc(item.item)] = len(h.elems)
-	h.elems = append(h.elems, item)
+func (h *heapImpl[K, T, P]) fix(node *heaps.Node[Elem[T, P]], elem Elem[T, P]) {
+	node.Value = elem
+	h.backend.Fix(node)
 }
 
-func (h *heapImpl[K, T, P]) Pop() any {
-	old := h.elems
-	n := len(old)
-	item := old[n-1]
-	h.elems = old[0 : n-1]
-	delete(h.lookup, h.keyFunc(item.item))
-	return item
+func (h *heapImpl[K, T, P]) remove(node *heaps.Node[Elem[T, P]]) Elem[T, P] {
+	h.backend.Remove(node)
+	delete(h.lookup, h.keyFunc(node.Value.item))
+	return node.Value
 }
 
 func counter() func() int {
-	i := 0
+	return counterFrom(0)
+}
+
+// counterFrom returns a counter whose first value is i+1.
+func counterFrom(i int) func() int {
 	return func() int {
 		i++
 		return i
@@ -58,10 +102,18 @@ func counter() func() int {
 }
 
 // PriorityQueue represents a priority queue with generic key, item, and priority types.
-type PriorityQueue[K comparable, T any, P cmp.Ordered] struct {
-	heap     *heapImpl[K, T, P]
-	counter  func() int
-	prioFunc func(T) P
+type PriorityQueue[K comparable, T any, P any] struct {
+	heap         *heapImpl[K, T, P]
+	counter      func() int
+	prioFunc     func(T) P
+	dupPolicy    DuplicatePolicy
+	codec        snapshot.Codec[T]
+	comparatorID string
+	hooks        []hooks.Hook[T, P]
+
+	nanPolicy NaNPolicy
+	nanOrder  nan.Order
+	isNaN     func(P) bool
 }
 
 // MinFirst compares two elements and returns true if x has lower priority than y.
@@ -94,103 +146,367 @@ func StableMaxFirst[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
 	return x.prio > y.prio
 }
 
+// MinFirstFunc returns a comparator that orders elements by ascending priority as determined
+// by compare, such as time.Time.Compare, for priority types that are not cmp.Ordered.
+func MinFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) < 0
+	}
+}
+
+// MaxFirstFunc returns a comparator that orders elements by descending priority as determined by compare.
+func MaxFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) > 0
+	}
+}
+
+// StableMinFirstFunc is like MinFirstFunc, but elements with equal priorities are ordered by insertion.
+func StableMinFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c < 0
+		}
+		return x.seq < y.seq
+	}
+}
+
+// StableMaxFirstFunc is like MaxFirstFunc, but elements with equal priorities are ordered by insertion.
+func StableMaxFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c > 0
+		}
+		return x.seq < y.seq
+	}
+}
+
 // New creates a new PriorityQueue with the provided less function.
 // The lessFunc determines the priority order: it should return true if x has higher priority than y.
 // For a min-priority queue, use: func(x, y Elem[T, P]) bool { return x.prio < y.prio }
-func New[K comparable, T any, P cmp.Ordered](
+func New[K comparable, T any, P any](
 	lessFunc func(x, y Elem[T, P]) bool,
 	keyFunc func(T) K,
 	prioFunc func(T) P,
 ) *PriorityQueue[K, T, P] {
-	return &PriorityQueue[K, T, P]{
-		heap: &heapImpl[K, T, P]{
-			elems:    []Elem[T, P]{},
-			lookup:   make(map[K]int),
-			keyFunc:  keyFunc,
-			lessFunc: lessFunc,
-		},
+	return NewWithBackend(lessFunc, keyFunc, prioFunc, heaps.Binary)
+}
+
+// NewWithBackend creates a new PriorityQueue like New, storing its elements in a heap created by backend.
+// For example, heaps.Pairing or heaps.Fibonacci make Update and UpdateByKey cheaper for decrease-key heavy workloads.
+func NewWithBackend[K comparable, T any, P any](
+	lessFunc func(x, y Elem[T, P]) bool,
+	keyFunc func(T) K,
+	prioFunc func(T) P,
+	backend heaps.Factory[Elem[T, P]],
+) *PriorityQueue[K, T, P] {
+	pq := &PriorityQueue[K, T, P]{
 		counter:  counter(),
 		prioFunc: prioFunc,
+		isNaN:    nan.Func[P](),
+	}
+	less := nanLess(pq, lessFunc)
+	pq.heap = &heapImpl[K, T, P]{
+		backend:  backend(debug.Less(less)),
+		lookup:   make(map[K]*heaps.Node[Elem[T, P]]),
+		keyFunc:  keyFunc,
+		lessFunc: lessFunc,
+		less:     less,
 	}
+	return pq
+}
+
+// SetDuplicatePolicy sets how Enqueue handles items whose key is already in the queue.
+func SetDuplicatePolicy[K comparable, T any, P any](pq *PriorityQueue[K, T, P], policy DuplicatePolicy) {
+	pq.dupPolicy = policy
 }
 
 // Clear removes all elements from the priority queue.
-func Clear[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) {
-	pq.heap.elems = []Elem[T, P]{}
-	pq.heap.lookup = make(map[K]int)
+func Clear[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	pq.heap.backend.Clear()
+	pq.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]])
 	pq.counter = counter()
+	emit(pq, hooks.Clear, Elem[T, P]{})
 }
 
 // Enqueue inserts a new item into the priority queue.
-func Enqueue[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T) {
+// If an item with the same key is already queued, the queue's DuplicatePolicy decides
+// which one is kept; ErrDuplicateKey is returned only under the Reject policy.
+// ErrNaN is returned if the priority is NaN and the NaN policy is RejectNaN.
+func Enqueue[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) error {
 	elem := Elem[T, P]{
 		item: item,
 		prio: pq.prioFunc(item),
 		seq:  pq.counter(),
 	}
-	heap.Push(pq.heap, elem)
+	return enqueue(pq, elem)
+}
+
+func enqueue[K comparable, T any, P any](pq *PriorityQueue[K, T, P], elem Elem[T, P]) error {
+	if rejected(pq, elem.prio) {
+		return ErrNaN
+	}
+	node, exists := pq.heap.lookup[pq.heap.keyFunc(elem.item)]
+	if !exists {
+		pq.heap.push(elem)
+		emit(pq, hooks.Enqueue, elem)
+		return nil
+	}
+	if pq.dupPolicy == Reject {
+		return ErrDuplicateKey
+	}
+	if replaces(pq, node.Value, elem) {
+		prev := node.Value
+		pq.heap.fix(node, elem)
+		emitUpdate(pq, hooks.Update, elem, prev)
+	}
+	return nil
+}
+
+// replaces reports whether elem should take the place of the queued element with the same key.
+func replaces[K comparable, T any, P any](pq *PriorityQueue[K, T, P], queued, elem Elem[T, P]) bool {
+	switch pq.dupPolicy {
+	case KeepBetter:
+		return pq.heap.less(elem, queued)
+	case KeepWorse:
+		return pq.heap.less(queued, elem)
+	}
+	return true
 }
 
 // Dequeue removes and returns the highest priority item from the priority queue.
 // The boolean return indicates whether an item was returned.
-func Dequeue[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) (T, bool) {
+func Dequeue[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := heap.Pop(pq.heap).(Elem[T, P])
+	elem := pq.heap.pop()
+	emit(pq, hooks.Dequeue, elem)
 	return elem.item, true
 }
 
 // Peek returns the highest priority item without removing it.
 // The boolean return indicates whether an item was returned.
-func Peek[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) (T, bool) {
+func Peek[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) (T, bool) {
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := pq.heap.elems[0]
-	return elem.item, true
+	return pq.heap.backend.Peek().Value.item, true
 }
 
 // Update modifies the priority of an existing item using the queue's prioFunc.
-// Returns true if the item exists and was successfully updated.
-func Update[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T) bool {
+// Returns true if the item exists and was successfully updated, and false if the new priority
+// is rejected by the NaN policy.
+func Update[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
 	key := pq.heap.keyFunc(item)
-	loc, exists := pq.heap.lookup[key]
+	node, exists := pq.heap.lookup[key]
 	if !exists {
 		return false
 	}
+	prio := pq.prioFunc(item)
+	if rejected(pq, prio) {
+		return false
+	}
 	elem := Elem[T, P]{
 		item: item,
-		prio: pq.prioFunc(item),
+		prio: prio,
 		seq:  pq.counter(),
 	}
-	pq.heap.elems[loc] = elem
-	heap.Fix(pq.heap, loc)
+	prev := node.Value
+	pq.heap.fix(node, elem)
+	emitUpdate(pq, hooks.Update, elem, prev)
 	return true
 }
 
 // Delete removes an item identified by its key from the priority queue.
 // Returns true if the item existed and was successfully removed.
-func Delete[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T) bool {
-	key := pq.heap.keyFunc(item)
-	loc, exists := pq.heap.lookup[key]
-	if !exists {
-		return false
-	}
-	heap.Remove(pq.heap, loc)
-	return true
+func Delete[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
+	_, ok := DeleteByKey(pq, pq.heap.keyFunc(item))
+	return ok
 }
 
 // Len returns the number of items in the priority queue.
-func Len[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P]) int {
+func Len[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) int {
 	return pq.heap.Len()
 }
 
 // Contains returns true if the queue contains an item identified by its key.
-func Contains[K comparable, T any, P cmp.Ordered](pq *PriorityQueue[K, T, P], item T) bool {
-	key := pq.heap.keyFunc(item)
+func Contains[K comparable, T any, P any](pq *PriorityQueue[K, T, P], item T) bool {
+	return ContainsKey(pq, pq.heap.keyFunc(item))
+}
+
+// ContainsKey returns true if the queue contains an item with the given key.
+func ContainsKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) bool {
 	_, exists := pq.heap.lookup[key]
 	return exists
 }
+
+// GetByKey returns the stored item identified by key without removing it.
+// The boolean return indicates whether the key exists.
+func GetByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (T, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	return node.Value.item, true
+}
+
+// PriorityOf returns the stored priority of the item identified by key.
+// The boolean return indicates whether the key exists.
+func PriorityOf[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (P, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero P
+		return zero, false
+	}
+	return node.Value.prio, true
+}
+
+// UpdateByKey re-evaluates the priority of the stored item identified by key using the queue's prioFunc.
+// This is useful when the item is a pointer whose priority field has been changed in place.
+// Returns true if the key exists and the item was successfully updated, and false if the new priority
+// is rejected by the NaN policy, in which case the queue keeps the previous priority.
+func UpdateByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) bool {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		return false
+	}
+	item := node.Value.item
+	prio := pq.prioFunc(item)
+	if rejected(pq, prio) {
+		return false
+	}
+	elem := Elem[T, P]{
+		item: item,
+		prio: prio,
+		seq:  pq.counter(),
+	}
+	prev := node.Value
+	pq.heap.fix(node, elem)
+	emitUpdate(pq, hooks.Update, elem, prev)
+	return true
+}
+
+// DeleteByKey removes the item identified by key from the priority queue and returns it.
+// The boolean return indicates whether the key existed and the item was removed.
+func DeleteByKey[K comparable, T any, P any](pq *PriorityQueue[K, T, P], key K) (T, bool) {
+	node, exists := pq.heap.lookup[key]
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	elem := pq.heap.remove(node)
+	emit(pq, hooks.Delete, elem)
+	return elem.item, true
+}
+
+// Merge moves all elements of src into dst and leaves src empty. Keys in both queues are resolved by
+// dst's DuplicatePolicy; under Reject, Merge returns ErrDuplicateKey and leaves both queues unchanged.
+func Merge[K comparable, T any, P any](dst, src *PriorityQueue[K, T, P]) error {
+	if dst == src {
+		return nil
+	}
+	if dst.dupPolicy == Reject {
+		for key := range src.heap.lookup {
+			if _, exists := dst.heap.lookup[key]; exists {
+				return ErrDuplicateKey
+			}
+		}
+	}
+	// Order src by dst's NaN policy while it is moved, and restore its own policy afterwards.
+	if policy := src.nanPolicy; policy != dst.nanPolicy {
+		SetNaNPolicy(src, dst.nanPolicy)
+		defer SetNaNPolicy(src, policy)
+	}
+	// Renumber src to follow dst, so stable comparators put dst's elements first on ties.
+	// All of src is renumbered before any of it is removed, so its heap stays ordered throughout.
+	base, last := dst.counter(), src.counter()
+	for node := range src.heap.backend.All() {
+		node.Value.seq += base
+	}
+	// Elements of dst replaced by ones from src are reported as updated, like an upsert.
+	replaced := make(map[K]Elem[T, P])
+	for key, node := range src.heap.lookup {
+		queued, exists := dst.heap.lookup[key]
+		if !exists {
+			continue
+		}
+		if replaces(dst, queued.Value, node.Value) {
+			replaced[key] = dst.heap.remove(queued)
+		} else {
+			src.heap.remove(node)
+		}
+	}
+	// Elements moved into dst are reported there once the meld is done.
+	var moved []Elem[T, P]
+	if len(dst.hooks) > 0 {
+		for node := range src.heap.backend.All() {
+			moved = append(moved, node.Value)
+		}
+	}
+	// Pairing and Fibonacci heaps link src in constant time, which is only safe when both queues
+	// are known to order alike; other comparators, such as closures, get src's elements inserted
+	// one by one. Array based heaps are rebuilt in linear time either way.
+	if comparator.Same(dst.heap.lessFunc, src.heap.lessFunc) {
+		dst.heap.backend.Meld(src.heap.backend)
+	} else {
+		heaps.MeldUnordered(dst.heap.backend, src.heap.backend)
+	}
+	maps.Copy(dst.heap.lookup, src.heap.lookup)
+	src.heap.lookup = make(map[K]*heaps.Node[Elem[T, P]])
+	dst.counter = counterFrom(base + last)
+	src.counter = counter()
+	emit(src, hooks.Clear, Elem[T, P]{})
+	for _, elem := range moved {
+		if prev, ok := replaced[dst.heap.keyFunc(elem.item)]; ok {
+			emitUpdate(dst, hooks.Update, elem, prev)
+		} else {
+			emit(dst, hooks.Enqueue, elem)
+		}
+	}
+	check(dst)
+	return nil
+}
+
+// All returns an iterator over the items and priorities currently in the priority queue.
+// Elements are yielded in unspecified order, not priority order, and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func All[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for node := range pq.heap.backend.All() {
+			if !yield(node.Value.item, node.Value.prio) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the items currently in the priority queue.
+// Keys are yielded in unspecified order and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func Keys[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for node := range pq.heap.backend.All() {
+			if !yield(pq.heap.keyFunc(node.Value.item)) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues items and their priorities in priority order
+// until the queue is empty. Stopping the iteration early leaves the remaining items in the queue.
+func Drain[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for pq.heap.Len() > 0 {
+			elem := pq.heap.pop()
+			emit(pq, hooks.Dequeue, elem)
+			if !yield(elem.item, elem.prio) {
+				return
+			}
+		}
+	}
+}
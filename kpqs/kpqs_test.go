@@ -1,10 +1,19 @@
 package kpqs_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
 	"github.com/byExist/priorityqueues/kpqs"
+	"github.com/byExist/priorityqueues/snapshot"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -150,6 +159,663 @@ func TestEmptyDequeuePeek(t *testing.T) {
 	assert.False(t, ok2)
 }
 
+func TestAll(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 3})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	got := map[string]int{}
+	for item, prio := range kpqs.All(pq) {
+		got[item.ID] = prio
+	}
+	assert.Equal(t, map[string]int{"a": 3, "b": 1}, got)
+	assert.Equal(t, 2, kpqs.Len(pq))
+}
+
+func TestKeys(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 3})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	var keys []string
+	for key := range kpqs.Keys(pq) {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+}
+
+func TestDrain(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 3})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	kpqs.Enqueue(pq, &Task{ID: "c", Priority: 2})
+	var ids []string
+	for item := range kpqs.Drain(pq) {
+		ids = append(ids, item.ID)
+	}
+	assert.Equal(t, []string{"b", "c", "a"}, ids)
+	assert.Equal(t, 0, kpqs.Len(pq))
+	assert.False(t, kpqs.Contains(pq, &Task{ID: "a"}))
+}
+
+func TestEnqueueDuplicateUpsert(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	assert.NoError(t, kpqs.Enqueue(pq, &Task{ID: "a", Priority: 5}))
+	assert.NoError(t, kpqs.Enqueue(pq, &Task{ID: "b", Priority: 3}))
+	assert.NoError(t, kpqs.Enqueue(pq, &Task{ID: "a", Priority: 1}))
+	assert.Equal(t, 2, kpqs.Len(pq))
+	item, _ := kpqs.Dequeue(pq)
+	assert.Equal(t, 1, item.Priority)
+	item, _ = kpqs.Dequeue(pq)
+	assert.Equal(t, "b", item.ID)
+	assert.False(t, kpqs.Contains(pq, &Task{ID: "a"}))
+}
+
+func TestEnqueueDuplicateReject(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.SetDuplicatePolicy(pq, kpqs.Reject)
+	assert.NoError(t, kpqs.Enqueue(pq, &Task{ID: "a", Priority: 5}))
+	assert.ErrorIs(t, kpqs.Enqueue(pq, &Task{ID: "a", Priority: 1}), kpqs.ErrDuplicateKey)
+	item, _ := kpqs.Peek(pq)
+	assert.Equal(t, 5, item.Priority)
+}
+
+func TestEnqueueDuplicateKeepBetter(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.SetDuplicatePolicy(pq, kpqs.KeepBetter)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 5})
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 9})
+	item, _ := kpqs.Peek(pq)
+	assert.Equal(t, 5, item.Priority)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 2})
+	item, _ = kpqs.Peek(pq)
+	assert.Equal(t, 2, item.Priority)
+}
+
+func TestEnqueueDuplicateKeepWorse(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.SetDuplicatePolicy(pq, kpqs.KeepWorse)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 5})
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 2})
+	item, _ := kpqs.Peek(pq)
+	assert.Equal(t, 5, item.Priority)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 9})
+	item, _ = kpqs.Peek(pq)
+	assert.Equal(t, 9, item.Priority)
+}
+
+func TestGetByKeyAndPriorityOf(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 4})
+	item, ok := kpqs.GetByKey(pq, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", item.ID)
+	prio, ok := kpqs.PriorityOf(pq, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, prio)
+	_, ok = kpqs.GetByKey(pq, "b")
+	assert.False(t, ok)
+	_, ok = kpqs.PriorityOf(pq, "b")
+	assert.False(t, ok)
+}
+
+func TestUpdateByKey(t *testing.T) {
+	a, b := &Task{ID: "a", Priority: 4}, &Task{ID: "b", Priority: 2}
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, a)
+	kpqs.Enqueue(pq, b)
+	a.Priority = 1
+	assert.True(t, kpqs.UpdateByKey(pq, "a"))
+	assert.False(t, kpqs.UpdateByKey(pq, "c"))
+	item, _ := kpqs.Peek(pq)
+	assert.Equal(t, "a", item.ID)
+}
+
+func TestDeleteByKeyAndContainsKey(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 4})
+	assert.True(t, kpqs.ContainsKey(pq, "a"))
+	item, ok := kpqs.DeleteByKey(pq, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, item.Priority)
+	assert.False(t, kpqs.ContainsKey(pq, "a"))
+	_, ok = kpqs.DeleteByKey(pq, "a")
+	assert.False(t, ok)
+}
+
+func TestTimePriorities(t *testing.T) {
+	type Job struct {
+		ID       string
+		Deadline time.Time
+	}
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	pq := kpqs.New(
+		kpqs.StableMinFirstFunc[*Job](time.Time.Compare),
+		func(j *Job) string { return j.ID },
+		func(j *Job) time.Time { return j.Deadline },
+	)
+	report := &Job{ID: "report", Deadline: base.Add(time.Hour)}
+	kpqs.Enqueue(pq, report)
+	kpqs.Enqueue(pq, &Job{ID: "deploy", Deadline: base.Add(time.Hour)})
+	kpqs.Enqueue(pq, &Job{ID: "backup", Deadline: base.Add(2 * time.Hour)})
+	report.Deadline = base.Add(3 * time.Hour)
+	assert.True(t, kpqs.Update(pq, report))
+
+	var got []string
+	for j := range kpqs.Drain(pq) {
+		got = append(got, j.ID)
+	}
+	assert.Equal(t, []string{"deploy", "backup", "report"}, got)
+
+	pq = kpqs.New(
+		kpqs.MaxFirstFunc[*Job](time.Time.Compare),
+		func(j *Job) string { return j.ID },
+		func(j *Job) time.Time { return j.Deadline },
+	)
+	kpqs.Enqueue(pq, &Job{ID: "early", Deadline: base})
+	kpqs.Enqueue(pq, &Job{ID: "late", Deadline: base.Add(time.Hour)})
+	top, _ := kpqs.Peek(pq)
+	assert.Equal(t, "late", top.ID)
+}
+
+func TestElemAccessors(t *testing.T) {
+	var seen []string
+	pq := kpqs.New(
+		func(x, y kpqs.Elem[*Task, int]) bool {
+			seen = append(seen, fmt.Sprintf("%s:%d:%d", x.Item().ID, x.Priority(), x.Sequence()))
+			return x.Priority() < y.Priority()
+		},
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 2})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	assert.Contains(t, seen, "b:1:2")
+}
+
+func TestNewWithBackend(t *testing.T) {
+	backends := []heaps.Factory[kpqs.Elem[*Task, int]]{
+		heaps.Quaternary[kpqs.Elem[*Task, int]],
+		heaps.Pairing[kpqs.Elem[*Task, int]],
+		heaps.Fibonacci[kpqs.Elem[*Task, int]],
+	}
+	for _, backend := range backends {
+		a, b, c := &Task{ID: "a", Priority: 3}, &Task{ID: "b", Priority: 2}, &Task{ID: "c", Priority: 1}
+		pq := kpqs.NewWithBackend(
+			kpqs.StableMinFirst[*Task, int],
+			func(t *Task) string { return t.ID },
+			func(t *Task) int { return t.Priority },
+			backend,
+		)
+		kpqs.Enqueue(pq, a)
+		kpqs.Enqueue(pq, b)
+		kpqs.Enqueue(pq, c)
+		a.Priority = 0
+		kpqs.Update(pq, a)
+		kpqs.Delete(pq, b)
+		var ids []string
+		for item := range kpqs.Drain(pq) {
+			ids = append(ids, item.ID)
+		}
+		assert.Equal(t, []string{"a", "c"}, ids)
+		assert.False(t, kpqs.ContainsKey(pq, "a"))
+	}
+}
+
+func newMergeQueue(policy kpqs.DuplicatePolicy) *kpqs.PriorityQueue[string, *Task, int] {
+	pq := kpqs.NewWithBackend(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+		heaps.Pairing,
+	)
+	kpqs.SetDuplicatePolicy(pq, policy)
+	return pq
+}
+
+func TestMerge(t *testing.T) {
+	dst := newMergeQueue(kpqs.Upsert)
+	src := newMergeQueue(kpqs.Upsert)
+	kpqs.Enqueue(dst, &Task{ID: "a", Priority: 2})
+	kpqs.Enqueue(src, &Task{ID: "b", Priority: 2})
+	kpqs.Enqueue(src, &Task{ID: "c", Priority: 1})
+
+	assert.NoError(t, kpqs.Merge(dst, src))
+	assert.Equal(t, 0, kpqs.Len(src))
+	assert.False(t, kpqs.ContainsKey(src, "b"))
+	assert.True(t, kpqs.ContainsKey(dst, "b"))
+
+	var got []string
+	for item := range kpqs.Drain(dst) {
+		got = append(got, item.ID)
+	}
+	assert.Equal(t, []string{"c", "a", "b"}, got)
+}
+
+func TestMergeCollisions(t *testing.T) {
+	cases := []struct {
+		policy kpqs.DuplicatePolicy
+		want   int
+	}{
+		{kpqs.Upsert, 9},
+		{kpqs.KeepBetter, 5},
+		{kpqs.KeepWorse, 9},
+	}
+	for _, c := range cases {
+		dst := newMergeQueue(c.policy)
+		src := newMergeQueue(c.policy)
+		kpqs.Enqueue(dst, &Task{ID: "a", Priority: 5})
+		kpqs.Enqueue(src, &Task{ID: "a", Priority: 9})
+		kpqs.Enqueue(src, &Task{ID: "b", Priority: 7})
+
+		assert.NoError(t, kpqs.Merge(dst, src))
+		assert.Equal(t, 2, kpqs.Len(dst))
+		prio, _ := kpqs.PriorityOf(dst, "a")
+		assert.Equal(t, c.want, prio)
+		assert.True(t, kpqs.UpdateByKey(dst, "a"))
+		assert.True(t, kpqs.UpdateByKey(dst, "b"))
+	}
+}
+
+func TestMergeRandomized(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, backend := range []heaps.Factory[kpqs.Elem[*Task, int]]{
+		heaps.Binary[kpqs.Elem[*Task, int]],
+		heaps.Quaternary[kpqs.Elem[*Task, int]],
+		heaps.Pairing[kpqs.Elem[*Task, int]],
+		heaps.Fibonacci[kpqs.Elem[*Task, int]],
+	} {
+		for _, policy := range []kpqs.DuplicatePolicy{kpqs.Upsert, kpqs.KeepBetter, kpqs.KeepWorse} {
+			for range 100 {
+				queues := make([]*kpqs.PriorityQueue[string, *Task, int], 2)
+				keys := make(map[string]bool)
+				for i := range queues {
+					queues[i] = kpqs.NewWithBackend(
+						kpqs.StableMinFirst[*Task, int],
+						func(t *Task) string { return t.ID },
+						func(t *Task) int { return t.Priority },
+						backend,
+					)
+					kpqs.SetDuplicatePolicy(queues[i], policy)
+					for range r.IntN(20) {
+						kpqs.Enqueue(queues[i], &Task{ID: fmt.Sprint(r.IntN(15)), Priority: r.IntN(5)})
+						if r.IntN(4) == 0 {
+							kpqs.Dequeue(queues[i])
+						}
+					}
+					for key := range kpqs.Keys(queues[i]) {
+						keys[key] = true
+					}
+				}
+				dst, src := queues[0], queues[1]
+				assert.NoError(t, kpqs.Merge(dst, src))
+				assert.Empty(t, kpqs.Validate(dst))
+				assert.Equal(t, len(keys), kpqs.Len(dst))
+			}
+		}
+	}
+}
+
+func TestMergeDifferentComparators(t *testing.T) {
+	for _, backend := range []heaps.Factory[kpqs.Elem[*Task, int]]{heaps.Pairing[kpqs.Elem[*Task, int]], heaps.Fibonacci[kpqs.Elem[*Task, int]]} {
+		keyFunc := func(t *Task) string { return t.ID }
+		prioFunc := func(t *Task) int { return t.Priority }
+		dst := kpqs.NewWithBackend(kpqs.StableMinFirst[*Task, int], keyFunc, prioFunc, backend)
+		src := kpqs.NewWithBackend(kpqs.StableMaxFirst[*Task, int], keyFunc, prioFunc, backend)
+		for _, prio := range []int{5, 1, 8} {
+			kpqs.Enqueue(dst, &Task{ID: fmt.Sprint(prio), Priority: prio})
+		}
+		for _, prio := range []int{2, 9, 3, 7} {
+			kpqs.Enqueue(src, &Task{ID: fmt.Sprint(prio), Priority: prio})
+		}
+		kpqs.Dequeue(src)
+		assert.NoError(t, kpqs.Merge(dst, src))
+		assert.Empty(t, kpqs.Validate(dst))
+		var got []string
+		for task := range kpqs.Drain(dst) {
+			got = append(got, task.ID)
+		}
+		assert.Equal(t, []string{"1", "2", "3", "5", "7", "8"}, got)
+	}
+}
+
+func TestMergeReject(t *testing.T) {
+	dst := newMergeQueue(kpqs.Reject)
+	src := newMergeQueue(kpqs.Reject)
+	kpqs.Enqueue(dst, &Task{ID: "a", Priority: 5})
+	kpqs.Enqueue(src, &Task{ID: "a", Priority: 9})
+	kpqs.Enqueue(src, &Task{ID: "b", Priority: 7})
+
+	assert.ErrorIs(t, kpqs.Merge(dst, src), kpqs.ErrDuplicateKey)
+	assert.Equal(t, 1, kpqs.Len(dst))
+	assert.Equal(t, 2, kpqs.Len(src))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	newQueue := func() *kpqs.PriorityQueue[string, *Task, int] {
+		return kpqs.New(
+			kpqs.StableMinFirst[*Task, int],
+			func(t *Task) string { return t.ID },
+			func(t *Task) int { return t.Priority },
+		)
+	}
+	pq := newQueue()
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 2})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	kpqs.Enqueue(pq, &Task{ID: "c", Priority: 2})
+
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+
+	restored := newQueue()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.True(t, kpqs.ContainsKey(restored, "c"))
+	prio, _ := kpqs.PriorityOf(restored, "c")
+	assert.Equal(t, 2, prio)
+	var got []string
+	for item := range kpqs.Drain(restored) {
+		got = append(got, item.ID)
+	}
+	assert.Equal(t, []string{"b", "a", "c"}, got)
+}
+
+func TestJSONNonFinitePriorities(t *testing.T) {
+	newQueue := func() *kpqs.PriorityQueue[string, *Task, float64] {
+		pq := kpqs.New(
+			kpqs.StableMinFirst[*Task, float64],
+			func(t *Task) string { return t.ID },
+			func(t *Task) float64 { return math.Sqrt(float64(t.Priority)) },
+		)
+		kpqs.SetNaNPolicy(pq, kpqs.NaNLast)
+		return pq
+	}
+	pq := newQueue()
+	kpqs.Enqueue(pq, &Task{ID: "negative", Priority: -1})
+	kpqs.Enqueue(pq, &Task{ID: "four", Priority: 4})
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"priority":"NaN"`)
+
+	restored := newQueue()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	prio, _ := kpqs.PriorityOf(restored, "negative")
+	assert.True(t, math.IsNaN(prio))
+	var got []string
+	for task := range kpqs.Drain(restored) {
+		got = append(got, task.ID)
+	}
+	assert.Equal(t, []string{"four", "negative"}, got)
+}
+
+func TestRestoreRejectsNaN(t *testing.T) {
+	newQueue := func() *kpqs.PriorityQueue[string, *Task, float64] {
+		pq := kpqs.New(
+			kpqs.StableMinFirst[*Task, float64],
+			func(t *Task) string { return t.ID },
+			func(t *Task) float64 { return math.Sqrt(float64(t.Priority)) },
+		)
+		kpqs.SetCodec(pq, snapshot.JSONCodec[*Task]())
+		return pq
+	}
+	pq := newQueue()
+	kpqs.Enqueue(pq, &Task{ID: "negative", Priority: -1})
+	kpqs.Enqueue(pq, &Task{ID: "four", Priority: 4})
+	text, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	for _, restore := range []func(*kpqs.PriorityQueue[string, *Task, float64]) error{
+		func(q *kpqs.PriorityQueue[string, *Task, float64]) error { return json.Unmarshal(text, q) },
+		func(q *kpqs.PriorityQueue[string, *Task, float64]) error { return q.UnmarshalBinary(data) },
+	} {
+		restored := newQueue()
+		kpqs.SetNaNPolicy(restored, kpqs.RejectNaN)
+		assert.NoError(t, restore(restored))
+		assert.Equal(t, 1, kpqs.Len(restored))
+		assert.False(t, kpqs.ContainsKey(restored, "negative"))
+	}
+}
+
+func TestUnmarshalJSONDuplicateKey(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.MinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "x", Priority: 1})
+	data := []byte(`[{"item":{"ID":"a"},"priority":1,"seq":1},{"item":{"ID":"a"},"priority":2,"seq":2}]`)
+	assert.ErrorIs(t, json.Unmarshal(data, pq), kpqs.ErrDuplicateKey)
+	assert.True(t, kpqs.ContainsKey(pq, "x"))
+
+	var zero kpqs.PriorityQueue[string, *Task, int]
+	assert.Error(t, json.Unmarshal([]byte(`[]`), &zero))
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	newQueue := func() *kpqs.PriorityQueue[string, *Task, int] {
+		pq := kpqs.New(
+			kpqs.StableMinFirst[*Task, int],
+			func(t *Task) string { return t.ID },
+			func(t *Task) int { return t.Priority },
+		)
+		kpqs.SetCodec(pq, snapshot.JSONCodec[*Task]())
+		return pq
+	}
+	pq := newQueue()
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 2})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	kpqs.Enqueue(pq, &Task{ID: "c", Priority: 2})
+
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	restored := newQueue()
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.True(t, kpqs.ContainsKey(restored, "b"))
+	var got []string
+	for item := range kpqs.Drain(restored) {
+		got = append(got, item.ID)
+	}
+	assert.Equal(t, []string{"b", "a", "c"}, got)
+}
+
+func TestBinaryMismatch(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.SetCodec(pq, snapshot.JSONCodec[*Task]())
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 2})
+	data, _ := pq.MarshalBinary()
+
+	other := kpqs.New(
+		kpqs.MaxFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.SetCodec(other, snapshot.JSONCodec[*Task]())
+	assert.ErrorIs(t, other.UnmarshalBinary(data), snapshot.ErrMismatch)
+	assert.Equal(t, 0, kpqs.Len(other))
+}
+
+func TestHooks(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	var events []hooks.Event[*Task, int]
+	kpqs.AddHook(pq, func(e hooks.Event[*Task, int]) { events = append(events, e) })
+
+	a, b := &Task{ID: "a", Priority: 5}, &Task{ID: "b", Priority: 3}
+	kpqs.Enqueue(pq, a)
+	kpqs.Enqueue(pq, b)
+	a.Priority = 1
+	kpqs.UpdateByKey(pq, "a")
+	kpqs.Delete(pq, b)
+	for range kpqs.Drain(pq) {
+	}
+	kpqs.Clear(pq)
+
+	assert.Equal(t, []hooks.Event[*Task, int]{
+		{Op: hooks.Enqueue, Item: a, Priority: 5, Sequence: 1, Len: 1},
+		{Op: hooks.Enqueue, Item: b, Priority: 3, Sequence: 2, Len: 2},
+		{Op: hooks.Update, Item: a, Priority: 1, Sequence: 3, PrevPriority: 5, PrevSequence: 1, Len: 2},
+		{Op: hooks.Delete, Item: b, Priority: 3, Sequence: 2, Len: 1},
+		{Op: hooks.Dequeue, Item: a, Priority: 1, Sequence: 3, Len: 0},
+		{Op: hooks.Clear},
+	}, events)
+}
+
+func TestHooksMerge(t *testing.T) {
+	dst := newMergeQueue(kpqs.Upsert)
+	src := newMergeQueue(kpqs.Upsert)
+	var events []hooks.Event[*Task, int]
+	kpqs.AddHook(dst, func(e hooks.Event[*Task, int]) { events = append(events, e) })
+
+	a, b := &Task{ID: "a", Priority: 5}, &Task{ID: "b", Priority: 3}
+	a2 := &Task{ID: "a", Priority: 9}
+	kpqs.Enqueue(dst, a)
+	kpqs.Enqueue(dst, b)
+	kpqs.Enqueue(src, a2)
+	events = nil
+	assert.NoError(t, kpqs.Merge(dst, src))
+
+	// The element replacing a is reported as an update, as an upsert would be.
+	assert.Equal(t, []hooks.Event[*Task, int]{
+		{Op: hooks.Update, Item: a2, Priority: 9, Sequence: 4, PrevPriority: 5, PrevSequence: 1, Len: 2},
+	}, events)
+}
+
+func TestValidate(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	a := &Task{ID: "a", Priority: 1}
+	kpqs.Enqueue(pq, a)
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 2})
+	assert.Empty(t, kpqs.Validate(pq))
+
+	// Changing a key in place breaks the lookup table.
+	corrupt := func() {
+		a.ID = "z"
+		kpqs.Enqueue(pq, &Task{ID: "c", Priority: 3})
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	errs := kpqs.Validate(pq)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "kpqs: key a maps to an element with key z")
+}
+
+func TestFormat(t *testing.T) {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 2})
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 1})
+	s := fmt.Sprintf("%v", pq)
+	assert.Equal(t, 2, strings.Count(s, "\n")+1)
+	assert.Contains(t, s, "(priority 1, seq 2)\n└── ")
+
+	var sb strings.Builder
+	assert.NoError(t, pq.WriteDOT(&sb))
+	assert.Contains(t, sb.String(), `\npriority 2\nseq 1"];`)
+}
+
+type scored struct {
+	ID    string
+	Score float64
+}
+
+func newScoredQueue() *kpqs.PriorityQueue[string, *scored, float64] {
+	return kpqs.New(
+		kpqs.StableMaxFirst[*scored, float64],
+		func(s *scored) string { return s.ID },
+		func(s *scored) float64 { return s.Score },
+	)
+}
+
+func TestNaNPolicy(t *testing.T) {
+	pq := newScoredQueue()
+	kpqs.SetNaNPolicy(pq, kpqs.NaNLast)
+	a, b, c := &scored{"a", 0.5}, &scored{"b", math.NaN()}, &scored{"c", 0.9}
+	for _, s := range []*scored{a, b, c} {
+		assert.NoError(t, kpqs.Enqueue(pq, s))
+	}
+	c.Score = math.NaN()
+	assert.True(t, kpqs.UpdateByKey(pq, "c"))
+	assert.Empty(t, kpqs.Validate(pq))
+	var got []string
+	for s := range kpqs.Drain(pq) {
+		got = append(got, s.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, got)
+
+	pq = newScoredQueue()
+	kpqs.Enqueue(pq, a)
+	kpqs.Enqueue(pq, b)
+	kpqs.SetNaNPolicy(pq, kpqs.NaNFirst)
+	s, _ := kpqs.Peek(pq)
+	assert.Equal(t, "b", s.ID)
+
+	kpqs.SetNaNPolicy(pq, kpqs.RejectNaN)
+	assert.False(t, kpqs.ContainsKey(pq, "b"))
+	assert.ErrorIs(t, kpqs.Enqueue(pq, c), kpqs.ErrNaN)
+	a.Score = math.NaN()
+	assert.False(t, kpqs.Update(pq, a))
+	assert.False(t, kpqs.UpdateByKey(pq, "a"))
+	prio, _ := kpqs.PriorityOf(pq, "a")
+	assert.Equal(t, 0.5, prio)
+}
+
 func ExampleNew() {
 	type Task struct {
 		ID       string
@@ -285,3 +951,55 @@ func ExampleContains() {
 	// Output:
 	// true
 }
+
+func ExampleDrain() {
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, &Task{ID: "a", Priority: 3})
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 1})
+	for item, prio := range kpqs.Drain(pq) {
+		fmt.Println(item.ID, prio)
+	}
+	// Output:
+	// b 1
+	// a 3
+}
+
+func ExampleUpdateByKey() {
+	a := &Task{ID: "a", Priority: 5}
+	pq := kpqs.New(
+		kpqs.StableMinFirst[*Task, int],
+		func(t *Task) string { return t.ID },
+		func(t *Task) int { return t.Priority },
+	)
+	kpqs.Enqueue(pq, a)
+	kpqs.Enqueue(pq, &Task{ID: "b", Priority: 3})
+	a.Priority = 1
+	kpqs.UpdateByKey(pq, "a")
+	item, _ := kpqs.Peek(pq)
+	fmt.Println(item.ID)
+	// Output: a
+}
+
+func ExampleMerge() {
+	newQueue := func() *kpqs.PriorityQueue[string, *Task, int] {
+		return kpqs.New(
+			kpqs.StableMinFirst[*Task, int],
+			func(t *Task) string { return t.ID },
+			func(t *Task) int { return t.Priority },
+		)
+	}
+	dst, src := newQueue(), newQueue()
+	kpqs.Enqueue(dst, &Task{ID: "a", Priority: 2})
+	kpqs.Enqueue(src, &Task{ID: "b", Priority: 1})
+	kpqs.Merge(dst, src)
+	for task := range kpqs.Drain(dst) {
+		fmt.Println(task.ID)
+	}
+	// Output:
+	// b
+	// a
+}
@@ -0,0 +1,57 @@
+package kpqs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/internal/debug"
+)
+
+// Validate checks the invariants of the priority queue and returns every violation found, or nil
+// if there is none. It checks the heap order under the queue's comparator and the structure of the
+// heap, see heaps.Validate: order violations wrap a *heaps.OrderError, and a comparator that is not
+// a strict weak ordering, such as one comparing NaN priorities, is reported with heaps.ErrComparator.
+// It also checks that every key in the lookup table maps to a queued element with that key and that
+// every element is in the lookup table, which fails when the key of an item is changed in place.
+// Validate takes linear time.
+//
+// Building with the pqdebug tag makes every mutating operation validate the queue and panic on a violation.
+func Validate[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) []error {
+	errs := heaps.Validate(pq.heap.backend)
+	for i, err := range errs {
+		var oe *heaps.OrderError[Elem[T, P]]
+		if errors.As(err, &oe) {
+			n, a := oe.Node.Value, oe.Ancestor.Value
+			errs[i] = fmt.Errorf("%w: %v with priority %v is below %v with priority %v", err, n.item, n.prio, a.item, a.prio)
+		}
+	}
+
+	queued := make(map[*heaps.Node[Elem[T, P]]]bool, pq.heap.Len())
+	for node := range pq.heap.backend.All() {
+		queued[node] = false
+	}
+	for key, node := range pq.heap.lookup {
+		if _, ok := queued[node]; !ok {
+			errs = append(errs, fmt.Errorf("kpqs: key %v maps to an element that is not queued", key))
+			continue
+		}
+		queued[node] = true
+		if k := pq.heap.keyFunc(node.Value.item); k != key {
+			errs = append(errs, fmt.Errorf("kpqs: key %v maps to an element with key %v", key, k))
+		}
+	}
+	for node, indexed := range queued {
+		if !indexed {
+			errs = append(errs, fmt.Errorf("kpqs: element with key %v is missing from the lookup table", pq.heap.keyFunc(node.Value.item)))
+		}
+	}
+	return errs
+}
+
+// check validates the priority queue when built with the pqdebug tag.
+func check[K comparable, T any, P any](pq *PriorityQueue[K, T, P]) {
+	if debug.Enabled {
+		debug.Check(Validate(pq))
+	}
+}
@@ -0,0 +1,196 @@
+// Package mlfq provides a multi-level feedback queue scheduler for cooperative tasks.
+//
+// Tasks start at the highest priority level 0. Each level has a time quantum: a task
+// that has run for its level's quantum in total is demoted one level, while a task that
+// yields early keeps its level. Tasks at the same level are served round robin.
+// A periodic boost moves every task back to level 0 so long-running tasks cannot starve.
+//
+// Runnable tasks are kept in a kmpqs.PriorityQueue keyed by task ID and ordered by level.
+// A Scheduler is not safe for concurrent use; callers must synchronize access.
+package mlfq
+
+import (
+	"errors"
+	"time"
+
+	"github.com/byExist/priorityqueues/kmpqs"
+)
+
+var (
+	// ErrDuplicateTask is returned by Submit when a task with the same ID is already scheduled.
+	ErrDuplicateTask = errors.New("mlfq: duplicate task")
+	// ErrUnknownTask is returned when no task with the given ID is scheduled.
+	ErrUnknownTask = errors.New("mlfq: unknown task")
+	// ErrNotRunning is returned by Yield when the task was not handed out by Next.
+	ErrNotRunning = errors.New("mlfq: task not running")
+)
+
+// Task is a task handed out by Next.
+type Task[K comparable, T any] struct {
+	// ID identifies the task.
+	ID K
+	// Value is the value the task was submitted with.
+	Value T
+	// Level is the task's current priority level; 0 is the highest.
+	Level int
+	// Quantum is how long the task may run before it is demoted.
+	Quantum time.Duration
+}
+
+type task[T any] struct {
+	value   T
+	level   int
+	used    time.Duration
+	running bool
+}
+
+// Scheduler is a multi-level feedback queue of tasks with IDs of type K and values of type T.
+type Scheduler[K comparable, T any] struct {
+	quanta   []time.Duration
+	runnable *kmpqs.PriorityQueue[K, K, int]
+	tasks    map[K]*task[T]
+
+	now           func() time.Time
+	boostInterval time.Duration
+	lastBoost     time.Time
+}
+
+// New creates a scheduler with one level per quantum, from the highest priority level to the lowest.
+// Lower levels usually get longer quanta. New panics if no quantum is given or a quantum is not positive.
+func New[K comparable, T any](quanta ...time.Duration) *Scheduler[K, T] {
+	if len(quanta) == 0 {
+		panic("mlfq: no levels")
+	}
+	for _, q := range quanta {
+		if q <= 0 {
+			panic("mlfq: quantum must be positive")
+		}
+	}
+	return &Scheduler[K, T]{
+		quanta:    append([]time.Duration(nil), quanta...),
+		runnable:  kmpqs.New(kmpqs.StableMinFirst[K, int], func(id K) K { return id }),
+		tasks:     make(map[K]*task[T]),
+		now:       time.Now,
+		lastBoost: time.Now(),
+	}
+}
+
+// SetClock sets the function the scheduler uses to time priority boosts. The default is time.Now.
+func SetClock[K comparable, T any](s *Scheduler[K, T], now func() time.Time) {
+	s.now = now
+	s.lastBoost = now()
+}
+
+// SetBoostInterval makes Next boost every task to level 0 once interval has passed since
+// the last boost. Zero or a negative interval disables periodic boosts.
+func SetBoostInterval[K comparable, T any](s *Scheduler[K, T], interval time.Duration) {
+	s.boostInterval = interval
+	s.lastBoost = s.now()
+}
+
+// Submit schedules a new task at level 0.
+// It returns ErrDuplicateTask if a task with the same ID is already scheduled.
+func Submit[K comparable, T any](s *Scheduler[K, T], id K, value T) error {
+	if _, exists := s.tasks[id]; exists {
+		return ErrDuplicateTask
+	}
+	s.tasks[id] = &task[T]{value: value}
+	return kmpqs.Enqueue(s.runnable, id, 0)
+}
+
+// Next hands out the runnable task at the highest level, first in first out within a level,
+// and marks it running until it is passed to Yield or Complete.
+// The boolean return value indicates whether a task was runnable.
+func Next[K comparable, T any](s *Scheduler[K, T]) (Task[K, T], bool) {
+	if s.boostInterval > 0 && s.now().Sub(s.lastBoost) >= s.boostInterval {
+		Boost(s)
+	}
+	id, ok := kmpqs.Dequeue(s.runnable)
+	if !ok {
+		return Task[K, T]{}, false
+	}
+	t := s.tasks[id]
+	t.running = true
+	return Task[K, T]{
+		ID:      id,
+		Value:   t.value,
+		Level:   t.level,
+		Quantum: s.quanta[t.level] - t.used,
+	}, true
+}
+
+// Yield returns a running task to the scheduler after it ran for the given duration.
+// A task that has used up its level's quantum, across all its runs at that level, is
+// demoted one level; the lowest level keeps its tasks. The task is queued behind the
+// other tasks at its new level.
+// It returns ErrUnknownTask or ErrNotRunning if id is not a running task.
+func Yield[K comparable, T any](s *Scheduler[K, T], id K, ran time.Duration) error {
+	t, exists := s.tasks[id]
+	if !exists {
+		return ErrUnknownTask
+	}
+	if !t.running {
+		return ErrNotRunning
+	}
+	t.running = false
+	t.used += ran
+	if t.used >= s.quanta[t.level] {
+		t.level = min(t.level+1, len(s.quanta)-1)
+		t.used = 0
+	}
+	return kmpqs.Enqueue(s.runnable, id, t.level)
+}
+
+// Complete removes a task from the scheduler, whether it is running or waiting to run.
+// It returns ErrUnknownTask if no task with the given ID is scheduled.
+func Complete[K comparable, T any](s *Scheduler[K, T], id K) error {
+	if _, exists := s.tasks[id]; !exists {
+		return ErrUnknownTask
+	}
+	delete(s.tasks, id)
+	kmpqs.DeleteByKey(s.runnable, id)
+	return nil
+}
+
+// Boost moves every task to level 0 and resets the time it has used there.
+// Waiting tasks keep their relative order within the levels they came from, with tasks
+// from higher levels first. Running tasks return to level 0 on Yield.
+func Boost[K comparable, T any](s *Scheduler[K, T]) {
+	s.lastBoost = s.now()
+	for _, t := range s.tasks {
+		t.level, t.used = 0, 0
+	}
+	// Drain in order so the sequence numbers given by Enqueue keep the relative order.
+	var ids []K
+	for id := range kmpqs.Drain(s.runnable) {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		kmpqs.Enqueue(s.runnable, id, 0)
+	}
+}
+
+// Level returns the current level of the task with the given ID.
+// The boolean return indicates whether the task is scheduled.
+func Level[K comparable, T any](s *Scheduler[K, T], id K) (int, bool) {
+	t, exists := s.tasks[id]
+	if !exists {
+		return 0, false
+	}
+	return t.level, true
+}
+
+// Len returns the number of scheduled tasks, running or waiting.
+func Len[K comparable, T any](s *Scheduler[K, T]) int {
+	return len(s.tasks)
+}
+
+// Runnable returns the number of tasks waiting to be handed out by Next.
+func Runnable[K comparable, T any](s *Scheduler[K, T]) int {
+	return kmpqs.Len(s.runnable)
+}
+
+// Levels returns the number of levels of the scheduler.
+func Levels[K comparable, T any](s *Scheduler[K, T]) int {
+	return len(s.quanta)
+}
@@ -0,0 +1,169 @@
+package mlfq_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/mlfq"
+	"github.com/stretchr/testify/assert"
+)
+
+const ms = time.Millisecond
+
+func next(t *testing.T, s *mlfq.Scheduler[string, int]) mlfq.Task[string, int] {
+	t.Helper()
+	task, ok := mlfq.Next(s)
+	assert.True(t, ok)
+	return task
+}
+
+func TestNewPanics(t *testing.T) {
+	assert.Panics(t, func() { mlfq.New[string, int]() })
+	assert.Panics(t, func() { mlfq.New[string, int](10*ms, 0) })
+}
+
+func TestSubmitAndRoundRobin(t *testing.T) {
+	s := mlfq.New[string, int](10*ms, 20*ms)
+	assert.NoError(t, mlfq.Submit(s, "a", 1))
+	assert.NoError(t, mlfq.Submit(s, "b", 2))
+	assert.ErrorIs(t, mlfq.Submit(s, "a", 3), mlfq.ErrDuplicateTask)
+	assert.Equal(t, 2, mlfq.Len(s))
+	assert.Equal(t, 2, mlfq.Levels(s))
+
+	task := next(t, s)
+	assert.Equal(t, mlfq.Task[string, int]{ID: "a", Value: 1, Level: 0, Quantum: 10 * ms}, task)
+	assert.Equal(t, 1, mlfq.Runnable(s))
+	assert.NoError(t, mlfq.Yield(s, "a", 3*ms))
+
+	// Yielding early keeps the level but goes behind the other tasks of that level.
+	task = next(t, s)
+	assert.Equal(t, "b", task.ID)
+	assert.NoError(t, mlfq.Yield(s, "b", ms))
+	task = next(t, s)
+	assert.Equal(t, "a", task.ID)
+	assert.Equal(t, 0, task.Level)
+	assert.Equal(t, 7*ms, task.Quantum)
+}
+
+func TestDemotion(t *testing.T) {
+	s := mlfq.New[string, int](10*ms, 20*ms, 40*ms)
+	mlfq.Submit(s, "cpu", 0)
+	for _, want := range []int{0, 1, 2, 2} {
+		task := next(t, s)
+		assert.Equal(t, want, task.Level)
+		assert.NoError(t, mlfq.Yield(s, "cpu", task.Quantum))
+	}
+	level, ok := mlfq.Level(s, "cpu")
+	assert.True(t, ok)
+	assert.Equal(t, 2, level)
+
+	// Higher levels always run first, and many short runs add up to the quantum too.
+	mlfq.Submit(s, "io", 0)
+	for range 99 {
+		task := next(t, s)
+		assert.Equal(t, "io", task.ID)
+		assert.NoError(t, mlfq.Yield(s, "io", ms/10))
+	}
+	level, _ = mlfq.Level(s, "io")
+	assert.Equal(t, 0, level)
+	task := next(t, s)
+	assert.Equal(t, ms/10, task.Quantum)
+	mlfq.Yield(s, "io", ms/10)
+	level, _ = mlfq.Level(s, "io")
+	assert.Equal(t, 1, level)
+}
+
+func TestComplete(t *testing.T) {
+	s := mlfq.New[string, int](10 * ms)
+	mlfq.Submit(s, "a", 1)
+	mlfq.Submit(s, "b", 2)
+
+	task := next(t, s)
+	assert.NoError(t, mlfq.Complete(s, task.ID))
+	assert.NoError(t, mlfq.Complete(s, "b"))
+	assert.ErrorIs(t, mlfq.Complete(s, "b"), mlfq.ErrUnknownTask)
+	assert.Equal(t, 0, mlfq.Len(s))
+	_, ok := mlfq.Next(s)
+	assert.False(t, ok)
+	_, ok = mlfq.Level(s, "a")
+	assert.False(t, ok)
+
+	// A completed ID can be submitted again.
+	assert.NoError(t, mlfq.Submit(s, "a", 3))
+}
+
+func TestYieldErrors(t *testing.T) {
+	s := mlfq.New[string, int](10 * ms)
+	assert.ErrorIs(t, mlfq.Yield(s, "a", ms), mlfq.ErrUnknownTask)
+	mlfq.Submit(s, "a", 1)
+	assert.ErrorIs(t, mlfq.Yield(s, "a", ms), mlfq.ErrNotRunning)
+	next(t, s)
+	assert.NoError(t, mlfq.Yield(s, "a", ms))
+	assert.ErrorIs(t, mlfq.Yield(s, "a", ms), mlfq.ErrNotRunning)
+}
+
+func TestBoost(t *testing.T) {
+	s := mlfq.New[string, int](10*ms, 20*ms, 40*ms)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mlfq.SetClock(s, func() time.Time { return now })
+	mlfq.SetBoostInterval(s, time.Second)
+
+	mlfq.Submit(s, "a", 0)
+	mlfq.Submit(s, "b", 0)
+	// Demote a to level 2 and b to level 1.
+	for range 3 {
+		task := next(t, s)
+		mlfq.Yield(s, task.ID, task.Quantum)
+	}
+	la, _ := mlfq.Level(s, "a")
+	lb, _ := mlfq.Level(s, "b")
+	assert.Equal(t, []int{2, 1}, []int{la, lb})
+
+	mlfq.Submit(s, "c", 0)
+	running := next(t, s)
+	assert.Equal(t, "c", running.ID)
+
+	now = now.Add(time.Second)
+	task := next(t, s)
+	assert.Equal(t, "b", task.ID)
+	assert.Equal(t, 0, task.Level)
+	assert.Equal(t, 10*ms, task.Quantum)
+	la, _ = mlfq.Level(s, "a")
+	assert.Equal(t, 0, la)
+
+	// The task running during the boost returns at level 0.
+	assert.NoError(t, mlfq.Yield(s, "c", 5*ms))
+	lc, _ := mlfq.Level(s, "c")
+	assert.Equal(t, 0, lc)
+	task = next(t, s)
+	assert.Equal(t, "a", task.ID)
+	task = next(t, s)
+	assert.Equal(t, "c", task.ID)
+	assert.Equal(t, 5*ms, task.Quantum)
+}
+
+func Example() {
+	s := mlfq.New[string, string](10*time.Millisecond, 50*time.Millisecond)
+	mlfq.Submit(s, "index", "rebuild search index")
+	mlfq.Submit(s, "render", "draw frame")
+
+	for range 3 {
+		task, _ := mlfq.Next(s)
+		fmt.Println(task.ID, task.Level, task.Quantum)
+		if task.ID == "index" {
+			// The indexer runs until it is preempted and sinks to a lower level.
+			mlfq.Yield(s, task.ID, task.Quantum)
+		} else {
+			mlfq.Yield(s, task.ID, time.Millisecond)
+		}
+	}
+	mlfq.Complete(s, "render")
+	task, _ := mlfq.Next(s)
+	fmt.Println(task.ID, task.Level, task.Quantum)
+	// Output:
+	// index 0 10ms
+	// render 0 10ms
+	// render 0 9ms
+	// index 1 50ms
+}
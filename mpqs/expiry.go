@@ -0,0 +1,106 @@
+package mpqs
+
+import (
+	"slices"
+	"time"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+)
+
+// SetClock sets the function the priority queue uses to tell whether elements have expired.
+// The default is time.Now.
+func SetClock[T any, P any](pq *PriorityQueue[T, P], now func() time.Time) {
+	pq.now = now
+}
+
+// SetExpiredFunc sets a function called with every expired element the queue drops, whether it is
+// skipped by Peek, Dequeue or Drain, reaped by Expire, or dropped to make room in a full queue.
+// It can be used to dead-letter stale work. The function must not modify the queue.
+func SetExpiredFunc[T any, P any](pq *PriorityQueue[T, P], expiredFunc func(Elem[T, P])) {
+	pq.expiredFunc = expiredFunc
+}
+
+// EnqueueWithExpiry inserts a new item with the given priority that expires at expiresAt.
+// Once the queue's clock reaches expiresAt, the element is no longer served by Peek, Dequeue or Drain.
+// It returns ErrNaN if prio is NaN and the NaN policy is RejectNaN.
+func EnqueueWithExpiry[T any, P any](pq *PriorityQueue[T, P], item T, prio P, expiresAt time.Time) error {
+	if rejected(pq, prio) {
+		return ErrNaN
+	}
+	offer(pq, Elem[T, P]{
+		item:   item,
+		prio:   prio,
+		seq:    pq.counter(),
+		expiry: expiresAt,
+	})
+	return nil
+}
+
+// EnqueueWithTTL inserts a new item with the given priority that expires after ttl has elapsed
+// on the queue's clock. It returns ErrNaN if prio is NaN and the NaN policy is RejectNaN.
+func EnqueueWithTTL[T any, P any](pq *PriorityQueue[T, P], item T, prio P, ttl time.Duration) error {
+	return EnqueueWithExpiry(pq, item, prio, pq.now().Add(ttl))
+}
+
+// Expire removes every element that has expired at now and returns them in priority order.
+// It scans the whole queue, so it costs O(n) plus O(log n) per removed element.
+func Expire[T any, P any](pq *PriorityQueue[T, P], now time.Time) []Elem[T, P] {
+	expired := expire(pq, now)
+	slices.SortFunc(expired, func(x, y Elem[T, P]) int {
+		switch {
+		case pq.less(x, y):
+			return -1
+		case pq.less(y, x):
+			return 1
+		}
+		return 0
+	})
+	return expired
+}
+
+func expire[T any, P any](pq *PriorityQueue[T, P], now time.Time) []Elem[T, P] {
+	var nodes []*heaps.Node[Elem[T, P]]
+	for node := range pq.heap.All() {
+		if expiredAt(node.Value, now) {
+			nodes = append(nodes, node)
+		}
+	}
+	expired := make([]Elem[T, P], 0, len(nodes))
+	for _, node := range nodes {
+		elem := remove(pq, node)
+		expired = append(expired, elem)
+		reportExpired(pq, elem)
+	}
+	return expired
+}
+
+// skipExpired drops expired elements from the top of the heap.
+func skipExpired[T any, P any](pq *PriorityQueue[T, P]) {
+	var now time.Time
+	for pq.heap.Len() > 0 {
+		elem := pq.heap.Peek().Value
+		if elem.expiry.IsZero() {
+			return
+		}
+		if now.IsZero() {
+			now = pq.now()
+		}
+		if !expiredAt(elem, now) {
+			return
+		}
+		reportExpired(pq, pop(pq))
+	}
+}
+
+// expiredAt reports whether elem has an expiry and it is not after now.
+func expiredAt[T any, P any](elem Elem[T, P], now time.Time) bool {
+	return !elem.expiry.IsZero() && !now.Before(elem.expiry)
+}
+
+func reportExpired[T any, P any](pq *PriorityQueue[T, P], elem Elem[T, P]) {
+	emit(pq, hooks.Delete, elem)
+	if pq.expiredFunc != nil {
+		pq.expiredFunc(elem)
+	}
+}
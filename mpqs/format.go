@@ -0,0 +1,54 @@
+package mpqs
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/byExist/priorityqueues/heaps"
+)
+
+// Format implements fmt.Formatter. It prints the heap as an indented tree that mirrors the layout of
+// the queue's backend, one element per line with its item, priority and sequence number. The %v and %s
+// verbs format items with %v and %q quotes them. %+v also shows expiries. The tree shows heap layout,
+// not priority order.
+func (pq *PriorityQueue[T, P]) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's', 'q':
+		itemVerb := "%v"
+		if verb == 'q' {
+			itemVerb = "%q"
+		}
+		heaps.WriteTree(f, pq.heap, func(e Elem[T, P]) string {
+			item, props := describe(e, itemVerb, verb == 'v' && f.Flag('+'))
+			return item + " (" + strings.Join(props, ", ") + ")"
+		})
+	default:
+		fmt.Fprintf(f, "%%!%c(*mpqs.PriorityQueue)", verb)
+	}
+}
+
+// String returns the heap as an indented tree, as printed by the %v verb.
+func (pq *PriorityQueue[T, P]) String() string {
+	return fmt.Sprint(pq)
+}
+
+// WriteDOT writes the heap to w as a Graphviz digraph that mirrors the layout of the queue's backend,
+// with a node per element showing its item, priority and sequence number. Expiries are included. The
+// element at the top is drawn in bold.
+func (pq *PriorityQueue[T, P]) WriteDOT(w io.Writer) error {
+	return heaps.WriteDOT(w, pq.heap, "mpqs", func(e Elem[T, P]) string {
+		item, props := describe(e, "%v", true)
+		return item + "\n" + strings.Join(props, "\n")
+	})
+}
+
+// describe formats the item of e with itemVerb and lists the properties shown next to it.
+func describe[T any, P any](e Elem[T, P], itemVerb string, verbose bool) (string, []string) {
+	props := []string{fmt.Sprintf("priority %v", e.prio), fmt.Sprintf("seq %d", e.seq)}
+	if verbose && !e.expiry.IsZero() {
+		props = append(props, "expires "+e.expiry.Format(time.RFC3339Nano))
+	}
+	return fmt.Sprintf(itemVerb, e.item), props
+}
@@ -0,0 +1,71 @@
+package mpqs
+
+import (
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+)
+
+// Handle refers to an element enqueued by Enqueue. It stays valid while the element is queued,
+// including after Merge moves it into another queue, and becomes invalid once the element is
+// dequeued, removed, evicted, dropped after expiring or cleared, or the queue is restored from a
+// snapshot. The zero Handle is never valid.
+type Handle[T any, P any] struct {
+	ref *ref[T, P]
+}
+
+// ref is shared by a Handle and its element, and is reset when the element leaves its queue.
+type ref[T any, P any] struct {
+	pq   *PriorityQueue[T, P]
+	node *heaps.Node[Elem[T, P]]
+}
+
+// Valid reports whether h refers to an element queued in pq.
+func Valid[T any, P any](pq *PriorityQueue[T, P], h Handle[T, P]) bool {
+	return h.ref != nil && h.ref.pq == pq
+}
+
+// UpdatePriority changes the priority of the element h refers to and restores the heap order in O(log n).
+// Like kmpqs.Update, the element gets a new sequence number, so stable comparators place it after
+// elements that already have the same priority. It returns false and leaves the queue unchanged
+// if h is not valid for pq or newPrio is NaN and the NaN policy is RejectNaN.
+func UpdatePriority[T any, P any](pq *PriorityQueue[T, P], h Handle[T, P], newPrio P) bool {
+	if !Valid(pq, h) || rejected(pq, newPrio) {
+		return false
+	}
+	node := h.ref.node
+	prev := node.Value
+	node.Value.prio = newPrio
+	node.Value.seq = pq.counter()
+	pq.heap.Fix(node)
+	emitUpdate(pq, hooks.Update, node.Value, prev)
+	return true
+}
+
+// Remove removes the element h refers to from the priority queue in O(log n) and returns its item.
+// The boolean return value indicates whether h was valid for pq and the element was removed.
+func Remove[T any, P any](pq *PriorityQueue[T, P], h Handle[T, P]) (T, bool) {
+	if !Valid(pq, h) {
+		var zero T
+		return zero, false
+	}
+	elem := remove(pq, h.ref.node)
+	emit(pq, hooks.Delete, elem)
+	return elem.item, true
+}
+
+// release invalidates the handle of an element that left its queue.
+func release[T any, P any](elem Elem[T, P]) {
+	if elem.ref != nil {
+		*elem.ref = ref[T, P]{}
+	}
+}
+
+// relink points handles at the nodes of pq, after a rebuild replaced the nodes of a backend
+// implemented outside the heaps package.
+func relink[T any, P any](pq *PriorityQueue[T, P]) {
+	for node := range pq.heap.All() {
+		if r := node.Value.ref; r != nil {
+			r.node = node
+		}
+	}
+}
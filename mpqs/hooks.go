@@ -0,0 +1,35 @@
+package mpqs
+
+import "github.com/byExist/priorityqueues/hooks"
+
+// AddHook registers a function called after every enqueue, dequeue, update, delete and clear
+// on the priority queue. UpdatePriority is reported as an update. Elements evicted from a full
+// queue, trimmed by SetMaxLen or dropped after expiring are reported as deletes; a new element
+// rejected by a full queue is not reported. Merge reports a clear on src and an enqueue on dst
+// for every element it moves, and restoring a snapshot reports nothing. Hooks must not modify the queue.
+func AddHook[T any, P any](pq *PriorityQueue[T, P], hook hooks.Hook[T, P]) {
+	pq.hooks = append(pq.hooks, hook)
+}
+
+func emit[T any, P any](pq *PriorityQueue[T, P], op hooks.Op, elem Elem[T, P]) {
+	emitUpdate(pq, op, elem, Elem[T, P]{})
+}
+
+func emitUpdate[T any, P any](pq *PriorityQueue[T, P], op hooks.Op, elem, prev Elem[T, P]) {
+	check(pq)
+	if len(pq.hooks) == 0 {
+		return
+	}
+	e := hooks.Event[T, P]{
+		Op:           op,
+		Item:         elem.item,
+		Priority:     elem.prio,
+		Sequence:     elem.seq,
+		PrevPriority: prev.prio,
+		PrevSequence: prev.seq,
+		Len:          pq.heap.Len(),
+	}
+	for _, hook := range pq.hooks {
+		hook(e)
+	}
+}
@@ -0,0 +1,69 @@
+package mpqs
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+type jsonElem[T any, P any] struct {
+	Item     T           `json:"item"`
+	Priority nan.JSON[P] `json:"priority"`
+	Sequence int         `json:"seq"`
+	Expiry   *time.Time  `json:"expiry,omitempty"`
+}
+
+// MarshalJSON encodes the elements of the priority queue as a JSON array of objects
+// with item, priority and seq fields, plus expiry for elements that expire, in insertion order.
+// NaN and infinite priorities are encoded as the strings "NaN", "+Inf" and "-Inf".
+func (pq *PriorityQueue[T, P]) MarshalJSON() ([]byte, error) {
+	elems := make([]jsonElem[T, P], 0, pq.heap.Len())
+	for node := range pq.heap.All() {
+		e := jsonElem[T, P]{Item: node.Value.item, Priority: nan.JSON[P]{V: node.Value.prio}, Sequence: node.Value.seq}
+		if !node.Value.expiry.IsZero() {
+			e.Expiry = &node.Value.expiry
+		}
+		elems = append(elems, e)
+	}
+	slices.SortFunc(elems, func(a, b jsonElem[T, P]) int {
+		return cmp.Compare(a.Sequence, b.Sequence)
+	})
+	return json.Marshal(elems)
+}
+
+// UnmarshalJSON replaces the contents of the priority queue with the elements of a JSON array
+// produced by MarshalJSON, keeping their sequence numbers so stable comparators preserve FIFO order.
+// The queue must have been created by New or NewWithBackend, which supply the comparator;
+// the queue is left unchanged if decoding fails. If the queue has a MaxLen, the elements that
+// would be dequeued last are dropped until it fits.
+// Elements with NaN priorities are dropped if the NaN policy is RejectNaN.
+func (pq *PriorityQueue[T, P]) UnmarshalJSON(data []byte) error {
+	if pq.heap == nil {
+		return errors.New("mpqs: UnmarshalJSON on a queue not created by New")
+	}
+	var elems []jsonElem[T, P]
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	clearHeap(pq)
+	last := 0
+	for _, e := range elems {
+		if rejected(pq, e.Priority.V) {
+			continue
+		}
+		elem := Elem[T, P]{item: e.Item, prio: e.Priority.V, seq: e.Sequence}
+		if e.Expiry != nil {
+			elem.expiry = *e.Expiry
+		}
+		push(pq, elem)
+		last = max(last, e.Sequence)
+	}
+	pq.counter = counterFrom(last)
+	SetMaxLen(pq, pq.maxLen)
+	check(pq)
+	return nil
+}
@@ -2,14 +2,24 @@ package mpqs
 
 import (
 	"cmp"
-	"container/heap"
+	"iter"
+	"time"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/comparator"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/internal/nan"
+	"github.com/byExist/priorityqueues/snapshot"
 )
 
 // Elem represents an element in the priority queue with an item, priority, and sequence number.
-type Elem[T any, P cmp.Ordered] struct {
-	item T
-	prio P
-	seq  int
+type Elem[T any, P any] struct {
+	item   T
+	prio   P
+	seq    int
+	expiry time.Time
+	ref    *ref[T, P]
 }
 
 // Item returns the item stored in the element.
@@ -27,47 +37,98 @@ func (e Elem[T, P]) Sequence() int {
 	return e.seq
 }
 
-type heapImpl[T any, P cmp.Ordered] struct {
-	elems    []Elem[T, P]
-	lessFunc func(i, j Elem[T, P]) bool
+// Expiry returns the time the element expires, or the zero time if it never expires.
+func (e Elem[T, P]) Expiry() time.Time {
+	return e.expiry
 }
 
-func (h *heapImpl[T, P]) Len() int {
-	return len(h.elems)
+func counter() func() int {
+	return counterFrom(0)
 }
 
-func (h *heapImpl[T, P]) Less(i, j int) bool {
-	return h.lessFunc(h.elems[i], h.elems[j])
+// counterFrom returns a counter whose first value is i+1.
+func counterFrom(i int) func() int {
+	return func() int {
+		i++
+		return i
+	}
 }
 
-func (h *heapImpl[T, P]) Swap(i, j int) {
-	h.elems[i], h.elems[j] = h.elems[j], h.elems[i]
+// push inserts elem into the heap, counts it if it expires and points its handle, if any, at the new node.
+func push[T any, P any](pq *PriorityQueue[T, P], elem Elem[T, P]) {
+	node := pq.heap.Push(elem)
+	if !elem.expiry.IsZero() {
+		pq.expiring++
+	}
+	if elem.ref != nil {
+		elem.ref.pq, elem.ref.node = pq, node
+	}
 }
 
-func (h *heapImpl[T, P]) Push(x any) {
-	h.elems = append(h.elems, x.(Elem[T, P]))
+// pop removes the top element of the heap and invalidates its handle.
+func pop[T any, P any](pq *PriorityQueue[T, P]) Elem[T, P] {
+	return removed(pq, pq.heap.Pop().Value)
 }
 
-func (h *heapImpl[T, P]) Pop() any {
-	old := h.elems
-	n := len(old)
-	item := old[n-1]
-	h.elems = old[0 : n-1]
-	return item
+// remove removes node from the heap and invalidates the handle of its element.
+func remove[T any, P any](pq *PriorityQueue[T, P], node *heaps.Node[Elem[T, P]]) Elem[T, P] {
+	pq.heap.Remove(node)
+	return removed(pq, node.Value)
 }
 
-func counter() func() int {
-	i := 0
-	return func() int {
-		i++
-		return i
+// removed uncounts an element that left the heap and invalidates its handle.
+func removed[T any, P any](pq *PriorityQueue[T, P], elem Elem[T, P]) Elem[T, P] {
+	if !elem.expiry.IsZero() {
+		pq.expiring--
 	}
+	release(elem)
+	return elem
 }
 
+// clearHeap empties the heap and invalidates the handles of its elements.
+func clearHeap[T any, P any](pq *PriorityQueue[T, P]) {
+	for node := range pq.heap.All() {
+		release(node.Value)
+	}
+	pq.heap.Clear()
+	pq.expiring = 0
+}
+
+// EvictionPolicy determines what a queue that has reached its MaxLen does with a new element.
+type EvictionPolicy int
+
+const (
+	// EvictWorst keeps the better of the new element and the queued element that would be
+	// dequeued last, and evicts the other. This is the default.
+	EvictWorst EvictionPolicy = iota
+	// RejectNew leaves the queue unchanged and evicts the new element.
+	RejectNew
+)
+
 // PriorityQueue represents a generic priority queue with elements of type T and priority of type P.
-type PriorityQueue[T any, P cmp.Ordered] struct {
-	heap    *heapImpl[T, P]
-	counter func() int
+type PriorityQueue[T any, P any] struct {
+	heap         heaps.Backend[Elem[T, P]]
+	counter      func() int
+	lessFunc     func(x, y Elem[T, P]) bool
+	codec        snapshot.Codec[T]
+	comparatorID string
+
+	// less is lessFunc with NaN priorities placed by the NaN policy.
+	less      func(x, y Elem[T, P]) bool
+	nanPolicy NaNPolicy
+	nanOrder  nan.Order
+	isNaN     func(P) bool
+
+	maxLen      int
+	evictPolicy EvictionPolicy
+	evictFunc   func(incoming, worst Elem[T, P]) bool
+
+	now         func() time.Time
+	expiredFunc func(Elem[T, P])
+	// expiring counts the queued elements that have an expiry.
+	expiring int
+
+	hooks []hooks.Hook[T, P]
 }
 
 // MinFirst compares two elements and returns true if x has lower priority than y.
@@ -100,59 +161,288 @@ func StableMaxFirst[T any, P cmp.Ordered](x, y Elem[T, P]) bool {
 	return x.prio > y.prio
 }
 
+// MinFirstFunc returns a comparator that orders elements by ascending priority as determined
+// by compare, such as time.Time.Compare, for priority types that are not cmp.Ordered.
+func MinFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) < 0
+	}
+}
+
+// MaxFirstFunc returns a comparator that orders elements by descending priority as determined by compare.
+func MaxFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		return compare(x.prio, y.prio) > 0
+	}
+}
+
+// StableMinFirstFunc is like MinFirstFunc, but elements with equal priorities are ordered by insertion.
+func StableMinFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c < 0
+		}
+		return x.seq < y.seq
+	}
+}
+
+// StableMaxFirstFunc is like MaxFirstFunc, but elements with equal priorities are ordered by insertion.
+func StableMaxFirstFunc[T any, P any](compare func(a, b P) int) func(x, y Elem[T, P]) bool {
+	return func(x, y Elem[T, P]) bool {
+		if c := compare(x.prio, y.prio); c != 0 {
+			return c > 0
+		}
+		return x.seq < y.seq
+	}
+}
+
 // New creates a new PriorityQueue with the provided less function.
 // The lessFunc determines the priority order: it should return true if x has higher priority than y.
 // For a min-priority queue, use: func(x, y Elem[T, P]) bool { return x.prio < y.prio }
-func New[T any, P cmp.Ordered](
+func New[T any, P any](
 	lessFunc func(x, y Elem[T, P]) bool,
 ) *PriorityQueue[T, P] {
-	return &PriorityQueue[T, P]{
-		heap: &heapImpl[T, P]{
-			elems:    []Elem[T, P]{},
-			lessFunc: lessFunc,
-		},
-		counter: counter(),
+	return NewWithBackend(lessFunc, heaps.Binary)
+}
+
+// NewWithBackend creates a new PriorityQueue like New, storing its elements in a heap created by backend,
+// such as heaps.Quaternary, heaps.Pairing or heaps.Fibonacci.
+func NewWithBackend[T any, P any](
+	lessFunc func(x, y Elem[T, P]) bool,
+	backend heaps.Factory[Elem[T, P]],
+) *PriorityQueue[T, P] {
+	pq := &PriorityQueue[T, P]{
+		counter:  counter(),
+		lessFunc: lessFunc,
+		isNaN:    nan.Func[P](),
+		now:      time.Now,
+	}
+	pq.less = nanLess(pq, lessFunc)
+	pq.heap = backend(debug.Less(pq.less))
+	return pq
+}
+
+// SetMaxLen bounds the priority queue to at most maxLen elements; zero or a negative value removes the bound.
+// Once the queue is full, Enqueue and Offer drop expired elements and then consult the eviction policy before inserting.
+// If the queue already holds more than maxLen elements, the ones that would be dequeued last are removed.
+// A bounded queue keeps its elements in a heaps.MinMax heap, so the element to evict is found in O(1)
+// and removed in O(log n). Bounding a queue with another backend moves its elements there in linear
+// time, and they stay there if the bound is removed later.
+func SetMaxLen[T any, P any](pq *PriorityQueue[T, P], maxLen int) {
+	pq.maxLen = maxLen
+	if maxLen > 0 {
+		bound(pq)
+	}
+	for pq.maxLen > 0 && pq.heap.Len() > pq.maxLen {
+		emit(pq, hooks.Delete, remove(pq, worst(pq)))
 	}
 }
 
+// SetEvictionPolicy sets how a full priority queue makes room for new elements.
+func SetEvictionPolicy[T any, P any](pq *PriorityQueue[T, P], policy EvictionPolicy) {
+	pq.evictPolicy = policy
+	pq.evictFunc = nil
+}
+
+// SetEvictFunc sets a custom eviction policy, overriding the EvictionPolicy.
+// When the queue is full, evictFunc is called with the new element and the queued element that
+// would be dequeued last; returning true evicts worst and inserts incoming, false evicts incoming.
+func SetEvictFunc[T any, P any](pq *PriorityQueue[T, P], evictFunc func(incoming, worst Elem[T, P]) bool) {
+	pq.evictFunc = evictFunc
+}
+
+// bound moves the elements of pq into a min-max heap unless its backend is already double-ended.
+// The nodes are kept, so handles stay valid.
+func bound[T any, P any](pq *PriorityQueue[T, P]) {
+	if _, ok := pq.heap.(heaps.DoubleEnded[Elem[T, P]]); ok {
+		return
+	}
+	h := heaps.MinMax(debug.Less(pq.less))
+	heaps.MeldUnordered(h, pq.heap)
+	pq.heap = h
+}
+
+// worst returns the node that would be dequeued last. Only bounded queues, whose backend
+// is double-ended, need it.
+func worst[T any, P any](pq *PriorityQueue[T, P]) *heaps.Node[Elem[T, P]] {
+	return pq.heap.(heaps.DoubleEnded[Elem[T, P]]).PeekMax()
+}
+
 // Clear removes all elements from the priority queue and resets its sequence counter.
-func Clear[T any, P cmp.Ordered](pq *PriorityQueue[T, P]) {
-	pq.heap.elems = []Elem[T, P]{}
+func Clear[T any, P any](pq *PriorityQueue[T, P]) {
+	clearHeap(pq)
 	pq.counter = counter()
+	emit(pq, hooks.Clear, Elem[T, P]{})
 }
 
-// Enqueue inserts a new item with the given priority into the priority queue.
-func Enqueue[T any, P cmp.Ordered](pq *PriorityQueue[T, P], item T, prio P) {
-	elem := Elem[T, P]{
+// Enqueue inserts a new item with the given priority into the priority queue and returns a Handle
+// for changing its priority with UpdatePriority or removing it with Remove.
+// If the queue is full, the eviction policy decides which element is dropped; use Offer to learn which one.
+// When that is the new element, the returned handle is not valid.
+// It returns ErrNaN if prio is NaN and the NaN policy is RejectNaN.
+func Enqueue[T any, P any](pq *PriorityQueue[T, P], item T, prio P) (Handle[T, P], error) {
+	if rejected(pq, prio) {
+		return Handle[T, P]{}, ErrNaN
+	}
+	r := &ref[T, P]{}
+	offer(pq, Elem[T, P]{
 		item: item,
 		prio: prio,
 		seq:  pq.counter(),
+		ref:  r,
+	})
+	return Handle[T, P]{r}, nil
+}
+
+// Offer inserts a new item with the given priority like Enqueue and returns the element evicted to
+// keep the queue within its MaxLen. The evicted element is either a queued one or the new one itself.
+// The boolean return value indicates whether an element was evicted.
+// ErrNaN is returned, and nothing is evicted, if prio is NaN and the NaN policy is RejectNaN.
+// A full Offer costs O(log n), plus a scan for expired elements if any queued element has an expiry.
+func Offer[T any, P any](pq *PriorityQueue[T, P], item T, prio P) (Elem[T, P], bool, error) {
+	if rejected(pq, prio) {
+		return Elem[T, P]{}, false, ErrNaN
 	}
-	heap.Push(pq.heap, elem)
+	evicted, ok := offer(pq, Elem[T, P]{
+		item: item,
+		prio: prio,
+		seq:  pq.counter(),
+	})
+	return evicted, ok, nil
 }
 
-// Dequeue removes and returns the item with the highest priority from the priority queue.
-// The boolean return value indicates whether an item was returned.
-func Dequeue[T any, P cmp.Ordered](pq *PriorityQueue[T, P]) (T, bool) {
+func offer[T any, P any](pq *PriorityQueue[T, P], elem Elem[T, P]) (Elem[T, P], bool) {
+	if pq.maxLen > 0 && pq.heap.Len() >= pq.maxLen && pq.expiring > 0 {
+		expire(pq, pq.now())
+	}
+	if pq.maxLen <= 0 || pq.heap.Len() < pq.maxLen {
+		push(pq, elem)
+		emit(pq, hooks.Enqueue, elem)
+		return Elem[T, P]{}, false
+	}
+	last := worst(pq)
+	if !admit(pq, elem, last.Value) {
+		return elem, true
+	}
+	evicted := remove(pq, last)
+	emit(pq, hooks.Delete, evicted)
+	push(pq, elem)
+	emit(pq, hooks.Enqueue, elem)
+	return evicted, true
+}
+
+func admit[T any, P any](pq *PriorityQueue[T, P], incoming, worst Elem[T, P]) bool {
+	if pq.evictFunc != nil {
+		return pq.evictFunc(incoming, worst)
+	}
+	if pq.evictPolicy == RejectNew {
+		return false
+	}
+	return pq.less(incoming, worst)
+}
+
+// Dequeue removes and returns the item with the highest priority from the priority queue,
+// dropping expired elements on the way. The boolean return value indicates whether an item was returned.
+func Dequeue[T any, P any](pq *PriorityQueue[T, P]) (T, bool) {
+	skipExpired(pq)
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := heap.Pop(pq.heap).(Elem[T, P])
+	elem := pop(pq)
+	emit(pq, hooks.Dequeue, elem)
 	return elem.item, true
 }
 
 // Peek returns the item with the highest priority without removing it from the queue.
-// The boolean return value indicates whether an item was returned.
-func Peek[T any, P cmp.Ordered](pq *PriorityQueue[T, P]) (T, bool) {
+// Expired elements ahead of it are dropped. The boolean return value indicates whether an item was returned.
+func Peek[T any, P any](pq *PriorityQueue[T, P]) (T, bool) {
+	skipExpired(pq)
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	return pq.heap.elems[0].item, true
+	return pq.heap.Peek().Value.item, true
 }
 
-// Len returns the number of elements currently in the priority queue.
-func Len[T any, P cmp.Ordered](pq *PriorityQueue[T, P]) int {
+// Len returns the number of elements currently in the priority queue,
+// including expired ones that have not been dropped yet.
+func Len[T any, P any](pq *PriorityQueue[T, P]) int {
 	return pq.heap.Len()
 }
+
+// Merge moves all elements of src into dst and leaves src empty. Stable comparators put dst's
+// elements first on ties, and handles follow their elements into dst. If dst has a MaxLen,
+// the elements that would be dequeued last are dropped until it fits.
+func Merge[T any, P any](dst, src *PriorityQueue[T, P]) {
+	if dst == src {
+		return
+	}
+	// Order src by dst's NaN policy while it is moved, and restore its own policy afterwards.
+	if policy := src.nanPolicy; policy != dst.nanPolicy {
+		SetNaNPolicy(src, dst.nanPolicy)
+		defer SetNaNPolicy(src, policy)
+	}
+	// Renumber src to follow dst, so stable comparators put dst's elements first on ties.
+	base, last := dst.counter(), src.counter()
+	for node := range src.heap.All() {
+		node.Value.seq += base
+		if r := node.Value.ref; r != nil {
+			r.pq = dst
+		}
+	}
+	// Elements moved into dst are reported as enqueued there once the meld is done.
+	var moved []Elem[T, P]
+	if len(dst.hooks) > 0 {
+		for node := range src.heap.All() {
+			moved = append(moved, node.Value)
+		}
+	}
+	// Pairing and Fibonacci heaps link src in constant time, which is only safe when both queues
+	// are known to order alike; other comparators, such as closures, get src's elements inserted
+	// one by one. Array based heaps are rebuilt in linear time either way.
+	if comparator.Same(dst.lessFunc, src.lessFunc) {
+		dst.heap.Meld(src.heap)
+	} else {
+		heaps.MeldUnordered(dst.heap, src.heap)
+	}
+	dst.expiring += src.expiring
+	src.expiring = 0
+	dst.counter = counterFrom(base + last)
+	src.counter = counter()
+	emit(src, hooks.Clear, Elem[T, P]{})
+	for _, elem := range moved {
+		emit(dst, hooks.Enqueue, elem)
+	}
+	SetMaxLen(dst, dst.maxLen)
+	check(dst)
+}
+
+// All returns an iterator over the items and priorities currently in the priority queue.
+// Elements are yielded in unspecified order, not priority order, and the queue is left unchanged.
+// Expired elements that have not been dropped yet are included.
+// The queue must not be modified during iteration.
+func All[T any, P any](pq *PriorityQueue[T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for node := range pq.heap.All() {
+			if !yield(node.Value.item, node.Value.prio) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues items and their priorities in priority order
+// until the queue is empty, skipping expired elements like Dequeue.
+// Stopping the iteration early leaves the remaining items in the queue.
+func Drain[T any, P any](pq *PriorityQueue[T, P]) iter.Seq2[T, P] {
+	return func(yield func(T, P) bool) {
+		for skipExpired(pq); pq.heap.Len() > 0; skipExpired(pq) {
+			elem := pop(pq)
+			emit(pq, hooks.Dequeue, elem)
+			if !yield(elem.item, elem.prio) {
+				return
+			}
+		}
+	}
+}
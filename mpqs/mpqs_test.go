@@ -1,10 +1,21 @@
 package mpqs_test
 
 import (
+	"cmp"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
 	"github.com/byExist/priorityqueues/mpqs"
+	"github.com/byExist/priorityqueues/order"
+	"github.com/byExist/priorityqueues/snapshot"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -105,6 +116,950 @@ func TestStableMaxFirstOrder(t *testing.T) {
 	assert.Equal(t, "c", third)
 }
 
+func TestTimePriorities(t *testing.T) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	pq := mpqs.New(mpqs.StableMinFirstFunc[string](time.Time.Compare))
+	mpqs.Enqueue(pq, "late", base.Add(time.Hour))
+	mpqs.Enqueue(pq, "first", base)
+	mpqs.Enqueue(pq, "second", base)
+	mpqs.Enqueue(pq, "soon", base.Add(time.Minute))
+	var got []string
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"first", "second", "soon", "late"}, got)
+
+	pq = mpqs.New(mpqs.MaxFirstFunc[string](time.Time.Compare))
+	mpqs.Enqueue(pq, "early", base)
+	mpqs.Enqueue(pq, "late", base.Add(time.Hour))
+	item, _ := mpqs.Peek(pq)
+	assert.Equal(t, "late", item)
+
+	pq = mpqs.New(mpqs.StableMaxFirstFunc[string](time.Time.Compare))
+	mpqs.Enqueue(pq, "a", base)
+	mpqs.Enqueue(pq, "b", base)
+	item, _ = mpqs.Dequeue(pq)
+	assert.Equal(t, "a", item)
+
+	pq = mpqs.New(mpqs.MinFirstFunc[string](time.Time.Compare))
+	mpqs.Enqueue(pq, "late", base.Add(time.Hour))
+	mpqs.Enqueue(pq, "early", base)
+	item, _ = mpqs.Dequeue(pq)
+	assert.Equal(t, "early", item)
+}
+
+func TestAll(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 3)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 2)
+	got := map[string]int{}
+	for item, prio := range mpqs.All(pq) {
+		got[item] = prio
+	}
+	assert.Equal(t, map[string]int{"a": 3, "b": 1, "c": 2}, got)
+	assert.Equal(t, 3, mpqs.Len(pq))
+}
+
+func TestAllPreservesStableOrder(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 1)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 1)
+	for range mpqs.All(pq) {
+	}
+	first, _ := mpqs.Dequeue(pq)
+	second, _ := mpqs.Dequeue(pq)
+	assert.Equal(t, "a", first)
+	assert.Equal(t, "b", second)
+}
+
+func TestDrain(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 2)
+	var items []string
+	var prios []int
+	for item, prio := range mpqs.Drain(pq) {
+		items = append(items, item)
+		prios = append(prios, prio)
+	}
+	assert.Equal(t, []string{"b", "a", "c"}, items)
+	assert.Equal(t, []int{1, 2, 2}, prios)
+	assert.Equal(t, 0, mpqs.Len(pq))
+}
+
+func TestDrainStopEarly(t *testing.T) {
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	for item := range mpqs.Drain(pq) {
+		assert.Equal(t, "b", item)
+		break
+	}
+	assert.Equal(t, 1, mpqs.Len(pq))
+}
+
+func TestNewWithBackendStable(t *testing.T) {
+	backends := []heaps.Factory[mpqs.Elem[string, int]]{
+		heaps.Binary[mpqs.Elem[string, int]],
+		heaps.Dary[mpqs.Elem[string, int]](3),
+		heaps.Pairing[mpqs.Elem[string, int]],
+		heaps.Fibonacci[mpqs.Elem[string, int]],
+	}
+	for _, backend := range backends {
+		pq := mpqs.NewWithBackend(mpqs.StableMinFirst[string, int], backend)
+		mpqs.Enqueue(pq, "a", 2)
+		mpqs.Enqueue(pq, "b", 1)
+		mpqs.Enqueue(pq, "c", 2)
+		mpqs.Enqueue(pq, "d", 1)
+		var items []string
+		for item := range mpqs.Drain(pq) {
+			items = append(items, item)
+		}
+		assert.Equal(t, []string{"b", "d", "a", "c"}, items)
+	}
+}
+
+func TestSetMaxLenEvictWorst(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMaxFirst[string, int])
+	mpqs.SetMaxLen(pq, 2)
+	mpqs.Enqueue(pq, "a", 5)
+	mpqs.Enqueue(pq, "b", 3)
+
+	evicted, ok, _ := mpqs.Offer(pq, "c", 4)
+	assert.True(t, ok)
+	assert.Equal(t, "b", evicted.Item())
+	assert.Equal(t, 3, evicted.Priority())
+
+	evicted, ok, _ = mpqs.Offer(pq, "d", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "d", evicted.Item())
+
+	assert.Equal(t, 2, mpqs.Len(pq))
+	var got []string
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"a", "c"}, got)
+}
+
+func TestSetMaxLenStableTie(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMaxFirst[string, int])
+	mpqs.SetMaxLen(pq, 2)
+	mpqs.Enqueue(pq, "a", 1)
+	mpqs.Enqueue(pq, "b", 1)
+
+	// On a tie the later element is worse, so the newcomer is evicted.
+	evicted, ok, _ := mpqs.Offer(pq, "c", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "c", evicted.Item())
+
+	// A better newcomer evicts the latest of the tied elements.
+	evicted, ok, _ = mpqs.Offer(pq, "d", 2)
+	assert.True(t, ok)
+	assert.Equal(t, "b", evicted.Item())
+}
+
+func TestSetMaxLenRejectNew(t *testing.T) {
+	pq := mpqs.New(mpqs.MaxFirst[string, int])
+	mpqs.SetMaxLen(pq, 1)
+	mpqs.SetEvictionPolicy(pq, mpqs.RejectNew)
+	mpqs.Enqueue(pq, "a", 1)
+
+	evicted, ok, _ := mpqs.Offer(pq, "b", 9)
+	assert.True(t, ok)
+	assert.Equal(t, "b", evicted.Item())
+	item, _ := mpqs.Peek(pq)
+	assert.Equal(t, "a", item)
+}
+
+func TestSetEvictFunc(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMaxFirst[string, int])
+	mpqs.SetMaxLen(pq, 1)
+	mpqs.SetEvictFunc(pq, func(incoming, worst mpqs.Elem[string, int]) bool {
+		return incoming.Priority()-worst.Priority() >= 10
+	})
+	mpqs.Enqueue(pq, "a", 1)
+
+	evicted, _, _ := mpqs.Offer(pq, "b", 5)
+	assert.Equal(t, "b", evicted.Item())
+	evicted, _, _ = mpqs.Offer(pq, "c", 11)
+	assert.Equal(t, "a", evicted.Item())
+	item, _ := mpqs.Peek(pq)
+	assert.Equal(t, "c", item)
+}
+
+func TestSetMaxLenShrinks(t *testing.T) {
+	pq := mpqs.New(mpqs.MinFirst[int, int])
+	for i := range 10 {
+		mpqs.Enqueue(pq, i, i)
+	}
+	mpqs.SetMaxLen(pq, 3)
+	assert.Equal(t, 3, mpqs.Len(pq))
+	var got []int
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []int{0, 1, 2}, got)
+}
+
+func TestSetMaxLenBackends(t *testing.T) {
+	for _, backend := range []heaps.Factory[mpqs.Elem[int, int]]{
+		heaps.Pairing[mpqs.Elem[int, int]],
+		heaps.Fibonacci[mpqs.Elem[int, int]],
+		func(less func(a, b mpqs.Elem[int, int]) bool) heaps.Backend[mpqs.Elem[int, int]] {
+			return foreignBackend[mpqs.Elem[int, int]]{heaps.Binary(less)}
+		},
+	} {
+		pq := mpqs.NewWithBackend(mpqs.StableMinFirst[int, int], backend)
+		handles := make([]mpqs.Handle[int, int], 10)
+		for i := range 10 {
+			handles[i], _ = mpqs.Enqueue(pq, i, (i*7)%10)
+		}
+		mpqs.Dequeue(pq)
+		mpqs.SetMaxLen(pq, 5)
+		assert.Empty(t, mpqs.Validate(pq))
+		assert.True(t, mpqs.UpdatePriority(pq, handles[5], 0))
+		for i := 10; i < 20; i++ {
+			mpqs.Offer(pq, i, i%10)
+		}
+		assert.Empty(t, mpqs.Validate(pq))
+		var got []int
+		for _, prio := range mpqs.Drain(pq) {
+			got = append(got, prio)
+		}
+		assert.Equal(t, []int{0, 0, 1, 1, 2}, got)
+	}
+}
+
+func TestOfferUnbounded(t *testing.T) {
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	_, ok, err := mpqs.Offer(pq, "a", 1)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, mpqs.Len(pq))
+}
+
+func TestMergeStable(t *testing.T) {
+	for _, backend := range []heaps.Factory[mpqs.Elem[string, int]]{
+		heaps.Binary[mpqs.Elem[string, int]],
+		heaps.Pairing[mpqs.Elem[string, int]],
+		heaps.Fibonacci[mpqs.Elem[string, int]],
+	} {
+		dst := mpqs.NewWithBackend(mpqs.StableMinFirst[string, int], backend)
+		src := mpqs.NewWithBackend(mpqs.StableMinFirst[string, int], backend)
+		mpqs.Enqueue(src, "s1", 1)
+		mpqs.Enqueue(src, "s2", 1)
+		mpqs.Enqueue(dst, "d1", 1)
+		mpqs.Enqueue(dst, "d2", 0)
+		mpqs.Enqueue(src, "s3", 1)
+
+		mpqs.Merge(dst, src)
+		assert.Equal(t, 0, mpqs.Len(src))
+		mpqs.Enqueue(dst, "d3", 1)
+
+		var got []string
+		for item := range mpqs.Drain(dst) {
+			got = append(got, item)
+		}
+		assert.Equal(t, []string{"d2", "d1", "s1", "s2", "s3", "d3"}, got)
+	}
+}
+
+func TestMergeReuseAndSelf(t *testing.T) {
+	dst := mpqs.New(mpqs.StableMinFirst[string, int])
+	src := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(src, "a", 1)
+	mpqs.Merge(dst, src)
+	mpqs.Enqueue(src, "b", 1)
+	assert.Equal(t, 1, mpqs.Len(src))
+	mpqs.Merge(dst, src)
+	mpqs.Merge(dst, dst)
+	assert.Equal(t, 2, mpqs.Len(dst))
+	item, _ := mpqs.Dequeue(dst)
+	assert.Equal(t, "a", item)
+}
+
+func TestMergeDifferentComparators(t *testing.T) {
+	for _, backend := range []heaps.Factory[mpqs.Elem[string, int]]{heaps.Pairing[mpqs.Elem[string, int]], heaps.Fibonacci[mpqs.Elem[string, int]]} {
+		dst := mpqs.NewWithBackend(mpqs.MinFirstFunc[string](cmp.Compare[int]), backend)
+		src := mpqs.NewWithBackend(mpqs.MinFirstFunc[string](func(a, b int) int { return cmp.Compare(b, a) }), backend)
+		for _, prio := range []int{5, 1, 8, 4} {
+			mpqs.Enqueue(dst, fmt.Sprint(prio), prio)
+		}
+		for _, prio := range []int{2, 9, 3, 7} {
+			mpqs.Enqueue(src, fmt.Sprint(prio), prio)
+		}
+		mpqs.Dequeue(dst)
+		mpqs.Dequeue(src)
+		mpqs.Merge(dst, src)
+		assert.Empty(t, mpqs.Validate(dst))
+		var got []int
+		for _, prio := range mpqs.Drain(dst) {
+			got = append(got, prio)
+		}
+		assert.Equal(t, []int{2, 3, 4, 5, 7, 8}, got)
+	}
+}
+
+func TestMergeBounded(t *testing.T) {
+	dst := mpqs.New(mpqs.StableMaxFirst[string, int])
+	mpqs.SetMaxLen(dst, 2)
+	src := mpqs.New(mpqs.StableMaxFirst[string, int])
+	mpqs.Enqueue(dst, "a", 1)
+	mpqs.Enqueue(src, "b", 3)
+	mpqs.Enqueue(src, "c", 2)
+	mpqs.Merge(dst, src)
+	assert.Equal(t, 2, mpqs.Len(dst))
+	var got []string
+	for item := range mpqs.Drain(dst) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"b", "c"}, got)
+}
+
+func TestJSONRoundTripPreservesFIFO(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 2)
+	mpqs.Enqueue(pq, "d", 1)
+	mpqs.Dequeue(pq)
+
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"item":"a","priority":2,"seq":1},
+		{"item":"c","priority":2,"seq":3},
+		{"item":"d","priority":1,"seq":4}
+	]`, string(data))
+
+	restored := mpqs.NewWithBackend(mpqs.StableMinFirst[string, int], heaps.Pairing)
+	assert.NoError(t, json.Unmarshal(data, restored))
+	mpqs.Enqueue(restored, "e", 2)
+	var got []string
+	for item := range mpqs.Drain(restored) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"d", "a", "c", "e"}, got)
+}
+
+func TestJSONNonFinitePriorities(t *testing.T) {
+	newQueue := func() *mpqs.PriorityQueue[string, float64] {
+		pq := mpqs.New(mpqs.StableMinFirst[string, float64])
+		mpqs.SetNaNPolicy(pq, mpqs.NaNLast)
+		return pq
+	}
+	pq := newQueue()
+	mpqs.Enqueue(pq, "nan", math.NaN())
+	mpqs.Enqueue(pq, "inf", math.Inf(1))
+	mpqs.Enqueue(pq, "one", 1)
+	mpqs.Enqueue(pq, "-inf", math.Inf(-1))
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"priority":"NaN"`)
+	assert.Contains(t, string(data), `"priority":"+Inf"`)
+	assert.Contains(t, string(data), `"priority":"-Inf"`)
+
+	restored := newQueue()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, []string{"-inf", "one", "inf", "nan"}, drainItems(restored))
+
+	assert.Error(t, json.Unmarshal([]byte(`[{"item":"x","priority":"1.5","seq":1}]`), restored))
+	assert.Equal(t, 0, mpqs.Len(restored))
+}
+
+func TestRestoreRejectsNaN(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, float64])
+	mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	mpqs.Enqueue(pq, "one", 1)
+	mpqs.Enqueue(pq, "nan", math.NaN())
+	mpqs.Enqueue(pq, "two", 2)
+	text, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	for _, restore := range []func(*mpqs.PriorityQueue[string, float64]) error{
+		func(q *mpqs.PriorityQueue[string, float64]) error { return json.Unmarshal(text, q) },
+		func(q *mpqs.PriorityQueue[string, float64]) error { return q.UnmarshalBinary(data) },
+	} {
+		restored := mpqs.New(mpqs.StableMinFirst[string, float64])
+		mpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+		mpqs.SetNaNPolicy(restored, mpqs.RejectNaN)
+		assert.NoError(t, restore(restored))
+		assert.Equal(t, []string{"one", "two"}, drainItems(restored))
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	var zero mpqs.PriorityQueue[string, int]
+	assert.Error(t, json.Unmarshal([]byte(`[]`), &zero))
+
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 1)
+	assert.Error(t, json.Unmarshal([]byte(`[{"item":1}]`), pq))
+	assert.Equal(t, 1, mpqs.Len(pq))
+}
+
+func TestBinaryRoundTripPreservesFIFO(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 2)
+	mpqs.Enqueue(pq, "d", 1)
+	mpqs.Dequeue(pq)
+
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	restored := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	mpqs.Enqueue(restored, "e", 2)
+	var got []string
+	for item := range mpqs.Drain(restored) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"d", "a", "c", "e"}, got)
+}
+
+func TestBinaryRoundTripTimePriorities(t *testing.T) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	pq := mpqs.New(mpqs.StableMinFirstFunc[string](time.Time.Compare))
+	mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	mpqs.SetComparatorID(pq, "deadline")
+	mpqs.Enqueue(pq, "b", base.Add(time.Minute))
+	mpqs.Enqueue(pq, "a", base)
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	restored := mpqs.New(mpqs.StableMinFirstFunc[string](time.Time.Compare))
+	mpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+	mpqs.SetComparatorID(restored, "deadline")
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	got := map[string]time.Time{}
+	for item, prio := range mpqs.All(restored) {
+		got[item] = prio
+	}
+	assert.True(t, base.Equal(got["a"]))
+	assert.True(t, base.Add(time.Minute).Equal(got["b"]))
+	item, _ := mpqs.Dequeue(restored)
+	assert.Equal(t, "a", item)
+
+	type rank struct{ tier, score int }
+	byRank := func(a, b rank) int {
+		if a.tier != b.tier {
+			return a.tier - b.tier
+		}
+		return a.score - b.score
+	}
+	ranked := mpqs.New(mpqs.MinFirstFunc[string](byRank))
+	mpqs.SetCodec(ranked, snapshot.OrderedCodec[string]())
+	mpqs.SetComparatorID(ranked, "rank")
+	mpqs.Enqueue(ranked, "a", rank{1, 2})
+	_, err = ranked.MarshalBinary()
+	assert.ErrorIs(t, err, snapshot.ErrUnsupported)
+}
+
+func TestBinaryClosureComparators(t *testing.T) {
+	byPrio := order.By(mpqs.Elem[string, float64].Priority)
+	asc := mpqs.New(order.Then(byPrio, order.FIFO[mpqs.Elem[string, float64]]))
+	desc := mpqs.New(order.Then(order.Reverse(byPrio), order.FIFO[mpqs.Elem[string, float64]]))
+	for _, pq := range []*mpqs.PriorityQueue[string, float64]{asc, desc} {
+		mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	}
+	mpqs.Enqueue(asc, "a", 1)
+	mpqs.Enqueue(asc, "b", 2)
+
+	// Both closures share a runtime name, so neither can be identified.
+	_, err := asc.MarshalBinary()
+	assert.ErrorIs(t, err, snapshot.ErrUnidentified)
+	mpqs.SetComparatorID(asc, "ascending")
+	data, err := asc.MarshalBinary()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, desc.UnmarshalBinary(data), snapshot.ErrUnidentified)
+	mpqs.SetComparatorID(desc, "descending")
+	assert.ErrorIs(t, desc.UnmarshalBinary(data), snapshot.ErrMismatch)
+	assert.Equal(t, 0, mpqs.Len(desc))
+
+	restored := mpqs.New(order.Then(byPrio, order.FIFO[mpqs.Elem[string, float64]]))
+	mpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+	mpqs.SetComparatorID(restored, "ascending")
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, []string{"a", "b"}, drainItems(restored))
+}
+
+func TestBinaryErrors(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 1)
+	_, err := pq.MarshalBinary()
+	assert.ErrorIs(t, err, snapshot.ErrNoCodec)
+
+	mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	data, _ := pq.MarshalBinary()
+
+	other := mpqs.New(mpqs.MinFirst[string, int])
+	assert.ErrorIs(t, other.UnmarshalBinary(data), snapshot.ErrNoCodec)
+	mpqs.SetCodec(other, snapshot.OrderedCodec[string]())
+	mpqs.Enqueue(other, "x", 1)
+	assert.ErrorIs(t, other.UnmarshalBinary(data), snapshot.ErrMismatch)
+	assert.Equal(t, 1, mpqs.Len(other))
+
+	var zero mpqs.PriorityQueue[string, int]
+	assert.Error(t, zero.UnmarshalBinary(data))
+}
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newClock returns a clock for SetClock and a function that advances it.
+func newClock() (func() time.Time, func(time.Duration)) {
+	now := epoch
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestExpirySkippedByPeekAndDequeue(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	now, advance := newClock()
+	mpqs.SetClock(pq, now)
+	var dropped []string
+	mpqs.SetExpiredFunc(pq, func(e mpqs.Elem[string, int]) { dropped = append(dropped, e.Item()) })
+
+	mpqs.EnqueueWithExpiry(pq, "a", 1, epoch.Add(time.Second))
+	mpqs.EnqueueWithTTL(pq, "b", 2, 2*time.Second)
+	mpqs.Enqueue(pq, "c", 3)
+
+	item, ok := mpqs.Peek(pq)
+	assert.True(t, ok)
+	assert.Equal(t, "a", item)
+	assert.Empty(t, dropped)
+
+	advance(time.Second)
+	item, _ = mpqs.Peek(pq)
+	assert.Equal(t, "b", item)
+	assert.Equal(t, []string{"a"}, dropped)
+	assert.Equal(t, 2, mpqs.Len(pq))
+
+	advance(time.Hour)
+	item, _ = mpqs.Dequeue(pq)
+	assert.Equal(t, "c", item)
+	assert.Equal(t, []string{"a", "b"}, dropped)
+	_, ok = mpqs.Dequeue(pq)
+	assert.False(t, ok)
+}
+
+func TestExpire(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMaxFirst[string, int])
+	now, _ := newClock()
+	mpqs.SetClock(pq, now)
+	var dropped []string
+	mpqs.SetExpiredFunc(pq, func(e mpqs.Elem[string, int]) { dropped = append(dropped, e.Item()) })
+	mpqs.EnqueueWithExpiry(pq, "a", 1, epoch.Add(time.Second))
+	mpqs.EnqueueWithExpiry(pq, "b", 5, epoch.Add(2*time.Second))
+	mpqs.EnqueueWithExpiry(pq, "c", 3, epoch)
+	mpqs.EnqueueWithExpiry(pq, "d", 9, epoch.Add(time.Hour))
+	mpqs.Enqueue(pq, "e", 0)
+
+	assert.Empty(t, mpqs.Expire(pq, epoch.Add(-time.Second)))
+	expired := mpqs.Expire(pq, epoch.Add(2*time.Second))
+	var got []string
+	for _, e := range expired {
+		got = append(got, e.Item())
+	}
+	assert.Equal(t, []string{"b", "c", "a"}, got)
+	assert.Equal(t, epoch.Add(2*time.Second), expired[0].Expiry())
+	assert.ElementsMatch(t, got, dropped)
+	assert.Equal(t, 2, mpqs.Len(pq))
+
+	got = nil
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"d", "e"}, got)
+}
+
+func TestDrainSkipsExpired(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	now, advance := newClock()
+	mpqs.SetClock(pq, now)
+	mpqs.EnqueueWithTTL(pq, "a", 1, time.Second)
+	mpqs.Enqueue(pq, "b", 2)
+	mpqs.EnqueueWithTTL(pq, "c", 3, time.Second)
+	mpqs.Enqueue(pq, "d", 4)
+	advance(time.Second)
+
+	var got []string
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"b", "d"}, got)
+}
+
+func TestBoundedDropsExpiredFirst(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	now, advance := newClock()
+	mpqs.SetClock(pq, now)
+	mpqs.SetMaxLen(pq, 2)
+	mpqs.EnqueueWithTTL(pq, "a", 1, time.Second)
+	mpqs.Enqueue(pq, "b", 5)
+	advance(time.Second)
+
+	_, evicted, _ := mpqs.Offer(pq, "c", 9)
+	assert.False(t, evicted)
+	var got []string
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"b", "c"}, got)
+}
+
+func TestExpiryRoundTrip(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	mpqs.EnqueueWithExpiry(pq, "a", 1, epoch.Add(time.Second))
+	mpqs.Enqueue(pq, "b", 2)
+
+	jsonData, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	binData, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	for name, restore := range map[string]func(*mpqs.PriorityQueue[string, int]) error{
+		"JSON":   func(q *mpqs.PriorityQueue[string, int]) error { return json.Unmarshal(jsonData, q) },
+		"Binary": func(q *mpqs.PriorityQueue[string, int]) error { return q.UnmarshalBinary(binData) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			restored := mpqs.New(mpqs.StableMinFirst[string, int])
+			mpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+			now, advance := newClock()
+			mpqs.SetClock(restored, now)
+			assert.NoError(t, restore(restored))
+			item, _ := mpqs.Peek(restored)
+			assert.Equal(t, "a", item)
+			advance(time.Second)
+			item, _ = mpqs.Peek(restored)
+			assert.Equal(t, "b", item)
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	now, advance := newClock()
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetClock(pq, now)
+	var events []hooks.Event[string, int]
+	mpqs.AddHook(pq, func(e hooks.Event[string, int]) { events = append(events, e) })
+
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.EnqueueWithTTL(pq, "b", 1, time.Second)
+	advance(time.Second)
+	item, _ := mpqs.Dequeue(pq)
+	assert.Equal(t, "a", item)
+	mpqs.Clear(pq)
+
+	assert.Equal(t, []hooks.Event[string, int]{
+		{Op: hooks.Enqueue, Item: "a", Priority: 2, Sequence: 1, Len: 1},
+		{Op: hooks.Enqueue, Item: "b", Priority: 1, Sequence: 2, Len: 2},
+		{Op: hooks.Delete, Item: "b", Priority: 1, Sequence: 2, Len: 1},
+		{Op: hooks.Dequeue, Item: "a", Priority: 2, Sequence: 1, Len: 0},
+		{Op: hooks.Clear},
+	}, events)
+}
+
+func TestHooksEvictionAndMerge(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetMaxLen(pq, 2)
+	var ops []hooks.Op
+	mpqs.AddHook(pq, func(e hooks.Event[string, int]) { ops = append(ops, e.Op) })
+
+	mpqs.Enqueue(pq, "a", 1)
+	mpqs.Enqueue(pq, "b", 2)
+	mpqs.Enqueue(pq, "c", 3) // rejected, not reported
+	mpqs.Enqueue(pq, "d", 0) // evicts b
+	assert.Equal(t, []hooks.Op{hooks.Enqueue, hooks.Enqueue, hooks.Delete, hooks.Enqueue}, ops)
+
+	ops = nil
+	dst := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Merge(dst, pq)
+	assert.Equal(t, []hooks.Op{hooks.Clear}, ops)
+
+	ops = nil
+	mpqs.Enqueue(pq, "e", 1)
+	for range mpqs.Drain(pq) {
+	}
+	assert.Equal(t, []hooks.Op{hooks.Enqueue, hooks.Dequeue}, ops)
+}
+
+func TestValidate(t *testing.T) {
+	rank := map[string]int{"a": 1, "b": 2, "c": 3}
+	pq := mpqs.New(func(x, y mpqs.Elem[string, int]) bool {
+		return rank[x.Item()] < rank[y.Item()]
+	})
+	for item := range rank {
+		mpqs.Enqueue(pq, item, 0)
+	}
+	assert.Empty(t, mpqs.Validate(pq))
+
+	// The comparator reads state that changes behind the queue's back.
+	corrupt := func() {
+		rank["a"] = 4
+		mpqs.Enqueue(pq, "d", 0)
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	errs := mpqs.Validate(pq)
+	if assert.NotEmpty(t, errs) {
+		var oe *heaps.OrderError[mpqs.Elem[string, int]]
+		assert.ErrorAs(t, errs[0], &oe)
+		assert.Equal(t, "a", oe.Ancestor.Value.Item())
+		assert.ErrorContains(t, errs[0], "is below a with priority 0")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	now, _ := newClock()
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetClock(pq, now)
+	mpqs.Enqueue(pq, "b", 2)
+	mpqs.Enqueue(pq, "a", 1)
+	mpqs.EnqueueWithTTL(pq, "c", 3, time.Minute)
+
+	assert.Equal(t, "a (priority 1, seq 2)\n├── b (priority 2, seq 1)\n└── c (priority 3, seq 3)", pq.String())
+	assert.Equal(t, `"a" (priority 1, seq 2)`, strings.SplitN(fmt.Sprintf("%q", pq), "\n", 2)[0])
+	assert.Contains(t, fmt.Sprintf("%+v", pq), "c (priority 3, seq 3, expires 2024-01-01T00:01:00Z)")
+	assert.NotContains(t, fmt.Sprintf("%v", pq), "expires")
+
+	var sb strings.Builder
+	assert.NoError(t, pq.WriteDOT(&sb))
+	assert.Contains(t, sb.String(), `n0 [label="a\npriority 1\nseq 2", style=bold];`)
+	assert.Contains(t, sb.String(), `[label="c\npriority 3\nseq 3\nexpires 2024-01-01T00:01:00Z"];`)
+}
+
+func drainItems(pq *mpqs.PriorityQueue[string, float64]) []string {
+	var got []string
+	for item := range mpqs.Drain(pq) {
+		got = append(got, item)
+	}
+	return got
+}
+
+func TestNaNPolicy(t *testing.T) {
+	nan := math.NaN()
+	for _, backend := range []heaps.Factory[mpqs.Elem[string, float64]]{
+		heaps.Binary[mpqs.Elem[string, float64]],
+		heaps.Pairing[mpqs.Elem[string, float64]],
+		heaps.Fibonacci[mpqs.Elem[string, float64]],
+	} {
+		pq := mpqs.NewWithBackend(mpqs.StableMinFirst[string, float64], backend)
+		mpqs.SetNaNPolicy(pq, mpqs.NaNLast)
+		for i, prio := range []float64{3, nan, 1, nan, 2, 1} {
+			_, err := mpqs.Enqueue(pq, fmt.Sprint(i), prio)
+			assert.NoError(t, err)
+		}
+		assert.Empty(t, mpqs.Validate(pq))
+		assert.Equal(t, []string{"2", "5", "4", "0", "1", "3"}, drainItems(pq))
+
+		mpqs.SetNaNPolicy(pq, mpqs.NaNFirst)
+		for i, prio := range []float64{3, nan, 1, nan} {
+			mpqs.Enqueue(pq, fmt.Sprint(i), prio)
+		}
+		assert.Equal(t, []string{"1", "3", "2", "0"}, drainItems(pq))
+	}
+
+	pq := mpqs.New(mpqs.MaxFirst[string, float64])
+	mpqs.SetNaNPolicy(pq, mpqs.RejectNaN)
+	h, err := mpqs.Enqueue(pq, "nan", nan)
+	assert.ErrorIs(t, err, mpqs.ErrNaN)
+	assert.False(t, mpqs.Valid(pq, h))
+	assert.ErrorIs(t, mpqs.EnqueueWithTTL(pq, "nan", nan, time.Minute), mpqs.ErrNaN)
+	_, ok, err := mpqs.Offer(pq, "nan", nan)
+	assert.ErrorIs(t, err, mpqs.ErrNaN)
+	assert.False(t, ok)
+	_, err = mpqs.Enqueue(pq, "one", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mpqs.Len(pq))
+}
+
+func TestSetNaNPolicyReordersQueued(t *testing.T) {
+	type score float32
+	pq := mpqs.New(mpqs.StableMinFirst[string, score])
+	var deleted []string
+	mpqs.AddHook(pq, func(e hooks.Event[string, score]) {
+		if e.Op == hooks.Delete {
+			deleted = append(deleted, e.Item)
+		}
+	})
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.SetNaNPolicy(pq, mpqs.NaNFirst)
+	mpqs.Enqueue(pq, "nan", score(math.NaN()))
+	item, _ := mpqs.Peek(pq)
+	assert.Equal(t, "nan", item)
+
+	mpqs.SetNaNPolicy(pq, mpqs.NaNLast)
+	item, _ = mpqs.Peek(pq)
+	assert.Equal(t, "b", item)
+	assert.Empty(t, mpqs.Validate(pq))
+
+	mpqs.SetNaNPolicy(pq, mpqs.RejectNaN)
+	assert.Equal(t, []string{"nan"}, deleted)
+	assert.Equal(t, 2, mpqs.Len(pq))
+}
+
+func TestMergeNaNPolicies(t *testing.T) {
+	dst := mpqs.New(mpqs.StableMinFirst[string, float64])
+	mpqs.SetNaNPolicy(dst, mpqs.NaNLast)
+	src := mpqs.New(mpqs.StableMinFirst[string, float64])
+	mpqs.SetNaNPolicy(src, mpqs.NaNFirst)
+	mpqs.Enqueue(dst, "two", 2)
+	mpqs.Enqueue(src, "nan", math.NaN())
+	mpqs.Enqueue(src, "one", 1)
+	mpqs.Merge(dst, src)
+	assert.Empty(t, mpqs.Validate(dst))
+	assert.Equal(t, []string{"one", "two", "nan"}, drainItems(dst))
+
+	// src keeps its own policy.
+	mpqs.Enqueue(src, "one", 1)
+	mpqs.Enqueue(src, "nan", math.NaN())
+	item, _ := mpqs.Peek(src)
+	assert.Equal(t, "nan", item)
+}
+
+func TestNaNPolicyIgnoredForOtherTypes(t *testing.T) {
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.SetNaNPolicy(pq, mpqs.RejectNaN)
+	_, err := mpqs.Enqueue(pq, "zero", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mpqs.Len(pq))
+}
+
+// foreignBackend hides the backend type from the heaps package.
+type foreignBackend[E any] struct {
+	heaps.Backend[E]
+}
+
+func TestHandles(t *testing.T) {
+	for _, backend := range []heaps.Factory[mpqs.Elem[string, float64]]{
+		heaps.Binary[mpqs.Elem[string, float64]],
+		heaps.Pairing[mpqs.Elem[string, float64]],
+		heaps.Fibonacci[mpqs.Elem[string, float64]],
+		func(less func(a, b mpqs.Elem[string, float64]) bool) heaps.Backend[mpqs.Elem[string, float64]] {
+			return foreignBackend[mpqs.Elem[string, float64]]{heaps.Binary(less)}
+		},
+	} {
+		pq := mpqs.NewWithBackend(mpqs.StableMinFirst[string, float64], backend)
+		handles := make(map[string]mpqs.Handle[string, float64])
+		for i, prio := range []float64{5, 3, 8, 1, 6} {
+			item := fmt.Sprint(i)
+			h, err := mpqs.Enqueue(pq, item, prio)
+			assert.NoError(t, err)
+			assert.True(t, mpqs.Valid(pq, h))
+			handles[item] = h
+		}
+
+		assert.True(t, mpqs.UpdatePriority(pq, handles["2"], 0))
+		assert.True(t, mpqs.UpdatePriority(pq, handles["3"], 9))
+		item, ok := mpqs.Remove(pq, handles["1"])
+		assert.True(t, ok)
+		assert.Equal(t, "1", item)
+		assert.False(t, mpqs.Valid(pq, handles["1"]))
+		_, ok = mpqs.Remove(pq, handles["1"])
+		assert.False(t, ok)
+		assert.False(t, mpqs.UpdatePriority(pq, handles["1"], 0))
+
+		// Reordering under a NaN policy keeps handles pointing at their elements.
+		mpqs.SetNaNPolicy(pq, mpqs.NaNLast)
+		assert.True(t, mpqs.UpdatePriority(pq, handles["0"], math.NaN()))
+		assert.True(t, mpqs.UpdatePriority(pq, handles["4"], 2))
+		assert.Empty(t, mpqs.Validate(pq))
+
+		item, _ = mpqs.Dequeue(pq)
+		assert.Equal(t, "2", item)
+		assert.False(t, mpqs.Valid(pq, handles["2"]))
+		assert.True(t, mpqs.Valid(pq, handles["4"]))
+		assert.Equal(t, []string{"4", "3", "0"}, drainItems(pq))
+		for _, h := range handles {
+			assert.False(t, mpqs.Valid(pq, h))
+		}
+	}
+	assert.False(t, mpqs.Valid(mpqs.New(mpqs.MinFirst[string, int]), mpqs.Handle[string, int]{}))
+}
+
+func TestHandlesInvalidated(t *testing.T) {
+	epoch := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := epoch
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetClock(pq, func() time.Time { return now })
+	mpqs.SetMaxLen(pq, 2)
+	worst, _ := mpqs.Enqueue(pq, "worst", 9)
+	best, _ := mpqs.Enqueue(pq, "best", 1)
+	rejected, _ := mpqs.Enqueue(pq, "rejected", 10)
+	assert.False(t, mpqs.Valid(pq, rejected))
+	evicting, _ := mpqs.Enqueue(pq, "evicting", 2)
+	assert.False(t, mpqs.Valid(pq, worst))
+	assert.True(t, mpqs.Valid(pq, best))
+	assert.True(t, mpqs.Valid(pq, evicting))
+
+	mpqs.Clear(pq)
+	assert.False(t, mpqs.Valid(pq, best))
+	assert.False(t, mpqs.Valid(pq, evicting))
+
+	mpqs.SetMaxLen(pq, 0)
+	kept, _ := mpqs.Enqueue(pq, "kept", 1)
+	mpqs.EnqueueWithExpiry(pq, "expiring", 0, epoch.Add(time.Second))
+	now = now.Add(time.Minute)
+	mpqs.Expire(pq, now)
+	assert.True(t, mpqs.Valid(pq, kept))
+
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, pq))
+	assert.False(t, mpqs.Valid(pq, kept))
+	assert.Equal(t, 1, mpqs.Len(pq))
+}
+
+func TestMergeKeepsHandles(t *testing.T) {
+	dst := mpqs.New(mpqs.StableMinFirst[string, int])
+	src := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(dst, "d", 2)
+	h, _ := mpqs.Enqueue(src, "s", 3)
+	mpqs.Merge(dst, src)
+	assert.False(t, mpqs.Valid(src, h))
+	assert.True(t, mpqs.Valid(dst, h))
+	assert.False(t, mpqs.UpdatePriority(src, h, 1))
+	assert.True(t, mpqs.UpdatePriority(dst, h, 1))
+	item, _ := mpqs.Peek(dst)
+	assert.Equal(t, "s", item)
+}
+
+func TestUpdatePriorityHook(t *testing.T) {
+	pq := mpqs.New(mpqs.StableMinFirst[string, float64])
+	var events []hooks.Event[string, float64]
+	mpqs.AddHook(pq, func(e hooks.Event[string, float64]) {
+		if e.Op == hooks.Update {
+			events = append(events, e)
+		}
+	})
+	h, _ := mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	assert.True(t, mpqs.UpdatePriority(pq, h, 0.5))
+	assert.Equal(t, []hooks.Event[string, float64]{
+		{Op: hooks.Update, Item: "a", Priority: 0.5, Sequence: 3, PrevPriority: 2, PrevSequence: 1, Len: 2},
+	}, events)
+
+	mpqs.SetNaNPolicy(pq, mpqs.RejectNaN)
+	assert.False(t, mpqs.UpdatePriority(pq, h, math.NaN()))
+	assert.Len(t, events, 1)
+	item, _ := mpqs.Peek(pq)
+	assert.Equal(t, "a", item)
+}
+
 func Example_reversedStableMinHeap() {
 	reversedStable := func(x, y mpqs.Elem[string, int]) bool {
 		if x.Priority() == y.Priority() {
@@ -184,3 +1139,165 @@ func ExampleLen() {
 	// 0
 	// 1
 }
+
+func ExampleAll() {
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	total := 0
+	for _, prio := range mpqs.All(pq) {
+		total += prio
+	}
+	fmt.Println(total, mpqs.Len(pq))
+	// Output: 3 2
+}
+
+func ExampleDrain() {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 2)
+	mpqs.Enqueue(pq, "b", 1)
+	mpqs.Enqueue(pq, "c", 2)
+	for item, prio := range mpqs.Drain(pq) {
+		fmt.Println(item, prio)
+	}
+	// Output:
+	// b 1
+	// a 2
+	// c 2
+}
+
+func ExampleOffer() {
+	// Keep a top-3 leaderboard.
+	pq := mpqs.New(mpqs.StableMaxFirst[string, int])
+	mpqs.SetMaxLen(pq, 3)
+	for _, score := range []struct {
+		name   string
+		points int
+	}{{"ann", 30}, {"bob", 10}, {"cat", 20}, {"dan", 25}} {
+		if evicted, ok, _ := mpqs.Offer(pq, score.name, score.points); ok {
+			fmt.Println("evicted", evicted.Item())
+		}
+	}
+	for name, points := range mpqs.Drain(pq) {
+		fmt.Println(name, points)
+	}
+	// Output:
+	// evicted bob
+	// ann 30
+	// dan 25
+	// cat 20
+}
+
+func ExampleMerge() {
+	shard1 := mpqs.New(mpqs.StableMinFirst[string, int])
+	shard2 := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(shard1, "a", 1)
+	mpqs.Enqueue(shard2, "b", 1)
+	mpqs.Enqueue(shard1, "c", 2)
+	mpqs.Merge(shard1, shard2)
+	for item, prio := range mpqs.Drain(shard1) {
+		fmt.Println(item, prio)
+	}
+	// Output:
+	// a 1
+	// b 1
+	// c 2
+}
+
+func ExamplePriorityQueue_UnmarshalJSON() {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.Enqueue(pq, "first", 1)
+	mpqs.Enqueue(pq, "second", 1)
+	data, _ := json.Marshal(pq)
+
+	// Supply the comparator by creating the queue before restoring into it.
+	restored := mpqs.New(mpqs.StableMinFirst[string, int])
+	_ = json.Unmarshal(data, restored)
+	for item := range mpqs.Drain(restored) {
+		fmt.Println(item)
+	}
+	// Output:
+	// first
+	// second
+}
+
+func ExamplePriorityQueue_MarshalBinary() {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetCodec(pq, snapshot.OrderedCodec[string]())
+	mpqs.Enqueue(pq, "first", 1)
+	mpqs.Enqueue(pq, "second", 1)
+	data, _ := pq.MarshalBinary()
+
+	restored := mpqs.New(mpqs.StableMinFirst[string, int])
+	mpqs.SetCodec(restored, snapshot.OrderedCodec[string]())
+	_ = restored.UnmarshalBinary(data)
+	for item := range mpqs.Drain(restored) {
+		fmt.Println(item)
+	}
+
+	// A queue with a different comparator refuses the snapshot.
+	other := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.SetCodec(other, snapshot.OrderedCodec[string]())
+	fmt.Println(errors.Is(other.UnmarshalBinary(data), snapshot.ErrMismatch))
+	// Output:
+	// first
+	// second
+	// true
+}
+
+func ExampleExpire() {
+	pq := mpqs.New(mpqs.StableMinFirst[string, int])
+	deadline := time.Now()
+	mpqs.EnqueueWithExpiry(pq, "stale report", 1, deadline)
+	mpqs.EnqueueWithExpiry(pq, "fresh report", 2, deadline.Add(time.Hour))
+	mpqs.Enqueue(pq, "cleanup", 3)
+
+	for _, e := range mpqs.Expire(pq, deadline) {
+		fmt.Println("dead-letter:", e.Item())
+	}
+	for item := range mpqs.Drain(pq) {
+		fmt.Println(item)
+	}
+	// Output:
+	// dead-letter: stale report
+	// fresh report
+	// cleanup
+}
+
+func ExampleSetNaNPolicy() {
+	pq := mpqs.New(mpqs.StableMinFirst[string, float64])
+	mpqs.SetNaNPolicy(pq, mpqs.NaNLast)
+	mpqs.Enqueue(pq, "unscored", math.NaN())
+	mpqs.Enqueue(pq, "b", 0.7)
+	mpqs.Enqueue(pq, "a", 0.2)
+	for item, prio := range mpqs.Drain(pq) {
+		fmt.Println(item, prio)
+	}
+
+	mpqs.SetNaNPolicy(pq, mpqs.RejectNaN)
+	_, err := mpqs.Enqueue(pq, "unscored", math.NaN())
+	fmt.Println(err)
+	// Output:
+	// a 0.2
+	// b 0.7
+	// unscored NaN
+	// mpqs: NaN priority
+}
+
+func ExampleUpdatePriority() {
+	pq := mpqs.New(mpqs.MinFirst[string, int])
+	mpqs.Enqueue(pq, "a", 1)
+	b, _ := mpqs.Enqueue(pq, "b", 5)
+	c, _ := mpqs.Enqueue(pq, "c", 3)
+
+	mpqs.UpdatePriority(pq, b, 0)
+	mpqs.Remove(pq, c)
+	for item, prio := range mpqs.Drain(pq) {
+		fmt.Println(item, prio)
+	}
+	fmt.Println(mpqs.Valid(pq, b))
+	// Output:
+	// b 0
+	// a 1
+	// false
+}
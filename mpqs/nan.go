@@ -0,0 +1,74 @@
+package mpqs
+
+import (
+	"errors"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+// ErrNaN is returned by Enqueue when the priority is NaN and the NaN policy is RejectNaN.
+var ErrNaN = errors.New("mpqs: NaN priority")
+
+// NaNPolicy determines how a queue with floating-point priorities handles NaN, which compares
+// false to every priority and so breaks the heap order under MinFirst and the other comparators.
+type NaNPolicy int
+
+const (
+	// AllowNaN leaves NaN priorities to the comparator. This is the default.
+	AllowNaN NaNPolicy = iota
+	// RejectNaN leaves the queue unchanged and makes Enqueue return ErrNaN.
+	RejectNaN
+	// NaNFirst dequeues elements with NaN priorities before all others, in insertion order.
+	NaNFirst
+	// NaNLast dequeues elements with NaN priorities after all others, in insertion order.
+	NaNLast
+)
+
+// SetNaNPolicy sets how the priority queue handles NaN priorities. It has no effect unless P is a
+// floating-point type. Queued elements are reordered under the new policy in linear time, and under
+// RejectNaN the ones with NaN priorities are removed. Handles to the remaining elements stay valid.
+func SetNaNPolicy[T any, P any](pq *PriorityQueue[T, P], policy NaNPolicy) {
+	pq.nanPolicy = policy
+	if pq.isNaN == nil {
+		return
+	}
+	if policy == RejectNaN {
+		var nodes []*heaps.Node[Elem[T, P]]
+		for node := range pq.heap.All() {
+			if pq.isNaN(node.Value.prio) {
+				nodes = append(nodes, node)
+			}
+		}
+		for _, node := range nodes {
+			emit(pq, hooks.Delete, remove(pq, node))
+		}
+	}
+	switch policy {
+	case NaNFirst:
+		pq.nanOrder = nan.First
+	case NaNLast:
+		pq.nanOrder = nan.Last
+	default:
+		pq.nanOrder = nan.Unordered
+	}
+	heaps.Rebuild(pq.heap)
+	relink(pq)
+	check(pq)
+}
+
+// nanLess returns lessFunc with NaN priorities placed by the NaN policy of pq.
+func nanLess[T any, P any](pq *PriorityQueue[T, P], lessFunc func(x, y Elem[T, P]) bool) func(x, y Elem[T, P]) bool {
+	if pq.isNaN == nil {
+		return lessFunc
+	}
+	isNaN := func(e Elem[T, P]) bool { return pq.isNaN(e.prio) }
+	bySeq := func(x, y Elem[T, P]) bool { return x.seq < y.seq }
+	return nan.Less(lessFunc, isNaN, bySeq, &pq.nanOrder)
+}
+
+// rejected reports whether prio is NaN and pq rejects NaN priorities.
+func rejected[T any, P any](pq *PriorityQueue[T, P], prio P) bool {
+	return pq.nanPolicy == RejectNaN && pq.isNaN != nil && pq.isNaN(prio)
+}
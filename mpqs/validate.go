@@ -0,0 +1,40 @@
+package mpqs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/internal/debug"
+)
+
+// Validate checks the heap order of the priority queue under its comparator and the structure of
+// its heap, and returns every violation found, or nil if there is none. Order violations wrap a
+// *heaps.OrderError, and a comparator that is not a strict weak ordering, such as one comparing NaN
+// priorities, is reported with heaps.ErrComparator; see heaps.Validate. Handles that no longer
+// refer to their elements are reported as well. Validate takes linear time.
+//
+// Building with the pqdebug tag makes every mutating operation validate the queue and panic on a violation.
+func Validate[T any, P any](pq *PriorityQueue[T, P]) []error {
+	errs := heaps.Validate(pq.heap)
+	for i, err := range errs {
+		var oe *heaps.OrderError[Elem[T, P]]
+		if errors.As(err, &oe) {
+			n, a := oe.Node.Value, oe.Ancestor.Value
+			errs[i] = fmt.Errorf("%w: %v with priority %v is below %v with priority %v", err, n.item, n.prio, a.item, a.prio)
+		}
+	}
+	for node := range pq.heap.All() {
+		if r := node.Value.ref; r != nil && (r.pq != pq || r.node != node) {
+			errs = append(errs, fmt.Errorf("mpqs: handle of %v does not refer to its element", node.Value.item))
+		}
+	}
+	return errs
+}
+
+// check validates the priority queue when built with the pqdebug tag.
+func check[T any, P any](pq *PriorityQueue[T, P]) {
+	if debug.Enabled {
+		debug.Check(Validate(pq))
+	}
+}
@@ -0,0 +1,87 @@
+// Package order builds the less functions the priority queue packages take from smaller parts.
+//
+// Every function here works on a less function of any element type, so the results can be
+// passed to pqs.New as well as to the New functions of mpqs, kpqs and kmpqs, whose Elem
+// types expose their fields through Item, Priority and Sequence methods:
+//
+//	type Elem = kmpqs.Elem[*Job, int]
+//	q := kmpqs.New(
+//		order.Then(order.By(Elem.Priority), order.LIFO[Elem]),
+//		func(j *Job) string { return j.ID },
+//	)
+//
+// Like any comparator, the results must be strict weak orderings for the queues to work;
+// each builder preserves that property when its inputs have it.
+package order
+
+import (
+	"cmp"
+	"math"
+)
+
+// Sequenced is implemented by the Elem types of the queue packages.
+type Sequenced interface {
+	Sequence() int
+}
+
+// By returns a less function that orders elements by ascending key. Keys are compared
+// with cmp.Less, so a NaN key sorts before every other key.
+func By[E any, K cmp.Ordered](key func(E) K) func(x, y E) bool {
+	return func(x, y E) bool {
+		return cmp.Less(key(x), key(y))
+	}
+}
+
+// ByFunc returns a less function that orders elements by ascending key as determined by compare,
+// such as time.Time.Compare.
+func ByFunc[E any, K any](key func(E) K, compare func(a, b K) int) func(x, y E) bool {
+	return func(x, y E) bool {
+		return compare(key(x), key(y)) < 0
+	}
+}
+
+// NaNLast returns a less function that orders elements by ascending floating-point key
+// with NaN keys after every other key, where By puts them first.
+func NaNLast[E any, F ~float32 | ~float64](key func(E) F) func(x, y E) bool {
+	return func(x, y E) bool {
+		a, b := float64(key(x)), float64(key(y))
+		if math.IsNaN(a) {
+			return false
+		}
+		return a < b || math.IsNaN(b)
+	}
+}
+
+// Reverse returns a less function that orders elements the opposite way from less.
+func Reverse[E any](less func(x, y E) bool) func(x, y E) bool {
+	return func(x, y E) bool {
+		return less(y, x)
+	}
+}
+
+// Then returns a less function that orders elements lexicographically: by the first of
+// less, then, among elements it considers equal, by the second, and so on.
+func Then[E any](less ...func(x, y E) bool) func(x, y E) bool {
+	return func(x, y E) bool {
+		for _, l := range less {
+			if l(x, y) {
+				return true
+			}
+			if l(y, x) {
+				return false
+			}
+		}
+		return false
+	}
+}
+
+// FIFO orders elements by ascending sequence number, so the element inserted first comes first.
+// It is the tie-breaker of the stable comparators, for use as the last argument of Then.
+func FIFO[E Sequenced](x, y E) bool {
+	return x.Sequence() < y.Sequence()
+}
+
+// LIFO orders elements by descending sequence number, so the element inserted last comes first.
+func LIFO[E Sequenced](x, y E) bool {
+	return x.Sequence() > y.Sequence()
+}
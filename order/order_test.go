@@ -0,0 +1,207 @@
+package order_test
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/kmpqs"
+	"github.com/byExist/priorityqueues/kpqs"
+	"github.com/byExist/priorityqueues/mpqs"
+	"github.com/byExist/priorityqueues/order"
+	"github.com/byExist/priorityqueues/pqs"
+	"github.com/stretchr/testify/assert"
+)
+
+type Task struct {
+	ID       string
+	Priority int
+	Score    float64
+}
+
+func sorted[E any](items []E, less func(x, y E) bool) []E {
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(x, y E) int {
+		switch {
+		case less(x, y):
+			return -1
+		case less(y, x):
+			return 1
+		}
+		return 0
+	})
+	return items
+}
+
+func TestBy(t *testing.T) {
+	byLen := order.By(func(s string) int { return len(s) })
+	assert.True(t, byLen("a", "bb"))
+	assert.False(t, byLen("bb", "a"))
+	assert.False(t, byLen("aa", "bb"))
+
+	nan := math.NaN()
+	assert.Equal(t, []float64{1, 2, 3}, sorted([]float64{3, 1, 2}, order.By(func(f float64) float64 { return f })))
+	got := sorted([]float64{3, nan, 1}, order.By(func(f float64) float64 { return f }))
+	assert.True(t, math.IsNaN(got[0]))
+	assert.Equal(t, []float64{1, 3}, got[1:])
+}
+
+func TestByFunc(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	less := order.ByFunc(func(d time.Duration) time.Time { return base.Add(d) }, time.Time.Compare)
+	assert.Equal(t, []time.Duration{time.Second, time.Minute, time.Hour}, sorted([]time.Duration{time.Hour, time.Second, time.Minute}, less))
+}
+
+func TestNaNLast(t *testing.T) {
+	nan := math.NaN()
+	score := func(f float64) float64 { return f }
+	got := sorted([]float64{nan, 3, nan, 1, math.Inf(-1)}, order.NaNLast(score))
+	assert.Equal(t, []float64{math.Inf(-1), 1, 3}, got[:3])
+	assert.True(t, math.IsNaN(got[3]) && math.IsNaN(got[4]))
+
+	got = sorted([]float64{nan, 3, 1}, order.Reverse(order.By(score)))
+	assert.Equal(t, []float64{3, 1}, got[:2])
+	assert.True(t, math.IsNaN(got[2]))
+
+	less := order.NaNLast(func(f float32) float32 { return f })
+	assert.False(t, less(float32(nan), float32(nan)))
+	assert.True(t, less(1, float32(nan)))
+}
+
+func TestReverse(t *testing.T) {
+	desc := order.Reverse(order.By(func(n int) int { return n }))
+	assert.Equal(t, []int{3, 2, 1}, sorted([]int{2, 3, 1}, desc))
+	assert.False(t, desc(1, 1))
+}
+
+func TestThen(t *testing.T) {
+	tasks := []Task{{"a", 2, 0.5}, {"b", 1, 0.1}, {"c", 2, 0.9}, {"d", 1, 0.1}}
+	less := order.Then(
+		order.By(func(t Task) int { return t.Priority }),
+		order.Reverse(order.By(func(t Task) float64 { return t.Score })),
+	)
+	var ids []string
+	for _, task := range sorted(tasks, less) {
+		ids = append(ids, task.ID)
+	}
+	assert.Equal(t, []string{"b", "d", "c", "a"}, ids)
+	assert.False(t, order.Then[Task]()(tasks[0], tasks[1]))
+}
+
+func TestFIFOAndLIFO(t *testing.T) {
+	type Elem = mpqs.Elem[string, int]
+	for _, tc := range []struct {
+		less func(x, y Elem) bool
+		want []string
+	}{
+		{order.Then(order.By(Elem.Priority), order.FIFO[Elem]), []string{"b", "a", "c", "d"}},
+		{order.Then(order.By(Elem.Priority), order.LIFO[Elem]), []string{"b", "d", "c", "a"}},
+		{order.Then(order.Reverse(order.By(Elem.Priority)), order.LIFO[Elem]), []string{"d", "c", "a", "b"}},
+	} {
+		q := mpqs.New(tc.less)
+		mpqs.Enqueue(q, "a", 2)
+		mpqs.Enqueue(q, "b", 1)
+		mpqs.Enqueue(q, "c", 2)
+		mpqs.Enqueue(q, "d", 2)
+		var got []string
+		for item := range mpqs.Drain(q) {
+			got = append(got, item)
+		}
+		assert.Equal(t, tc.want, got)
+	}
+}
+
+func TestWithQueues(t *testing.T) {
+	tasks := []*Task{{"a", 2, 0.5}, {"b", 1, math.NaN()}, {"c", 1, 0.9}}
+	want := []string{"c", "a", "b"}
+	byScore := func(t *Task) float64 { return t.Score }
+
+	p := pqs.New(order.Reverse(order.By(byScore)))
+	for _, task := range tasks {
+		pqs.Enqueue(p, task)
+	}
+	var got []string
+	for task := range pqs.Drain(p) {
+		got = append(got, task.ID)
+	}
+	assert.Equal(t, want, got)
+
+	type KElem = kpqs.Elem[*Task, float64]
+	k := kpqs.New(
+		order.Then(order.Reverse(order.By(KElem.Priority)), order.FIFO[KElem]),
+		func(t *Task) string { return t.ID },
+		byScore,
+	)
+	for _, task := range tasks {
+		kpqs.Enqueue(k, task)
+	}
+	got = nil
+	for task := range kpqs.Drain(k) {
+		got = append(got, task.ID)
+	}
+	assert.Equal(t, want, got)
+
+	type KMElem = kmpqs.Elem[*Task, float64]
+	km := kmpqs.New(
+		order.Then(
+			order.By(func(e KMElem) int { return e.Item().Priority }),
+			order.Reverse(order.NaNLast(KMElem.Priority)),
+		),
+		func(t *Task) string { return t.ID },
+	)
+	for _, task := range tasks {
+		kmpqs.Enqueue(km, task, task.Score)
+	}
+	got = nil
+	for task := range kmpqs.Drain(km) {
+		got = append(got, task.ID)
+	}
+	assert.Equal(t, []string{"b", "c", "a"}, got)
+}
+
+func ExampleThen() {
+	type Elem = kmpqs.Elem[*Task, int]
+	q := kmpqs.New(
+		order.Then(
+			order.Reverse(order.By(Elem.Priority)),
+			order.By(func(e Elem) string { return e.Item().ID }),
+		),
+		func(t *Task) string { return t.ID },
+	)
+	kmpqs.Enqueue(q, &Task{ID: "deploy"}, 1)
+	kmpqs.Enqueue(q, &Task{ID: "build"}, 2)
+	kmpqs.Enqueue(q, &Task{ID: "backup"}, 1)
+	for task, prio := range kmpqs.Drain(q) {
+		fmt.Println(prio, task.ID)
+	}
+	// Output:
+	// 2 build
+	// 1 backup
+	// 1 deploy
+}
+
+func ExampleNaNLast() {
+	q := pqs.New(order.NaNLast(func(f float64) float64 { return f }))
+	for _, score := range []float64{0.7, math.NaN(), 0.2} {
+		pqs.Enqueue(q, score)
+	}
+	for score := range pqs.Drain(q) {
+		fmt.Println(score)
+	}
+	// Output:
+	// 0.2
+	// 0.7
+	// NaN
+}
+
+func ExampleLIFO() {
+	type Elem = mpqs.Elem[string, int]
+	q := mpqs.New(order.Then(order.By(Elem.Priority), order.LIFO[Elem]))
+	mpqs.Enqueue(q, "first", 1)
+	mpqs.Enqueue(q, "second", 1)
+	item, _ := mpqs.Dequeue(q)
+	fmt.Println(item)
+	// Output: second
+}
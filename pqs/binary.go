@@ -0,0 +1,83 @@
+package pqs
+
+import (
+	"errors"
+
+	"github.com/byExist/priorityqueues/snapshot"
+)
+
+// SetComparatorID sets the identity MarshalBinary records for the comparator of the priority queue,
+// and UnmarshalBinary expects, in place of snapshot.Identity. It is required for comparators that
+// snapshot.Identity cannot identify, such as the closures returned by MinFirstFunc.
+func SetComparatorID[T any](pq *PriorityQueue[T], id string) {
+	pq.comparatorID = id
+}
+
+// MarshalBinary encodes the priority queue in the versioned snapshot format described by
+// package snapshot, recording the identity of its comparator or the ID set by SetComparatorID,
+// and whether the queue is stable; without either, snapshot.ErrUnidentified is returned. Items are encoded with
+// snapshot.AppendValue, so items that are not of an ordered kind must implement
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler. A stable queue writes its items in
+// dequeue order, so restoring it preserves FIFO order among equal items.
+func (pq *PriorityQueue[T]) MarshalBinary() ([]byte, error) {
+	id := identity(pq)
+	if id == "" {
+		return nil, snapshot.ErrUnidentified
+	}
+	buf := snapshot.AppendHeader(nil, snapshot.Header{
+		Kind:       "pqs",
+		Comparator: id,
+		Count:      pq.heap.Len(),
+	})
+	var err error
+	for _, item := range snapshotItems(pq) {
+		if buf, err = snapshot.AppendValue(buf, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the contents of the priority queue with a snapshot produced by MarshalBinary.
+// The queue must have been created with the same comparator and stability the snapshot was taken with;
+// otherwise an error wrapping snapshot.ErrMismatch is returned. The queue is left unchanged on error.
+// Items are ordered by the NaN policy of the queue, and NaN items are dropped under RejectNaN.
+func (pq *PriorityQueue[T]) UnmarshalBinary(data []byte) error {
+	if pq.heap == nil {
+		return errors.New("pqs: UnmarshalBinary on a queue not created by New")
+	}
+	h, data, err := snapshot.ReadHeader(data)
+	if err != nil {
+		return err
+	}
+	if err := h.Check("pqs", identity(pq)); err != nil {
+		return err
+	}
+	items := make([]T, 0, h.Count)
+	for range h.Count {
+		var item T
+		item, data, err = snapshot.ReadValue[T](data)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+	if len(data) != 0 {
+		return snapshot.ErrFormat
+	}
+	restore(pq, items)
+	return nil
+}
+
+// identity returns the comparator identity recorded in snapshots of pq. A stable queue breaks ties
+// by insertion order, so it is told apart from an unstable one with the same comparator.
+func identity[T any](pq *PriorityQueue[T]) string {
+	id := pq.comparatorID
+	if id == "" {
+		id = snapshot.Identity(pq.lessFunc)
+	}
+	if id != "" && pq.stable {
+		id += " stable"
+	}
+	return id
+}
@@ -0,0 +1,45 @@
+package pqs
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/byExist/priorityqueues/heaps"
+)
+
+// Format implements fmt.Formatter. It prints the heap as an indented tree that mirrors the layout of
+// the queue's backend, one item per line. The %v and %s verbs format items with %v and %q quotes them.
+// Items of a stable queue are followed by their sequence numbers. The tree shows heap layout, not priority order.
+func (pq *PriorityQueue[T]) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's', 'q':
+		itemVerb := "%v"
+		if verb == 'q' {
+			itemVerb = "%q"
+		}
+		heaps.WriteTree(f, pq.heap, func(e Elem[T]) string {
+			if pq.stable {
+				return fmt.Sprintf(itemVerb+" (seq %d)", e.item, e.seq)
+			}
+			return fmt.Sprintf(itemVerb, e.item)
+		})
+	default:
+		fmt.Fprintf(f, "%%!%c(*pqs.PriorityQueue)", verb)
+	}
+}
+
+// String returns the heap as an indented tree, as printed by the %v verb.
+func (pq *PriorityQueue[T]) String() string {
+	return fmt.Sprint(pq)
+}
+
+// WriteDOT writes the heap to w as a Graphviz digraph that mirrors the layout of the queue's backend,
+// with a node per item, labelled with its sequence number if the queue is stable. The item at the top is drawn in bold.
+func (pq *PriorityQueue[T]) WriteDOT(w io.Writer) error {
+	return heaps.WriteDOT(w, pq.heap, "pqs", func(e Elem[T]) string {
+		if pq.stable {
+			return fmt.Sprintf("%v\nseq %d", e.item, e.seq)
+		}
+		return fmt.Sprint(e.item)
+	})
+}
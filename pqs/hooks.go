@@ -0,0 +1,29 @@
+package pqs
+
+import "github.com/byExist/priorityqueues/hooks"
+
+// AddHook registers a function called after every enqueue, dequeue and clear on the priority queue.
+// Events carry the item as both Item and Priority, and its sequence number as Sequence.
+// Merge reports a clear on src and an enqueue on dst for every item it moves, and restoring a
+// snapshot reports nothing.
+// Hooks must not modify the queue.
+func AddHook[T any](pq *PriorityQueue[T], hook hooks.Hook[T, T]) {
+	pq.hooks = append(pq.hooks, hook)
+}
+
+func emit[T any](pq *PriorityQueue[T], op hooks.Op, elem Elem[T]) {
+	check(pq)
+	if len(pq.hooks) == 0 {
+		return
+	}
+	e := hooks.Event[T, T]{
+		Op:       op,
+		Item:     elem.item,
+		Priority: elem.item,
+		Sequence: elem.seq,
+		Len:      pq.heap.Len(),
+	}
+	for _, hook := range pq.hooks {
+		hook(e)
+	}
+}
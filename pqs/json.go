@@ -0,0 +1,40 @@
+package pqs
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+// MarshalJSON encodes the items of the priority queue as a JSON array in unspecified order,
+// or in dequeue order if the queue is stable so that restoring it preserves FIFO order.
+// NaN and infinite floats are encoded as the strings "NaN", "+Inf" and "-Inf".
+func (pq *PriorityQueue[T]) MarshalJSON() ([]byte, error) {
+	items := snapshotItems(pq)
+	elems := make([]nan.JSON[T], len(items))
+	for i, item := range items {
+		elems[i] = nan.JSON[T]{V: item}
+	}
+	return json.Marshal(elems)
+}
+
+// UnmarshalJSON replaces the contents of the priority queue with the items of a JSON array
+// produced by MarshalJSON. The queue must have been created by New or NewWithBackend,
+// which supply the comparator; the queue is left unchanged if decoding fails.
+// Items are ordered by the NaN policy of the queue, and NaN items are dropped under RejectNaN.
+func (pq *PriorityQueue[T]) UnmarshalJSON(data []byte) error {
+	if pq.heap == nil {
+		return errors.New("pqs: UnmarshalJSON on a queue not created by New")
+	}
+	var elems []nan.JSON[T]
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	items := make([]T, len(elems))
+	for i, e := range elems {
+		items[i] = e.V
+	}
+	restore(pq, items)
+	return nil
+}
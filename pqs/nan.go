@@ -0,0 +1,74 @@
+package pqs
+
+import (
+	"errors"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
+
+// ErrNaN is returned by Enqueue when the item is NaN and the NaN policy is RejectNaN.
+var ErrNaN = errors.New("pqs: NaN item")
+
+// NaNPolicy determines how a queue of floating-point items handles NaN, which compares
+// false to every item and so breaks the heap order under MinFirst and MaxFirst.
+type NaNPolicy int
+
+const (
+	// AllowNaN leaves NaN items to the comparator. This is the default.
+	AllowNaN NaNPolicy = iota
+	// RejectNaN leaves the queue unchanged and makes Enqueue return ErrNaN.
+	RejectNaN
+	// NaNFirst dequeues NaN items before all others, in insertion order.
+	NaNFirst
+	// NaNLast dequeues NaN items after all others, in insertion order.
+	NaNLast
+)
+
+// SetNaNPolicy sets how the priority queue handles NaN items. It has no effect unless T is a
+// floating-point type. Queued items are reordered under the new policy in linear time, and under
+// RejectNaN the NaN items are removed.
+func SetNaNPolicy[T any](pq *PriorityQueue[T], policy NaNPolicy) {
+	pq.nanPolicy = policy
+	if pq.isNaN == nil {
+		return
+	}
+	if policy == RejectNaN {
+		var nodes []*heaps.Node[Elem[T]]
+		for node := range pq.heap.All() {
+			if pq.isNaN(node.Value.item) {
+				nodes = append(nodes, node)
+			}
+		}
+		for _, node := range nodes {
+			pq.heap.Remove(node)
+			emit(pq, hooks.Delete, node.Value)
+		}
+	}
+	switch policy {
+	case NaNFirst:
+		pq.nanOrder = nan.First
+	case NaNLast:
+		pq.nanOrder = nan.Last
+	default:
+		pq.nanOrder = nan.Unordered
+	}
+	heaps.Rebuild(pq.heap)
+	check(pq)
+}
+
+// nanLess returns less with NaN items placed by the NaN policy of pq.
+func nanLess[T any](pq *PriorityQueue[T], less func(x, y Elem[T]) bool) func(x, y Elem[T]) bool {
+	if pq.isNaN == nil {
+		return less
+	}
+	isNaN := func(e Elem[T]) bool { return pq.isNaN(e.item) }
+	bySeq := func(x, y Elem[T]) bool { return x.seq < y.seq }
+	return nan.Less(less, isNaN, bySeq, &pq.nanOrder)
+}
+
+// rejected reports whether item is NaN and pq rejects NaN items.
+func rejected[T any](pq *PriorityQueue[T], item T) bool {
+	return pq.nanPolicy == RejectNaN && pq.isNaN != nil && pq.isNaN(item)
+}
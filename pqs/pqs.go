@@ -2,42 +2,59 @@ package pqs
 
 import (
 	"cmp"
-	"container/heap"
-)
+	"iter"
+	"slices"
 
-type heapImpl[T cmp.Ordered] struct {
-	items    []T
-	lessFunc func(i, j T) bool
-}
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/comparator"
+	"github.com/byExist/priorityqueues/internal/debug"
+	"github.com/byExist/priorityqueues/internal/nan"
+)
 
-func (h *heapImpl[T]) Len() int {
-	return len(h.items)
+// Elem is an item in the heap of a priority queue, together with its insertion sequence number.
+type Elem[T any] struct {
+	item T
+	seq  int
 }
 
-func (h *heapImpl[T]) Less(i, j int) bool {
-	return h.lessFunc(h.items[i], h.items[j])
+// Item returns the item stored in the element.
+func (e Elem[T]) Item() T {
+	return e.item
 }
 
-func (h *heapImpl[T]) Swap(i, j int) {
-	h.items[i], h.items[j] = h.items[j], h.items[i]
+// Sequence returns the sequence number of the element.
+func (e Elem[T]) Sequence() int {
+	return e.seq
 }
 
-func (h *heapImpl[T]) Push(x any) {
-	item := x.(T)
-	h.items = append(h.items, item)
+func counter() func() int {
+	return counterFrom(0)
 }
 
-func (h *heapImpl[T]) Pop() any {
-	old := h.items
-	n := len(old)
-	item := old[n-1]
-	h.items = old[0 : n-1]
-	return item
+// counterFrom returns a counter whose first value is i+1.
+func counterFrom(i int) func() int {
+	return func() int {
+		i++
+		return i
+	}
 }
 
 // PriorityQueue represents a generic priority queue data structure.
-type PriorityQueue[T cmp.Ordered] struct {
-	heap *heapImpl[T]
+type PriorityQueue[T any] struct {
+	heap     heaps.Backend[Elem[T]]
+	counter  func() int
+	lessFunc func(x, y T) bool
+	stable   bool
+	hooks    []hooks.Hook[T, T]
+
+	comparatorID string
+
+	// less orders the heap elements by lessFunc, with NaN items placed by the NaN policy.
+	less      func(x, y Elem[T]) bool
+	nanPolicy NaNPolicy
+	nanOrder  nan.Order
+	isNaN     func(T) bool
 }
 
 // MinFirst compares two elements and returns true if x has lower priority than y.
@@ -52,53 +69,230 @@ func MaxFirst[T cmp.Ordered](x, y T) bool {
 	return x > y
 }
 
+// MinFirstFunc returns a comparator that orders items in ascending order as determined
+// by compare, such as time.Time.Compare, for item types that are not cmp.Ordered.
+func MinFirstFunc[T any](compare func(a, b T) int) func(x, y T) bool {
+	return func(x, y T) bool {
+		return compare(x, y) < 0
+	}
+}
+
+// MaxFirstFunc returns a comparator that orders items in descending order as determined by compare.
+func MaxFirstFunc[T any](compare func(a, b T) int) func(x, y T) bool {
+	return func(x, y T) bool {
+		return compare(x, y) > 0
+	}
+}
+
 // New creates a new PriorityQueue with the provided less function.
 // The lessFunc determines the priority order: it should return true if x has higher priority than y.
 // For a min-priority queue, use: func(x, y T) bool { return x < y }
-func New[T cmp.Ordered](
+func New[T any](
 	lessFunc func(x, y T) bool,
 ) *PriorityQueue[T] {
-	return &PriorityQueue[T]{
-		heap: &heapImpl[T]{
-			items:    []T{},
-			lessFunc: lessFunc,
-		},
+	return NewWithBackend(lessFunc, heaps.Binary)
+}
+
+// NewWithBackend creates a new PriorityQueue like New, storing its items in a heap created by backend,
+// such as heaps.Quaternary, heaps.Pairing or heaps.Fibonacci.
+func NewWithBackend[T any](
+	lessFunc func(x, y T) bool,
+	backend heaps.Factory[Elem[T]],
+) *PriorityQueue[T] {
+	return newQueue(lessFunc, backend, false)
+}
+
+// NewStable creates a new PriorityQueue like New, except that items lessFunc considers equal
+// are dequeued in insertion order (lower sequence number first).
+func NewStable[T any](
+	lessFunc func(x, y T) bool,
+) *PriorityQueue[T] {
+	return NewStableWithBackend(lessFunc, heaps.Binary)
+}
+
+// NewStableWithBackend creates a new stable PriorityQueue like NewStable, storing its items in a heap created by backend.
+func NewStableWithBackend[T any](
+	lessFunc func(x, y T) bool,
+	backend heaps.Factory[Elem[T]],
+) *PriorityQueue[T] {
+	return newQueue(lessFunc, backend, true)
+}
+
+func newQueue[T any](lessFunc func(x, y T) bool, backend heaps.Factory[Elem[T]], stable bool) *PriorityQueue[T] {
+	pq := &PriorityQueue[T]{
+		counter:  counter(),
+		lessFunc: lessFunc,
+		stable:   stable,
+		isNaN:    nan.Func[T](),
 	}
+	pq.less = nanLess(pq, elemLess(lessFunc, stable))
+	pq.heap = backend(debug.Less(pq.less))
+	return pq
 }
 
-// Clear removes all items from the priority queue.
-func Clear[T cmp.Ordered](pq *PriorityQueue[T]) {
-	pq.heap.items = []T{}
+// elemLess lifts lessFunc to heap elements, breaking ties by sequence number if stable is set.
+func elemLess[T any](lessFunc func(x, y T) bool, stable bool) func(x, y Elem[T]) bool {
+	if !stable {
+		return func(x, y Elem[T]) bool {
+			return lessFunc(x.item, y.item)
+		}
+	}
+	return func(x, y Elem[T]) bool {
+		if lessFunc(x.item, y.item) {
+			return true
+		}
+		return !lessFunc(y.item, x.item) && x.seq < y.seq
+	}
+}
+
+// Clear removes all items from the priority queue and resets its sequence counter.
+func Clear[T any](pq *PriorityQueue[T]) {
+	pq.heap.Clear()
+	pq.counter = counter()
+	emit(pq, hooks.Clear, Elem[T]{})
 }
 
 // Enqueue inserts a new item into the priority queue.
-func Enqueue[T cmp.Ordered](pq *PriorityQueue[T], item T) {
-	heap.Push(pq.heap, item)
+// It returns ErrNaN if the item is NaN and the NaN policy is RejectNaN.
+func Enqueue[T any](pq *PriorityQueue[T], item T) error {
+	if rejected(pq, item) {
+		return ErrNaN
+	}
+	elem := Elem[T]{item: item, seq: pq.counter()}
+	pq.heap.Push(elem)
+	emit(pq, hooks.Enqueue, elem)
+	return nil
 }
 
 // Dequeue removes and returns the highest priority item from the priority queue.
 // The boolean indicates whether an item was returned.
-func Dequeue[T cmp.Ordered](pq *PriorityQueue[T]) (T, bool) {
+func Dequeue[T any](pq *PriorityQueue[T]) (T, bool) {
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := heap.Pop(pq.heap).(T)
-	return elem, true
+	elem := pq.heap.Pop().Value
+	emit(pq, hooks.Dequeue, elem)
+	return elem.item, true
 }
 
 // Peek returns the highest priority item without removing it from the priority queue.
 // The boolean indicates whether an item was returned.
-func Peek[T cmp.Ordered](pq *PriorityQueue[T]) (T, bool) {
+func Peek[T any](pq *PriorityQueue[T]) (T, bool) {
 	if pq.heap.Len() == 0 {
 		var zero T
 		return zero, false
 	}
-	elem := pq.heap.items[0]
-	return elem, true
+	return pq.heap.Peek().Value.item, true
 }
 
 // Len returns the number of items currently in the priority queue.
-func Len[T cmp.Ordered](pq *PriorityQueue[T]) int {
+func Len[T any](pq *PriorityQueue[T]) int {
 	return pq.heap.Len()
 }
+
+// Merge moves all items of src into dst and leaves src empty.
+// With a stable dst, equal items of src are dequeued after dst's own.
+func Merge[T any](dst, src *PriorityQueue[T]) {
+	if dst == src {
+		return
+	}
+	// Order src by dst's NaN policy while it is moved, and restore its own policy afterwards.
+	if policy := src.nanPolicy; policy != dst.nanPolicy {
+		SetNaNPolicy(src, dst.nanPolicy)
+		defer SetNaNPolicy(src, policy)
+	}
+	// Renumber src to follow dst, so stable comparators put dst's elements first on ties.
+	base, last := dst.counter(), src.counter()
+	for node := range src.heap.All() {
+		node.Value.seq += base
+	}
+	// Elements moved into dst are reported as enqueued there once the meld is done.
+	var moved []Elem[T]
+	if len(dst.hooks) > 0 {
+		for node := range src.heap.All() {
+			moved = append(moved, node.Value)
+		}
+	}
+	// Pairing and Fibonacci heaps link src in constant time, which is only safe when both queues
+	// are known to order alike, including stability; otherwise src's items are inserted one by one.
+	// Array based heaps are rebuilt in linear time either way.
+	if dst.stable == src.stable && comparator.Same(dst.lessFunc, src.lessFunc) {
+		dst.heap.Meld(src.heap)
+	} else {
+		heaps.MeldUnordered(dst.heap, src.heap)
+	}
+	dst.counter = counterFrom(base + last)
+	src.counter = counter()
+	emit(src, hooks.Clear, Elem[T]{})
+	for _, elem := range moved {
+		emit(dst, hooks.Enqueue, elem)
+	}
+	check(dst)
+}
+
+// All returns an iterator over the items currently in the priority queue.
+// Items are yielded in unspecified order, not priority order, and the queue is left unchanged.
+// The queue must not be modified during iteration.
+func All[T any](pq *PriorityQueue[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for node := range pq.heap.All() {
+			if !yield(node.Value.item) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that dequeues items in priority order until the queue is empty.
+// Stopping the iteration early leaves the remaining items in the queue.
+func Drain[T any](pq *PriorityQueue[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for pq.heap.Len() > 0 {
+			elem := pq.heap.Pop().Value
+			emit(pq, hooks.Dequeue, elem)
+			if !yield(elem.item) {
+				return
+			}
+		}
+	}
+}
+
+// snapshotItems returns the items of the priority queue, in dequeue order for a stable queue
+// so that restoring them numbers equal items in the same relative order.
+func snapshotItems[T any](pq *PriorityQueue[T]) []T {
+	elems := make([]Elem[T], 0, pq.heap.Len())
+	for node := range pq.heap.All() {
+		elems = append(elems, node.Value)
+	}
+	if pq.stable {
+		slices.SortFunc(elems, func(x, y Elem[T]) int {
+			if pq.less(x, y) {
+				return -1
+			}
+			if pq.less(y, x) {
+				return 1
+			}
+			return 0
+		})
+	}
+	items := make([]T, len(elems))
+	for i, elem := range elems {
+		items[i] = elem.item
+	}
+	return items
+}
+
+// restore replaces the contents of the priority queue with items, numbered in order.
+// Items rejected by the NaN policy are skipped.
+func restore[T any](pq *PriorityQueue[T], items []T) {
+	pq.heap.Clear()
+	pq.counter = counter()
+	for _, item := range items {
+		if rejected(pq, item) {
+			continue
+		}
+		pq.heap.Push(Elem[T]{item: item, seq: pq.counter()})
+	}
+	check(pq)
+}
@@ -1,10 +1,18 @@
 package pqs_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/hooks"
+	"github.com/byExist/priorityqueues/internal/debug"
 	"github.com/byExist/priorityqueues/pqs"
+	"github.com/byExist/priorityqueues/snapshot"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -100,6 +108,497 @@ func TestMaxFirstOrdering(t *testing.T) {
 	assert.Equal(t, 10, item)
 }
 
+func TestFuncOrdering(t *testing.T) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	pq := pqs.New(pqs.MinFirstFunc(time.Time.Compare))
+	pqs.Enqueue(pq, base.Add(time.Hour))
+	pqs.Enqueue(pq, base)
+	item, _ := pqs.Dequeue(pq)
+	assert.True(t, base.Equal(item))
+
+	pq = pqs.New(pqs.MaxFirstFunc(time.Time.Compare))
+	pqs.Enqueue(pq, base)
+	pqs.Enqueue(pq, base.Add(time.Hour))
+	_, err := pq.MarshalBinary()
+	assert.ErrorIs(t, err, snapshot.ErrUnidentified)
+	pqs.SetComparatorID(pq, "latest")
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+	restored := pqs.New(pqs.MaxFirstFunc(time.Time.Compare))
+	assert.ErrorIs(t, restored.UnmarshalBinary(data), snapshot.ErrUnidentified)
+	pqs.SetComparatorID(restored, "earliest")
+	assert.ErrorIs(t, restored.UnmarshalBinary(data), snapshot.ErrMismatch)
+	pqs.SetComparatorID(restored, "latest")
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	item, _ = pqs.Dequeue(restored)
+	assert.True(t, base.Add(time.Hour).Equal(item))
+}
+
+type event struct {
+	Name     string
+	Severity int
+}
+
+func bySeverity(x, y event) bool {
+	return x.Severity > y.Severity
+}
+
+func drainNames(pq *pqs.PriorityQueue[event]) []string {
+	var names []string
+	for e := range pqs.Drain(pq) {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestNewStable(t *testing.T) {
+	for _, backend := range []heaps.Factory[pqs.Elem[event]]{heaps.Binary[pqs.Elem[event]], heaps.Pairing[pqs.Elem[event]], heaps.Fibonacci[pqs.Elem[event]]} {
+		pq := pqs.NewStableWithBackend(bySeverity, backend)
+		for i, sev := range []int{1, 3, 1, 3, 2, 1, 3} {
+			pqs.Enqueue(pq, event{fmt.Sprint("e", i), sev})
+		}
+		assert.Equal(t, []string{"e1", "e3", "e6", "e4", "e0", "e2", "e5"}, drainNames(pq))
+	}
+
+	pq := pqs.NewStable(bySeverity)
+	pqs.Enqueue(pq, event{"a", 1})
+	pqs.Clear(pq)
+	pqs.Enqueue(pq, event{"b", 1})
+	pqs.Enqueue(pq, event{"c", 1})
+	item, _ := pqs.Peek(pq)
+	assert.Equal(t, "b", item.Name)
+}
+
+func TestMergeStable(t *testing.T) {
+	dst := pqs.NewStable(bySeverity)
+	src := pqs.NewStable(bySeverity)
+	pqs.Enqueue(src, event{"src-1", 1})
+	pqs.Enqueue(dst, event{"dst-1", 1})
+	pqs.Enqueue(src, event{"src-2", 1})
+	pqs.Merge(dst, src)
+	pqs.Enqueue(dst, event{"dst-2", 1})
+	pqs.Enqueue(src, event{"src-3", 1})
+
+	assert.Equal(t, []string{"dst-1", "src-1", "src-2", "dst-2"}, drainNames(dst))
+	assert.Equal(t, []string{"src-3"}, drainNames(src))
+}
+
+func TestAll(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 3)
+	pqs.Enqueue(pq, 1)
+	pqs.Enqueue(pq, 2)
+	var items []int
+	for item := range pqs.All(pq) {
+		items = append(items, item)
+	}
+	assert.ElementsMatch(t, []int{1, 2, 3}, items)
+	assert.Equal(t, 3, pqs.Len(pq))
+}
+
+func TestDrain(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 3)
+	pqs.Enqueue(pq, 1)
+	pqs.Enqueue(pq, 2)
+	var items []int
+	for item := range pqs.Drain(pq) {
+		items = append(items, item)
+	}
+	assert.Equal(t, []int{1, 2, 3}, items)
+	assert.Equal(t, 0, pqs.Len(pq))
+}
+
+func TestDrainStopEarly(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 3)
+	pqs.Enqueue(pq, 1)
+	pqs.Enqueue(pq, 2)
+	for item := range pqs.Drain(pq) {
+		assert.Equal(t, 1, item)
+		break
+	}
+	assert.Equal(t, 2, pqs.Len(pq))
+	item, _ := pqs.Peek(pq)
+	assert.Equal(t, 2, item)
+}
+
+func TestNewWithBackend(t *testing.T) {
+	for _, backend := range []heaps.Factory[pqs.Elem[int]]{heaps.Binary[pqs.Elem[int]], heaps.Quaternary[pqs.Elem[int]], heaps.Pairing[pqs.Elem[int]], heaps.Fibonacci[pqs.Elem[int]]} {
+		pq := pqs.NewWithBackend(pqs.MaxFirst[int], backend)
+		for _, item := range []int{4, 9, 1, 7, 3} {
+			pqs.Enqueue(pq, item)
+		}
+		var items []int
+		for item := range pqs.Drain(pq) {
+			items = append(items, item)
+		}
+		assert.Equal(t, []int{9, 7, 4, 3, 1}, items)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for _, backend := range []heaps.Factory[pqs.Elem[int]]{heaps.Binary[pqs.Elem[int]], heaps.Pairing[pqs.Elem[int]], heaps.Fibonacci[pqs.Elem[int]]} {
+		dst := pqs.NewWithBackend(pqs.MinFirst[int], backend)
+		src := pqs.NewWithBackend(pqs.MinFirst[int], backend)
+		for _, v := range []int{5, 1, 9} {
+			pqs.Enqueue(dst, v)
+		}
+		for _, v := range []int{4, 8, 0} {
+			pqs.Enqueue(src, v)
+		}
+		pqs.Merge(dst, src)
+		assert.Equal(t, 0, pqs.Len(src))
+		var got []int
+		for v := range pqs.Drain(dst) {
+			got = append(got, v)
+		}
+		assert.Equal(t, []int{0, 1, 4, 5, 8, 9}, got)
+	}
+}
+
+func TestMergeMixedBackends(t *testing.T) {
+	dst := pqs.NewWithBackend(pqs.MinFirst[int], heaps.Fibonacci[pqs.Elem[int]])
+	src := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(dst, 2)
+	pqs.Enqueue(src, 1)
+	pqs.Enqueue(src, 3)
+	pqs.Merge(dst, src)
+	assert.Equal(t, 3, pqs.Len(dst))
+	item, _ := pqs.Peek(dst)
+	assert.Equal(t, 1, item)
+}
+
+func TestMergeDifferentComparators(t *testing.T) {
+	for _, backend := range []heaps.Factory[pqs.Elem[int]]{heaps.Pairing[pqs.Elem[int]], heaps.Fibonacci[pqs.Elem[int]]} {
+		dst := pqs.NewWithBackend(pqs.MinFirst[int], backend)
+		src := pqs.NewWithBackend(pqs.MaxFirst[int], backend)
+		for _, v := range []int{5, 1, 8} {
+			pqs.Enqueue(dst, v)
+		}
+		for _, v := range []int{2, 9, 3, 7} {
+			pqs.Enqueue(src, v)
+		}
+		pqs.Dequeue(src)
+		pqs.Merge(dst, src)
+		assert.Empty(t, pqs.Validate(dst))
+		var got []int
+		for v := range pqs.Drain(dst) {
+			got = append(got, v)
+		}
+		assert.Equal(t, []int{1, 2, 3, 5, 7, 8}, got)
+	}
+
+	stable := pqs.NewStableWithBackend(bySeverity, heaps.Pairing[pqs.Elem[event]])
+	unstable := pqs.NewWithBackend(bySeverity, heaps.Pairing[pqs.Elem[event]])
+	for i := range 6 {
+		pqs.Enqueue(stable, event{fmt.Sprint("s", i), i % 2})
+		pqs.Enqueue(unstable, event{fmt.Sprint("u", i), i % 2})
+	}
+	pqs.Dequeue(unstable)
+	pqs.Merge(stable, unstable)
+	assert.Empty(t, pqs.Validate(stable))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[int])
+	for _, v := range []int{3, 1, 2} {
+		pqs.Enqueue(pq, v)
+	}
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+
+	restored := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(restored, 99)
+	assert.NoError(t, json.Unmarshal(data, restored))
+	var got []int
+	for v := range pqs.Drain(restored) {
+		got = append(got, v)
+	}
+	assert.Equal(t, []int{1, 2, 3}, got)
+}
+
+func TestStableRoundTripPreservesFIFO(t *testing.T) {
+	pq := pqs.NewStable(bySeverity)
+	for i, sev := range []int{1, 2, 1, 2, 1} {
+		pqs.Enqueue(pq, event{fmt.Sprint("e", i), sev})
+	}
+	pqs.Dequeue(pq)
+
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	restored := pqs.NewStable(bySeverity)
+	assert.NoError(t, json.Unmarshal(data, restored))
+	pqs.Enqueue(restored, event{"e5", 2})
+	assert.Equal(t, []string{"e3", "e5", "e0", "e2", "e4"}, drainNames(restored))
+
+	times := pqs.NewStable(pqs.MinFirstFunc(func(a, b time.Time) int {
+		return a.Truncate(time.Hour).Compare(b.Truncate(time.Hour))
+	}))
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, m := range []int{30, 0, 10} {
+		pqs.Enqueue(times, base.Add(time.Duration(m)*time.Minute))
+	}
+	pqs.SetComparatorID(times, "by hour")
+	data, err = times.MarshalBinary()
+	assert.NoError(t, err)
+	restoredTimes := pqs.NewStable(pqs.MinFirstFunc(func(a, b time.Time) int {
+		return a.Truncate(time.Hour).Compare(b.Truncate(time.Hour))
+	}))
+	pqs.SetComparatorID(restoredTimes, "by hour")
+	assert.NoError(t, restoredTimes.UnmarshalBinary(data))
+	var got []int
+	for v := range pqs.Drain(restoredTimes) {
+		got = append(got, v.Minute())
+	}
+	assert.Equal(t, []int{30, 0, 10}, got)
+}
+
+func TestJSONNonFiniteItems(t *testing.T) {
+	newQueue := func() *pqs.PriorityQueue[float64] {
+		pq := pqs.NewStable(pqs.MinFirst[float64])
+		pqs.SetNaNPolicy(pq, pqs.NaNFirst)
+		return pq
+	}
+	pq := newQueue()
+	for _, v := range []float64{2, math.NaN(), math.Inf(-1), 1} {
+		pqs.Enqueue(pq, v)
+	}
+	data, err := json.Marshal(pq)
+	assert.NoError(t, err)
+	assert.Equal(t, `["NaN","-Inf",1,2]`, string(data))
+
+	restored := newQueue()
+	assert.NoError(t, json.Unmarshal(data, restored))
+	got := drainFloats(restored)
+	assert.True(t, math.IsNaN(got[0]))
+	assert.Equal(t, []float64{math.Inf(-1), 1, 2}, got[1:])
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	var zero pqs.PriorityQueue[int]
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), &zero))
+
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 1)
+	assert.Error(t, json.Unmarshal([]byte(`{"bad":1}`), pq))
+	assert.Equal(t, 1, pqs.Len(pq))
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	pq := pqs.New(pqs.MaxFirst[float64])
+	for _, v := range []float64{1.5, -2, 8} {
+		pqs.Enqueue(pq, v)
+	}
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+
+	restored := pqs.NewWithBackend(pqs.MaxFirst[float64], heaps.Pairing)
+	assert.NoError(t, restored.UnmarshalBinary(data))
+	var got []float64
+	for v := range pqs.Drain(restored) {
+		got = append(got, v)
+	}
+	assert.Equal(t, []float64{8, 1.5, -2}, got)
+}
+
+func TestUnmarshalBinaryMismatch(t *testing.T) {
+	pq := pqs.New(pqs.MaxFirst[int])
+	pqs.Enqueue(pq, 1)
+	data, _ := pq.MarshalBinary()
+
+	other := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(other, 5)
+	assert.ErrorIs(t, other.UnmarshalBinary(data), snapshot.ErrMismatch)
+	assert.ErrorIs(t, other.UnmarshalBinary(data[:len(data)-1]), snapshot.ErrFormat)
+	assert.ErrorIs(t, other.UnmarshalBinary(append(data, 0)), snapshot.ErrMismatch)
+	item, _ := pqs.Peek(other)
+	assert.Equal(t, 5, item)
+}
+
+func TestUnmarshalBinaryStability(t *testing.T) {
+	stable := pqs.NewStable(pqs.MinFirst[int])
+	pqs.Enqueue(stable, 1)
+	data, _ := stable.MarshalBinary()
+	unstable := pqs.New(pqs.MinFirst[int])
+	assert.ErrorIs(t, unstable.UnmarshalBinary(data), snapshot.ErrMismatch)
+
+	data, _ = unstable.MarshalBinary()
+	assert.ErrorIs(t, stable.UnmarshalBinary(data), snapshot.ErrMismatch)
+	assert.Equal(t, 1, pqs.Len(stable))
+}
+
+func TestRestoreNaNPolicy(t *testing.T) {
+	nan := math.NaN()
+	pq := pqs.New(pqs.MinFirst[float64])
+	for _, v := range []float64{2, nan, 1} {
+		pqs.Enqueue(pq, v)
+	}
+	data, err := pq.MarshalBinary()
+	assert.NoError(t, err)
+	text, err := json.Marshal(pq)
+	assert.NoError(t, err)
+
+	restores := map[string]func(*pqs.PriorityQueue[float64]) error{
+		"binary": func(q *pqs.PriorityQueue[float64]) error { return q.UnmarshalBinary(data) },
+		"json":   func(q *pqs.PriorityQueue[float64]) error { return json.Unmarshal(text, q) },
+	}
+	for name, restore := range restores {
+		rejecting := pqs.New(pqs.MinFirst[float64])
+		pqs.SetNaNPolicy(rejecting, pqs.RejectNaN)
+		assert.NoError(t, restore(rejecting), name)
+		assert.Equal(t, []float64{1, 2}, drainFloats(rejecting), name)
+
+		last := pqs.New(pqs.MinFirst[float64])
+		pqs.SetNaNPolicy(last, pqs.NaNLast)
+		assert.NoError(t, restore(last), name)
+		assert.Empty(t, pqs.Validate(last), name)
+		got := drainFloats(last)
+		assert.Equal(t, []float64{1, 2}, got[:2], name)
+		assert.True(t, math.IsNaN(got[2]), name)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[int])
+	var events []hooks.Event[int, int]
+	pqs.AddHook(pq, func(e hooks.Event[int, int]) { events = append(events, e) })
+
+	pqs.Enqueue(pq, 2)
+	pqs.Enqueue(pq, 1)
+	pqs.Dequeue(pq)
+	pqs.Merge(pqs.New(pqs.MinFirst[int]), pq)
+
+	assert.Equal(t, []hooks.Event[int, int]{
+		{Op: hooks.Enqueue, Item: 2, Priority: 2, Sequence: 1, Len: 1},
+		{Op: hooks.Enqueue, Item: 1, Priority: 1, Sequence: 2, Len: 2},
+		{Op: hooks.Dequeue, Item: 1, Priority: 1, Sequence: 2, Len: 1},
+		{Op: hooks.Clear},
+	}, events)
+}
+
+func TestValidate(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[float64])
+	pqs.Enqueue(pq, 1)
+	assert.Empty(t, pqs.Validate(pq))
+
+	// NaN is incomparable to both 1 and 3, which compare normally.
+	corrupt := func() {
+		for _, v := range []float64{math.NaN(), 2, 3} {
+			pqs.Enqueue(pq, v)
+		}
+	}
+	if debug.Enabled {
+		assert.Panics(t, corrupt)
+		return
+	}
+	corrupt()
+	errs := pqs.Validate(pq)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], heaps.ErrComparator)
+}
+
+func TestFormat(t *testing.T) {
+	pq := pqs.New(pqs.MinFirst[string])
+	for _, s := range []string{"c", "a", "b"} {
+		pqs.Enqueue(pq, s)
+	}
+	assert.Equal(t, "a\n├── c\n└── b", pq.String())
+	assert.Equal(t, "\"a\"\n├── \"c\"\n└── \"b\"", fmt.Sprintf("%q", pq))
+	assert.Equal(t, "%!d(*pqs.PriorityQueue)", fmt.Sprintf("%d", pq))
+	assert.Equal(t, "", pqs.New(pqs.MinFirst[int]).String())
+
+	var sb strings.Builder
+	assert.NoError(t, pq.WriteDOT(&sb))
+	assert.Equal(t, `digraph "pqs" {
+	node [shape=box];
+	n0 [label="a", style=bold];
+	n1 [label="c"];
+	n0 -> n1;
+	n2 [label="b"];
+	n0 -> n2;
+}
+`, sb.String())
+}
+
+func TestFormatStable(t *testing.T) {
+	pq := pqs.NewStable(pqs.MinFirst[string])
+	for _, s := range []string{"b", "a", "b"} {
+		pqs.Enqueue(pq, s)
+	}
+	assert.Equal(t, "a (seq 2)\n├── b (seq 1)\n└── b (seq 3)", pq.String())
+	assert.Equal(t, "\"a\" (seq 2)\n├── \"b\" (seq 1)\n└── \"b\" (seq 3)", fmt.Sprintf("%q", pq))
+
+	var sb strings.Builder
+	assert.NoError(t, pq.WriteDOT(&sb))
+	assert.Contains(t, sb.String(), `n0 [label="a\nseq 2", style=bold];`)
+}
+
+func drainFloats(pq *pqs.PriorityQueue[float64]) []float64 {
+	var got []float64
+	for v := range pqs.Drain(pq) {
+		got = append(got, v)
+	}
+	return got
+}
+
+func TestNaNPolicy(t *testing.T) {
+	nan := math.NaN()
+	for _, backend := range []heaps.Factory[pqs.Elem[float64]]{
+		heaps.Binary[pqs.Elem[float64]],
+		heaps.Pairing[pqs.Elem[float64]],
+		heaps.Fibonacci[pqs.Elem[float64]],
+	} {
+		pq := pqs.NewWithBackend(pqs.MaxFirst[float64], backend)
+		pqs.SetNaNPolicy(pq, pqs.NaNLast)
+		for _, v := range []float64{2, nan, 3, 1, nan} {
+			assert.NoError(t, pqs.Enqueue(pq, v))
+		}
+		assert.Empty(t, pqs.Validate(pq))
+		got := drainFloats(pq)
+		assert.Equal(t, []float64{3, 2, 1}, got[:3])
+		assert.True(t, math.IsNaN(got[3]) && math.IsNaN(got[4]))
+
+		pqs.SetNaNPolicy(pq, pqs.NaNFirst)
+		for _, v := range []float64{2, nan, 3} {
+			pqs.Enqueue(pq, v)
+		}
+		got = drainFloats(pq)
+		assert.True(t, math.IsNaN(got[0]))
+		assert.Equal(t, []float64{3, 2}, got[1:])
+	}
+
+	pq := pqs.New(pqs.MinFirst[float64])
+	pqs.Enqueue(pq, 1)
+	pqs.Enqueue(pq, nan)
+	pqs.SetNaNPolicy(pq, pqs.RejectNaN)
+	assert.Equal(t, 1, pqs.Len(pq))
+	assert.ErrorIs(t, pqs.Enqueue(pq, nan), pqs.ErrNaN)
+	assert.Equal(t, []float64{1}, drainFloats(pq))
+}
+
+func TestNaNPolicyStable(t *testing.T) {
+	type reading struct {
+		name  string
+		value float64
+	}
+	pq := pqs.NewStable(func(x, y reading) bool { return x.value < y.value })
+	pqs.SetNaNPolicy(pq, pqs.NaNFirst)
+	// Only float item types are checked, so the policy does not apply to structs.
+	pqs.Enqueue(pq, reading{"a", 1})
+	pqs.Enqueue(pq, reading{"b", 1})
+	item, _ := pqs.Dequeue(pq)
+	assert.Equal(t, "a", item.name)
+
+	type celsius float64
+	q := pqs.NewStable(pqs.MinFirst[celsius])
+	pqs.SetNaNPolicy(q, pqs.NaNLast)
+	pqs.Enqueue(q, celsius(math.NaN()))
+	pqs.Enqueue(q, 20)
+	v, _ := pqs.Peek(q)
+	assert.Equal(t, celsius(20), v)
+}
+
 func Example_stringLengthPriority() {
 	lengthPriority := func(x, y string) bool {
 		return len(x) < len(y)
@@ -181,3 +680,108 @@ func ExampleMaxFirst() {
 	fmt.Println(pqs.MaxFirst(1, 2))
 	// Output: false
 }
+
+func ExampleAll() {
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 3)
+	pqs.Enqueue(pq, 1)
+	pqs.Enqueue(pq, 2)
+	sum := 0
+	for item := range pqs.All(pq) {
+		sum += item
+	}
+	fmt.Println(sum, pqs.Len(pq))
+	// Output: 6 3
+}
+
+func ExampleDrain() {
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 3)
+	pqs.Enqueue(pq, 1)
+	pqs.Enqueue(pq, 2)
+	for item := range pqs.Drain(pq) {
+		fmt.Println(item)
+	}
+	// Output:
+	// 1
+	// 2
+	// 3
+}
+
+func ExampleMerge() {
+	a := pqs.New(pqs.MinFirst[int])
+	b := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(a, 3)
+	pqs.Enqueue(b, 1)
+	pqs.Enqueue(b, 2)
+	pqs.Merge(a, b)
+	fmt.Println(pqs.Len(a), pqs.Len(b))
+	for item := range pqs.Drain(a) {
+		fmt.Println(item)
+	}
+	// Output:
+	// 3 0
+	// 1
+	// 2
+	// 3
+}
+
+func ExamplePriorityQueue_MarshalJSON() {
+	pq := pqs.New(pqs.MinFirst[int])
+	pqs.Enqueue(pq, 1)
+	data, _ := json.Marshal(pq)
+	fmt.Println(string(data))
+	// Output: [1]
+}
+
+func ExamplePriorityQueue_UnmarshalJSON() {
+	// Create the queue with its comparator first, then restore the contents into it.
+	pq := pqs.New(pqs.MaxFirst[int])
+	_ = json.Unmarshal([]byte(`[1,3,2]`), pq)
+	item, _ := pqs.Peek(pq)
+	fmt.Println(item)
+	// Output: 3
+}
+
+func ExamplePriorityQueue_String() {
+	pq := pqs.New(pqs.MinFirst[int])
+	for _, v := range []int{5, 3, 8, 1, 9, 2} {
+		pqs.Enqueue(pq, v)
+	}
+	fmt.Println(pq)
+	// Output:
+	// 1
+	// ├── 3
+	// │   ├── 5
+	// │   └── 9
+	// └── 2
+	//     └── 8
+}
+
+func ExampleNewStable() {
+	type Event struct {
+		Name     string
+		Severity int
+	}
+	pq := pqs.NewStable(func(x, y Event) bool { return x.Severity > y.Severity })
+	pqs.Enqueue(pq, Event{"disk full", 2})
+	pqs.Enqueue(pq, Event{"cert expiring", 1})
+	pqs.Enqueue(pq, Event{"node down", 2})
+	for e := range pqs.Drain(pq) {
+		fmt.Println(e.Name)
+	}
+	// Output:
+	// disk full
+	// node down
+	// cert expiring
+}
+
+func ExampleSetNaNPolicy() {
+	pq := pqs.New(pqs.MinFirst[float64])
+	pqs.SetNaNPolicy(pq, pqs.RejectNaN)
+	fmt.Println(pqs.Enqueue(pq, math.NaN()))
+	fmt.Println(pqs.Enqueue(pq, 0.5))
+	// Output:
+	// pqs: NaN item
+	// <nil>
+}
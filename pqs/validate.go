@@ -0,0 +1,33 @@
+package pqs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/byExist/priorityqueues/heaps"
+	"github.com/byExist/priorityqueues/internal/debug"
+)
+
+// Validate checks the heap order of the priority queue under its comparator and the structure of
+// its heap, and returns every violation found, or nil if there is none. Order violations wrap a
+// *heaps.OrderError, and a comparator that is not a strict weak ordering, such as one comparing NaN
+// items, is reported with heaps.ErrComparator; see heaps.Validate. Validate takes linear time.
+//
+// Building with the pqdebug tag makes every mutating operation validate the queue and panic on a violation.
+func Validate[T any](pq *PriorityQueue[T]) []error {
+	errs := heaps.Validate(pq.heap)
+	for i, err := range errs {
+		var oe *heaps.OrderError[Elem[T]]
+		if errors.As(err, &oe) {
+			errs[i] = fmt.Errorf("%w: %v is below %v", err, oe.Node.Value.item, oe.Ancestor.Value.item)
+		}
+	}
+	return errs
+}
+
+// check validates the priority queue when built with the pqdebug tag.
+func check[T any](pq *PriorityQueue[T]) {
+	if debug.Enabled {
+		debug.Check(Validate(pq))
+	}
+}
@@ -0,0 +1,414 @@
+// Package snapshot defines the versioned binary format used by the MarshalBinary and
+// UnmarshalBinary methods of the priority queue packages.
+//
+// A snapshot starts with a header:
+//
+//	magic       4 bytes  "PQSS"
+//	version     uvarint  currently 2
+//	kind        bytes    name of the queue package, e.g. "kmpqs"
+//	comparator  bytes    identity of the queue's less function, see Identity, or the
+//	                     comparator ID the queue was given
+//	count       uvarint  number of elements that follow
+//
+// where bytes is a uvarint length followed by that many bytes. Each element of
+// pqs is a value; each element of mpqs, kpqs and kmpqs is
+//
+//	seq       varint   insertion sequence number
+//	priority  value    the element's priority
+//	item      bytes    the item as encoded by the caller-supplied Codec
+//
+// Since version 2, each element of mpqs and kmpqs is followed by
+//
+//	expiry    time     when the element expires, see AppendTime
+//
+// Ordered values are stored by kind: signed integers as varint, unsigned integers
+// as uvarint, floats as 8 big-endian IEEE 754 bytes and strings as bytes. Values of
+// other kinds, such as time.Time priorities, are stored as the bytes produced by
+// their MarshalBinary method, see AppendValue.
+package snapshot
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// Version is the snapshot format version written by this package.
+// ReadHeader also accepts every earlier version.
+const Version = 2
+
+var magic = []byte("PQSS")
+
+var (
+	// ErrFormat is returned when data is not a well-formed snapshot.
+	ErrFormat = errors.New("snapshot: malformed data")
+	// ErrVersion is returned when a snapshot was written in an unsupported format version.
+	ErrVersion = errors.New("snapshot: unsupported version")
+	// ErrMismatch is returned when a snapshot was taken from a different kind of queue
+	// or from a queue with a different comparator.
+	ErrMismatch = errors.New("snapshot: queue mismatch")
+	// ErrUnidentified is returned when a queue is ordered by a comparator that Identity cannot
+	// identify, such as a closure, and has not been given a comparator ID instead.
+	ErrUnidentified = errors.New("snapshot: comparator cannot be identified")
+	// ErrNoCodec is returned when a queue has no item codec to encode or decode its items.
+	ErrNoCodec = errors.New("snapshot: no item codec")
+	// ErrUnsupported is returned when a priority has neither an ordered kind
+	// nor a binary encoding of its own.
+	ErrUnsupported = errors.New("snapshot: value has no binary encoding")
+)
+
+// Codec encodes and decodes items of type V.
+type Codec[V any] struct {
+	// Append appends the encoding of v to buf and returns the extended buffer.
+	Append func(buf []byte, v V) ([]byte, error)
+	// Decode decodes a value from data, which holds exactly what Append produced.
+	Decode func(data []byte) (V, error)
+}
+
+// OrderedCodec returns a Codec for ordered values using the snapshot's ordered encoding.
+func OrderedCodec[V cmp.Ordered]() Codec[V] {
+	return Codec[V]{
+		Append: func(buf []byte, v V) ([]byte, error) {
+			return AppendOrdered(buf, v), nil
+		},
+		Decode: func(data []byte) (V, error) {
+			v, rest, err := ReadOrdered[V](data)
+			if err == nil && len(rest) != 0 {
+				err = ErrFormat
+			}
+			return v, err
+		},
+	}
+}
+
+// JSONCodec returns a Codec that encodes values with encoding/json.
+func JSONCodec[V any]() Codec[V] {
+	return Codec[V]{
+		Append: func(buf []byte, v V) ([]byte, error) {
+			data, err := json.Marshal(v)
+			return append(buf, data...), err
+		},
+		Decode: func(data []byte) (V, error) {
+			var v V
+			err := json.Unmarshal(data, &v)
+			return v, err
+		},
+	}
+}
+
+// Header is the header of a snapshot.
+type Header struct {
+	Version    int
+	Kind       string
+	Comparator string
+	Count      int
+}
+
+// closure matches the runtime names of function literals and method values. Every closure
+// made by the same function shares one name whatever it captures, and the names can change
+// between builds.
+var closure = regexp.MustCompile(`\.func\d|-fm$`)
+
+// Identity returns the identity of fn recorded in snapshot headers: the name the runtime
+// reports for it followed by its type. The runtime elides the type arguments of generic
+// functions, as in "github.com/byExist/priorityqueues/mpqs.StableMinFirst[...]", so the
+// type tells different instantiations apart. Identity returns the empty string for closures,
+// such as the comparators returned by mpqs.MinFirstFunc, since it cannot tell them apart.
+func Identity(fn any) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil || closure.MatchString(f.Name()) {
+		return ""
+	}
+	return f.Name() + " " + v.Type().String()
+}
+
+// AppendHeader appends h, written as the current Version, to buf.
+func AppendHeader(buf []byte, h Header) []byte {
+	buf = append(buf, magic...)
+	buf = binary.AppendUvarint(buf, Version)
+	buf = AppendBytes(buf, []byte(h.Kind))
+	buf = AppendBytes(buf, []byte(h.Comparator))
+	return binary.AppendUvarint(buf, uint64(h.Count))
+}
+
+// ReadHeader reads a header from data and returns it with the remaining data.
+func ReadHeader(data []byte) (Header, []byte, error) {
+	var h Header
+	if !bytes.HasPrefix(data, magic) {
+		return h, nil, ErrFormat
+	}
+	data = data[len(magic):]
+	version, data, err := ReadUvarint(data)
+	if err != nil {
+		return h, nil, err
+	}
+	if version < 1 || version > Version {
+		return h, nil, fmt.Errorf("%w: %d", ErrVersion, version)
+	}
+	h.Version = int(version)
+	kind, data, err := ReadBytes(data)
+	if err != nil {
+		return h, nil, err
+	}
+	comparator, data, err := ReadBytes(data)
+	if err != nil {
+		return h, nil, err
+	}
+	count, data, err := ReadUvarint(data)
+	if err != nil {
+		return h, nil, err
+	}
+	if count > uint64(len(data)) {
+		// Every element takes at least one byte.
+		return h, nil, ErrFormat
+	}
+	h.Kind, h.Comparator, h.Count = string(kind), string(comparator), int(count)
+	return h, data, nil
+}
+
+// Check reports ErrMismatch unless the header was written for a queue of the given
+// kind ordered by a comparator with the given identity. An empty identity reports ErrUnidentified.
+func (h Header) Check(kind, comparator string) error {
+	if h.Kind != kind {
+		return fmt.Errorf("%w: snapshot of %s, restoring into %s", ErrMismatch, h.Kind, kind)
+	}
+	if comparator == "" {
+		return ErrUnidentified
+	}
+	if h.Comparator != comparator {
+		return fmt.Errorf("%w: snapshot ordered by %s, restoring into a queue ordered by %s", ErrMismatch, h.Comparator, comparator)
+	}
+	return nil
+}
+
+// AppendBytes appends b to buf, prefixed with its length.
+func AppendBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// ReadBytes reads a length-prefixed byte slice from data and returns it with the remaining data.
+func ReadBytes(data []byte) ([]byte, []byte, error) {
+	n, data, err := ReadUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > uint64(len(data)) {
+		return nil, nil, ErrFormat
+	}
+	return data[:n], data[n:], nil
+}
+
+// ReadUvarint reads a uvarint from data and returns it with the remaining data.
+func ReadUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, ErrFormat
+	}
+	return v, data[n:], nil
+}
+
+// ReadVarint reads a varint from data and returns it with the remaining data.
+func ReadVarint(data []byte) (int64, []byte, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 {
+		return 0, nil, ErrFormat
+	}
+	return v, data[n:], nil
+}
+
+// AppendOrdered appends the ordered encoding of v to buf.
+func AppendOrdered[V cmp.Ordered](buf []byte, v V) []byte {
+	buf, _ = appendKind(buf, reflect.ValueOf(v))
+	return buf
+}
+
+// ReadOrdered reads an ordered value from data and returns it with the remaining data.
+func ReadOrdered[V cmp.Ordered](data []byte) (V, []byte, error) {
+	var v V
+	rest, _, err := readKind(data, reflect.ValueOf(&v).Elem())
+	return v, rest, err
+}
+
+// AppendValue appends v to buf. Values of an ordered kind use the ordered encoding, so
+// AppendValue and AppendOrdered agree on them; other values must implement
+// encoding.BinaryMarshaler and are stored as bytes, or ErrUnsupported is returned.
+func AppendValue[V any](buf []byte, v V) ([]byte, error) {
+	if buf, ok := appendKind(buf, reflect.ValueOf(&v).Elem()); ok {
+		return buf, nil
+	}
+	m, ok := any(v).(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupported, v)
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return AppendBytes(buf, b), nil
+}
+
+// ReadValue reads a value written by AppendValue from data and returns it with the remaining data.
+// Values that are not of an ordered kind must implement encoding.BinaryUnmarshaler through a pointer.
+func ReadValue[V any](data []byte) (V, []byte, error) {
+	var v V
+	rest, ok, err := readKind(data, reflect.ValueOf(&v).Elem())
+	if ok {
+		return v, rest, err
+	}
+	u, ok := any(&v).(encoding.BinaryUnmarshaler)
+	if !ok {
+		return v, nil, fmt.Errorf("%w: %T", ErrUnsupported, v)
+	}
+	b, rest, err := ReadBytes(data)
+	if err != nil {
+		return v, nil, err
+	}
+	if err := u.UnmarshalBinary(b); err != nil {
+		return v, nil, err
+	}
+	return v, rest, nil
+}
+
+// appendKind appends the ordered encoding of rv to buf, reporting false if rv is not of an ordered kind.
+func appendKind(buf []byte, rv reflect.Value) ([]byte, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(rv.Float())), true
+	case reflect.String:
+		return AppendBytes(buf, []byte(rv.String())), true
+	default:
+		return buf, false
+	}
+}
+
+// readKind reads an ordered value from data into rv, reporting false if rv is not of an ordered kind.
+func readKind(data []byte, rv reflect.Value) ([]byte, bool, error) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, rest, err := ReadVarint(data)
+		if err != nil || rv.OverflowInt(i) {
+			return nil, true, ErrFormat
+		}
+		rv.SetInt(i)
+		return rest, true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, rest, err := ReadUvarint(data)
+		if err != nil || rv.OverflowUint(u) {
+			return nil, true, ErrFormat
+		}
+		rv.SetUint(u)
+		return rest, true, nil
+	case reflect.Float32, reflect.Float64:
+		if len(data) < 8 {
+			return nil, true, ErrFormat
+		}
+		rv.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(data)))
+		return data[8:], true, nil
+	case reflect.String:
+		b, rest, err := ReadBytes(data)
+		if err != nil {
+			return nil, true, err
+		}
+		rv.SetString(string(b))
+		return rest, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// AppendTime appends t to buf as a presence byte, 0 for the zero time and 1 otherwise,
+// followed for other times by a varint of Unix seconds and a uvarint of nanoseconds.
+// Every time, including the Unix epoch and times past 2262, survives the round trip.
+func AppendTime(buf []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return append(buf, 0)
+	}
+	buf = append(buf, 1)
+	buf = binary.AppendVarint(buf, t.Unix())
+	return binary.AppendUvarint(buf, uint64(t.Nanosecond()))
+}
+
+// ReadTime reads a time written by AppendTime from data and returns it with the remaining data.
+func ReadTime(data []byte) (time.Time, []byte, error) {
+	if len(data) == 0 || data[0] > 1 {
+		return time.Time{}, nil, ErrFormat
+	}
+	if data[0] == 0 {
+		return time.Time{}, data[1:], nil
+	}
+	sec, data, err := ReadVarint(data[1:])
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	nsec, data, err := ReadUvarint(data)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if nsec >= uint64(time.Second) {
+		return time.Time{}, nil, ErrFormat
+	}
+	return time.Unix(sec, int64(nsec)), data, nil
+}
+
+// AppendElem appends an element with the given item, priority and sequence number to buf,
+// encoding the item with codec and the priority with AppendValue.
+func AppendElem[T any, P any](buf []byte, codec Codec[T], item T, prio P, seq int) ([]byte, error) {
+	buf = binary.AppendVarint(buf, int64(seq))
+	buf, err := AppendValue(buf, prio)
+	if err != nil {
+		return nil, err
+	}
+	start := len(buf)
+	buf, err = codec.Append(buf, item)
+	if err != nil {
+		return nil, err
+	}
+	// Shift the encoded item to make room for its length prefix.
+	n := len(buf) - start
+	var prefix [binary.MaxVarintLen64]byte
+	k := binary.PutUvarint(prefix[:], uint64(n))
+	buf = append(buf, prefix[:k]...)
+	copy(buf[start+k:], buf[start:start+n])
+	copy(buf[start:], prefix[:k])
+	return buf, nil
+}
+
+// ReadElem reads an element written by AppendElem from data and returns its item, priority
+// and sequence number with the remaining data.
+func ReadElem[T any, P any](data []byte, codec Codec[T]) (item T, prio P, seq int, rest []byte, err error) {
+	s, data, err := ReadVarint(data)
+	if err != nil {
+		return item, prio, 0, nil, err
+	}
+	prio, data, err = ReadValue[P](data)
+	if err != nil {
+		return item, prio, 0, nil, err
+	}
+	encoded, data, err := ReadBytes(data)
+	if err != nil {
+		return item, prio, 0, nil, err
+	}
+	item, err = codec.Decode(encoded)
+	if err != nil {
+		return item, prio, 0, nil, err
+	}
+	return item, prio, int(s), data, nil
+}
@@ -0,0 +1,212 @@
+package snapshot_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/snapshot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	h := snapshot.Header{Kind: "kmpqs", Comparator: "pkg.Less", Count: 2}
+	data := snapshot.AppendHeader(nil, h)
+	data = append(data, 1, 2)
+
+	got, rest, err := snapshot.ReadHeader(data)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot.Header{Version: snapshot.Version, Kind: "kmpqs", Comparator: "pkg.Less", Count: 2}, got)
+	assert.Equal(t, []byte{1, 2}, rest)
+}
+
+func TestReadHeaderErrors(t *testing.T) {
+	_, _, err := snapshot.ReadHeader([]byte("JSON"))
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+
+	data := snapshot.AppendHeader(nil, snapshot.Header{Kind: "pqs"})
+	data[4] = 9 // version
+	_, _, err = snapshot.ReadHeader(data)
+	assert.ErrorIs(t, err, snapshot.ErrVersion)
+	data[4] = 0
+	_, _, err = snapshot.ReadHeader(data)
+	assert.ErrorIs(t, err, snapshot.ErrVersion)
+
+	// Snapshots written in earlier versions remain readable.
+	data[4] = 1
+	h, _, err := snapshot.ReadHeader(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, h.Version)
+
+	data = snapshot.AppendHeader(nil, snapshot.Header{Kind: "pqs", Count: 5})
+	_, _, err = snapshot.ReadHeader(data)
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+
+	data = snapshot.AppendHeader(nil, snapshot.Header{Kind: "pqs", Comparator: "pkg.Less"})
+	_, _, err = snapshot.ReadHeader(data[:len(data)-3])
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+}
+
+func TestHeaderCheck(t *testing.T) {
+	h := snapshot.Header{Kind: "mpqs", Comparator: "pkg.Less"}
+	assert.NoError(t, h.Check("mpqs", "pkg.Less"))
+	assert.ErrorIs(t, h.Check("kmpqs", "pkg.Less"), snapshot.ErrMismatch)
+	assert.ErrorIs(t, h.Check("mpqs", "pkg.More"), snapshot.ErrMismatch)
+}
+
+func roundTrip[V interface {
+	~int | ~int8 | ~uint16 | ~uint64 | ~float32 | ~float64 | ~string
+}](t *testing.T, values ...V) {
+	var data []byte
+	for _, v := range values {
+		data = snapshot.AppendOrdered(data, v)
+	}
+	for _, want := range values {
+		var got V
+		var err error
+		got, data, err = snapshot.ReadOrdered[V](data)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	assert.Empty(t, data)
+}
+
+type level string
+
+func TestOrderedRoundTrip(t *testing.T) {
+	roundTrip(t, 0, -1, math.MaxInt, math.MinInt)
+	roundTrip[int8](t, -128, 127)
+	roundTrip[uint16](t, 0, math.MaxUint16)
+	roundTrip[uint64](t, math.MaxUint64)
+	roundTrip(t, 1.5, -0.25, math.Inf(1))
+	roundTrip[float32](t, 3.25)
+	roundTrip(t, "", "hello", strings.Repeat("x", 300))
+	roundTrip[level](t, "high")
+}
+
+func TestReadOrderedOverflow(t *testing.T) {
+	data := snapshot.AppendOrdered(nil, 300)
+	_, _, err := snapshot.ReadOrdered[int8](data)
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	data, err := snapshot.AppendValue(nil, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot.AppendOrdered(nil, 42), data)
+
+	deadline := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+	data, err = snapshot.AppendValue(data, deadline)
+	assert.NoError(t, err)
+	n, rest, err := snapshot.ReadValue[int](data)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, n)
+	got, rest, err := snapshot.ReadValue[time.Time](rest)
+	assert.NoError(t, err)
+	assert.True(t, deadline.Equal(got))
+	assert.Empty(t, rest)
+
+	type pair struct{ a, b int }
+	_, err = snapshot.AppendValue(nil, pair{1, 2})
+	assert.ErrorIs(t, err, snapshot.ErrUnsupported)
+	_, _, err = snapshot.ReadValue[pair](data)
+	assert.ErrorIs(t, err, snapshot.ErrUnsupported)
+}
+
+func TestElemRoundTrip(t *testing.T) {
+	codec := snapshot.JSONCodec[map[string]int]()
+	data, err := snapshot.AppendElem(nil, codec, map[string]int{"a": 1}, 2.5, 7)
+	assert.NoError(t, err)
+	data, err = snapshot.AppendElem(data, codec, map[string]int{"b": 2}, -1.0, 8)
+	assert.NoError(t, err)
+
+	item, prio, seq, rest, err := snapshot.ReadElem[map[string]int, float64](data, codec)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1}, item)
+	assert.Equal(t, 2.5, prio)
+	assert.Equal(t, 7, seq)
+	item, prio, seq, rest, err = snapshot.ReadElem[map[string]int, float64](rest, codec)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"b": 2}, item)
+	assert.Equal(t, -1.0, prio)
+	assert.Equal(t, 8, seq)
+	assert.Empty(t, rest)
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC)
+	data := snapshot.AppendTime(nil, at)
+	data = snapshot.AppendTime(data, time.Time{})
+
+	got, data, err := snapshot.ReadTime(data)
+	assert.NoError(t, err)
+	assert.True(t, at.Equal(got))
+	got, data, err = snapshot.ReadTime(data)
+	assert.NoError(t, err)
+	assert.True(t, got.IsZero())
+	assert.Empty(t, data)
+
+	_, _, err = snapshot.ReadTime(nil)
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+	_, _, err = snapshot.ReadTime([]byte{2})
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+}
+
+func TestTimeRoundTripEdges(t *testing.T) {
+	for _, at := range []time.Time{
+		time.Unix(0, 0),
+		time.Unix(-1, 999999999),
+		time.Date(2262, 4, 12, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC),
+	} {
+		got, rest, err := snapshot.ReadTime(snapshot.AppendTime(nil, at))
+		assert.NoError(t, err)
+		assert.Empty(t, rest)
+		assert.True(t, at.Equal(got), "%v read back as %v", at, got)
+		assert.False(t, got.IsZero(), "%v read back as the zero time", at)
+	}
+}
+
+func TestOrderedCodec(t *testing.T) {
+	codec := snapshot.OrderedCodec[string]()
+	data, err := codec.Append(nil, "job")
+	assert.NoError(t, err)
+	v, err := codec.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "job", v)
+	_, err = codec.Decode(append(data, 0))
+	assert.ErrorIs(t, err, snapshot.ErrFormat)
+}
+
+func less(a, b int) bool {
+	return a < b
+}
+
+func lessGeneric[T int | string](a, b T) bool {
+	return a < b
+}
+
+func TestIdentity(t *testing.T) {
+	assert.Equal(t, "github.com/byExist/priorityqueues/snapshot_test.less func(int, int) bool", snapshot.Identity(less))
+	assert.NotEqual(t, snapshot.Identity(lessGeneric[int]), snapshot.Identity(lessGeneric[string]))
+	assert.Equal(t, "", snapshot.Identity(nil))
+	assert.Equal(t, "", snapshot.Identity(42))
+	var fn func()
+	assert.Equal(t, "", snapshot.Identity(fn))
+	// Closures made by the same function share a name, whatever they capture.
+	lessBy := func(desc bool) func(a, b int) bool {
+		return func(a, b int) bool { return a < b != desc }
+	}
+	assert.Equal(t, "", snapshot.Identity(lessBy(false)))
+	assert.Equal(t, "", snapshot.Identity(func(a, b int) bool { return a < b }))
+}
+
+func ExampleAppendHeader() {
+	data := snapshot.AppendHeader(nil, snapshot.Header{Kind: "mpqs", Comparator: "main.less", Count: 0})
+	h, _, err := snapshot.ReadHeader(data)
+	fmt.Println(h.Version, h.Kind, h.Comparator, h.Count, err)
+	// Output: 2 mpqs main.less 0 <nil>
+}
@@ -0,0 +1,255 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+
+	"github.com/byExist/priorityqueues/kmpqs"
+)
+
+// KMPQS is a thread-safe wrapper around a kmpqs.PriorityQueue.
+type KMPQS[K comparable, T any, P any] struct {
+	mu       gosync.Mutex
+	q        *kmpqs.PriorityQueue[K, T, P]
+	capacity int
+	closed   bool
+	changed  signal
+}
+
+// NewKMPQS wraps q for concurrent use.
+func NewKMPQS[K comparable, T any, P any](q *kmpqs.PriorityQueue[K, T, P]) *KMPQS[K, T, P] {
+	return &KMPQS[K, T, P]{q: q}
+}
+
+// SetCapacity limits the number of queued items; zero or a negative value means unbounded.
+// Lowering the capacity never removes items, it only blocks or rejects further enqueues.
+func (s *KMPQS[K, T, P]) SetCapacity(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = capacity
+	s.changed.broadcast()
+}
+
+// Close marks the queue as closed and wakes every goroutine blocked in EnqueueContext or DequeueContext.
+// Items still in the queue can be dequeued after Close. Closing an already closed queue has no effect.
+func (s *KMPQS[K, T, P]) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.changed.broadcast()
+}
+
+// full reports whether enqueueing item would exceed the capacity.
+// Items whose key is already queued never grow the queue.
+func (s *KMPQS[K, T, P]) full(item T) bool {
+	return s.capacity > 0 && kmpqs.Len(s.q) >= s.capacity && !kmpqs.Contains(s.q, item)
+}
+
+// Enqueue inserts a new item with the given priority without blocking, applying the queue's duplicate policy.
+// It returns ErrClosed if the queue is closed and ErrFull if it is at capacity.
+func (s *KMPQS[K, T, P]) Enqueue(item T, prio P) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.full(item) {
+		return ErrFull
+	}
+	defer s.changed.broadcast()
+	return kmpqs.Enqueue(s.q, item, prio)
+}
+
+// EnqueueContext inserts a new item with the given priority, waiting while the queue is at capacity.
+// It returns ErrClosed if the queue is or becomes closed, or ctx.Err() if ctx is done first.
+func (s *KMPQS[K, T, P]) EnqueueContext(ctx context.Context, item T, prio P) error {
+	s.mu.Lock()
+	for {
+		if s.closed {
+			s.mu.Unlock()
+			return ErrClosed
+		}
+		if !s.full(item) {
+			err := kmpqs.Enqueue(s.q, item, prio)
+			s.changed.broadcast()
+			s.mu.Unlock()
+			return err
+		}
+		changed := s.changed.wait()
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+	}
+}
+
+// UpdateOrEnqueue updates the item if its key is already queued and enqueues it otherwise.
+// Unlike Enqueue, it always updates an existing key regardless of the duplicate policy.
+// It returns ErrClosed if the queue is closed and ErrFull if a new item does not fit.
+func (s *KMPQS[K, T, P]) UpdateOrEnqueue(item T, prio P) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if kmpqs.Update(s.q, item, prio) {
+		return nil
+	}
+	if s.full(item) {
+		return ErrFull
+	}
+	defer s.changed.broadcast()
+	return kmpqs.Enqueue(s.q, item, prio)
+}
+
+// Dequeue removes and returns the highest priority item without blocking.
+// The boolean indicates whether an item was returned.
+func (s *KMPQS[K, T, P]) Dequeue() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := kmpqs.Dequeue(s.q)
+	if ok {
+		s.changed.broadcast()
+	}
+	return item, ok
+}
+
+// DequeueContext removes and returns the highest priority item, waiting until one is available.
+// It returns ErrClosed once the queue is closed and empty, or ctx.Err() if ctx is done first.
+func (s *KMPQS[K, T, P]) DequeueContext(ctx context.Context) (T, error) {
+	s.mu.Lock()
+	for {
+		if item, ok := kmpqs.Dequeue(s.q); ok {
+			s.changed.broadcast()
+			s.mu.Unlock()
+			return item, nil
+		}
+		if s.closed {
+			s.mu.Unlock()
+			var zero T
+			return zero, ErrClosed
+		}
+		changed := s.changed.wait()
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		s.mu.Lock()
+	}
+}
+
+// DequeueIf removes and returns the highest priority item only if pred reports true for it.
+// The boolean indicates whether an item was returned.
+func (s *KMPQS[K, T, P]) DequeueIf(pred func(T) bool) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := kmpqs.Peek(s.q)
+	if !ok || !pred(item) {
+		var zero T
+		return zero, false
+	}
+	s.changed.broadcast()
+	return kmpqs.Dequeue(s.q)
+}
+
+// Peek returns the highest priority item without removing it.
+// The boolean indicates whether an item was returned.
+func (s *KMPQS[K, T, P]) Peek() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.Peek(s.q)
+}
+
+// Update replaces an existing item and sets its priority to newPrio.
+// Returns true if the item exists and was successfully updated.
+func (s *KMPQS[K, T, P]) Update(item T, newPrio P) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.Update(s.q, item, newPrio)
+}
+
+// UpdateByKey changes the priority of the item identified by key, keeping the stored item.
+// Returns true if the key exists and the item was successfully updated.
+func (s *KMPQS[K, T, P]) UpdateByKey(key K, newPrio P) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.UpdateByKey(s.q, key, newPrio)
+}
+
+// Delete removes an item identified by its key from the priority queue.
+// Returns true if the item existed and was successfully removed.
+func (s *KMPQS[K, T, P]) Delete(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.changed.broadcast()
+	return kmpqs.Delete(s.q, item)
+}
+
+// DeleteByKey removes the item identified by key and returns it.
+// The boolean indicates whether the key existed and the item was removed.
+func (s *KMPQS[K, T, P]) DeleteByKey(key K) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.changed.broadcast()
+	return kmpqs.DeleteByKey(s.q, key)
+}
+
+// GetByKey returns the stored item identified by key without removing it.
+// The boolean indicates whether the key exists.
+func (s *KMPQS[K, T, P]) GetByKey(key K) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.GetByKey(s.q, key)
+}
+
+// PriorityOf returns the stored priority of the item identified by key.
+// The boolean indicates whether the key exists.
+func (s *KMPQS[K, T, P]) PriorityOf(key K) (P, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.PriorityOf(s.q, key)
+}
+
+// Contains returns true if the queue contains an item identified by its key.
+func (s *KMPQS[K, T, P]) Contains(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.Contains(s.q, item)
+}
+
+// ContainsKey returns true if the queue contains an item with the given key.
+func (s *KMPQS[K, T, P]) ContainsKey(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.ContainsKey(s.q, key)
+}
+
+// Len returns the number of items currently in the priority queue.
+func (s *KMPQS[K, T, P]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kmpqs.Len(s.q)
+}
+
+// Clear removes all elements from the priority queue.
+func (s *KMPQS[K, T, P]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kmpqs.Clear(s.q)
+	s.changed.broadcast()
+}
+
+// Do calls fn with the underlying queue while holding the lock, so that several
+// operations can be performed atomically. fn must not retain q or call methods on s.
+func (s *KMPQS[K, T, P]) Do(fn func(q *kmpqs.PriorityQueue[K, T, P])) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.q)
+	s.changed.broadcast()
+}
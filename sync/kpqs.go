@@ -0,0 +1,149 @@
+package sync
+
+import (
+	gosync "sync"
+
+	"github.com/byExist/priorityqueues/kpqs"
+)
+
+// KPQS is a thread-safe wrapper around a kpqs.PriorityQueue.
+type KPQS[K comparable, T any, P any] struct {
+	mu gosync.Mutex
+	q  *kpqs.PriorityQueue[K, T, P]
+}
+
+// NewKPQS wraps q for concurrent use.
+func NewKPQS[K comparable, T any, P any](q *kpqs.PriorityQueue[K, T, P]) *KPQS[K, T, P] {
+	return &KPQS[K, T, P]{q: q}
+}
+
+// Enqueue inserts a new item into the priority queue, applying the queue's duplicate policy.
+func (s *KPQS[K, T, P]) Enqueue(item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Enqueue(s.q, item)
+}
+
+// UpdateOrEnqueue updates the item if its key is already queued and enqueues it otherwise.
+// Unlike Enqueue, it always updates an existing key regardless of the duplicate policy.
+func (s *KPQS[K, T, P]) UpdateOrEnqueue(item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if kpqs.Update(s.q, item) {
+		return nil
+	}
+	return kpqs.Enqueue(s.q, item)
+}
+
+// Dequeue removes and returns the highest priority item.
+// The boolean indicates whether an item was returned.
+func (s *KPQS[K, T, P]) Dequeue() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Dequeue(s.q)
+}
+
+// DequeueIf removes and returns the highest priority item only if pred reports true for it.
+// The boolean indicates whether an item was returned.
+func (s *KPQS[K, T, P]) DequeueIf(pred func(T) bool) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := kpqs.Peek(s.q)
+	if !ok || !pred(item) {
+		var zero T
+		return zero, false
+	}
+	return kpqs.Dequeue(s.q)
+}
+
+// Peek returns the highest priority item without removing it.
+// The boolean indicates whether an item was returned.
+func (s *KPQS[K, T, P]) Peek() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Peek(s.q)
+}
+
+// Update modifies the priority of an existing item using the queue's prioFunc.
+// Returns true if the item exists and was successfully updated.
+func (s *KPQS[K, T, P]) Update(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Update(s.q, item)
+}
+
+// UpdateByKey re-evaluates the priority of the stored item identified by key.
+// Returns true if the key exists and the item was successfully updated.
+func (s *KPQS[K, T, P]) UpdateByKey(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.UpdateByKey(s.q, key)
+}
+
+// Delete removes an item identified by its key from the priority queue.
+// Returns true if the item existed and was successfully removed.
+func (s *KPQS[K, T, P]) Delete(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Delete(s.q, item)
+}
+
+// DeleteByKey removes the item identified by key and returns it.
+// The boolean indicates whether the key existed and the item was removed.
+func (s *KPQS[K, T, P]) DeleteByKey(key K) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.DeleteByKey(s.q, key)
+}
+
+// GetByKey returns the stored item identified by key without removing it.
+// The boolean indicates whether the key exists.
+func (s *KPQS[K, T, P]) GetByKey(key K) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.GetByKey(s.q, key)
+}
+
+// PriorityOf returns the stored priority of the item identified by key.
+// The boolean indicates whether the key exists.
+func (s *KPQS[K, T, P]) PriorityOf(key K) (P, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.PriorityOf(s.q, key)
+}
+
+// Contains returns true if the queue contains an item identified by its key.
+func (s *KPQS[K, T, P]) Contains(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Contains(s.q, item)
+}
+
+// ContainsKey returns true if the queue contains an item with the given key.
+func (s *KPQS[K, T, P]) ContainsKey(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.ContainsKey(s.q, key)
+}
+
+// Len returns the number of items in the priority queue.
+func (s *KPQS[K, T, P]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kpqs.Len(s.q)
+}
+
+// Clear removes all elements from the priority queue.
+func (s *KPQS[K, T, P]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kpqs.Clear(s.q)
+}
+
+// Do calls fn with the underlying queue while holding the lock, so that several
+// operations can be performed atomically. fn must not retain q or call methods on s.
+func (s *KPQS[K, T, P]) Do(fn func(q *kpqs.PriorityQueue[K, T, P])) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.q)
+}
@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+
+	"github.com/byExist/priorityqueues/mpqs"
+)
+
+// MPQS is a thread-safe wrapper around an mpqs.PriorityQueue.
+type MPQS[T any, P any] struct {
+	mu       gosync.Mutex
+	q        *mpqs.PriorityQueue[T, P]
+	capacity int
+	closed   bool
+	changed  signal
+}
+
+// NewMPQS wraps q for concurrent use.
+func NewMPQS[T any, P any](q *mpqs.PriorityQueue[T, P]) *MPQS[T, P] {
+	return &MPQS[T, P]{q: q}
+}
+
+// SetCapacity limits the number of queued elements; zero or a negative value means unbounded.
+// Lowering the capacity never removes elements, it only blocks or rejects further enqueues.
+func (s *MPQS[T, P]) SetCapacity(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = capacity
+	s.changed.broadcast()
+}
+
+// Close marks the queue as closed and wakes every goroutine blocked in EnqueueContext or DequeueContext.
+// Items still in the queue can be dequeued after Close. Closing an already closed queue has no effect.
+func (s *MPQS[T, P]) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.changed.broadcast()
+}
+
+func (s *MPQS[T, P]) full() bool {
+	return s.capacity > 0 && mpqs.Len(s.q) >= s.capacity
+}
+
+// Enqueue inserts a new item with the given priority into the priority queue without blocking.
+// It returns ErrClosed if the queue is closed, ErrFull if it is at capacity, and mpqs.ErrNaN
+// if the priority is rejected by the queue's NaN policy.
+func (s *MPQS[T, P]) Enqueue(item T, prio P) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.full() {
+		return ErrFull
+	}
+	defer s.changed.broadcast()
+	_, err := mpqs.Enqueue(s.q, item, prio)
+	return err
+}
+
+// EnqueueContext inserts a new item with the given priority, waiting while the queue is at capacity.
+// It returns ErrClosed if the queue is or becomes closed, or ctx.Err() if ctx is done first.
+func (s *MPQS[T, P]) EnqueueContext(ctx context.Context, item T, prio P) error {
+	s.mu.Lock()
+	for {
+		if s.closed {
+			s.mu.Unlock()
+			return ErrClosed
+		}
+		if !s.full() {
+			_, err := mpqs.Enqueue(s.q, item, prio)
+			s.changed.broadcast()
+			s.mu.Unlock()
+			return err
+		}
+		changed := s.changed.wait()
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+	}
+}
+
+// Dequeue removes and returns the item with the highest priority without blocking.
+// The boolean indicates whether an item was returned.
+func (s *MPQS[T, P]) Dequeue() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := mpqs.Dequeue(s.q)
+	if ok {
+		s.changed.broadcast()
+	}
+	return item, ok
+}
+
+// DequeueContext removes and returns the item with the highest priority, waiting until one is available.
+// It returns ErrClosed once the queue is closed and empty, or ctx.Err() if ctx is done first.
+func (s *MPQS[T, P]) DequeueContext(ctx context.Context) (T, error) {
+	s.mu.Lock()
+	for {
+		if item, ok := mpqs.Dequeue(s.q); ok {
+			s.changed.broadcast()
+			s.mu.Unlock()
+			return item, nil
+		}
+		if s.closed {
+			s.mu.Unlock()
+			var zero T
+			return zero, ErrClosed
+		}
+		changed := s.changed.wait()
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		s.mu.Lock()
+	}
+}
+
+// DequeueIf removes and returns the item with the highest priority only if pred reports true for it.
+// The boolean indicates whether an item was returned.
+func (s *MPQS[T, P]) DequeueIf(pred func(T) bool) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := mpqs.Peek(s.q)
+	if !ok || !pred(item) {
+		var zero T
+		return zero, false
+	}
+	s.changed.broadcast()
+	return mpqs.Dequeue(s.q)
+}
+
+// Peek returns the item with the highest priority without removing it.
+// The boolean indicates whether an item was returned.
+func (s *MPQS[T, P]) Peek() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return mpqs.Peek(s.q)
+}
+
+// Len returns the number of elements currently in the priority queue.
+func (s *MPQS[T, P]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return mpqs.Len(s.q)
+}
+
+// Clear removes all elements from the priority queue and resets its sequence counter.
+func (s *MPQS[T, P]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mpqs.Clear(s.q)
+	s.changed.broadcast()
+}
+
+// Do calls fn with the underlying queue while holding the lock, so that several
+// operations can be performed atomically. fn must not retain q or call methods on s.
+func (s *MPQS[T, P]) Do(fn func(q *mpqs.PriorityQueue[T, P])) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.q)
+	s.changed.broadcast()
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	gosync "sync"
+
+	"github.com/byExist/priorityqueues/pqs"
+)
+
+// PQS is a thread-safe wrapper around a pqs.PriorityQueue.
+type PQS[T any] struct {
+	mu gosync.Mutex
+	q  *pqs.PriorityQueue[T]
+}
+
+// NewPQS wraps q for concurrent use.
+func NewPQS[T any](q *pqs.PriorityQueue[T]) *PQS[T] {
+	return &PQS[T]{q: q}
+}
+
+// Enqueue inserts a new item into the priority queue.
+// It returns pqs.ErrNaN if the item is rejected by the queue's NaN policy.
+func (s *PQS[T]) Enqueue(item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pqs.Enqueue(s.q, item)
+}
+
+// Dequeue removes and returns the highest priority item.
+// The boolean indicates whether an item was returned.
+func (s *PQS[T]) Dequeue() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pqs.Dequeue(s.q)
+}
+
+// DequeueIf removes and returns the highest priority item only if pred reports true for it.
+// The boolean indicates whether an item was returned.
+func (s *PQS[T]) DequeueIf(pred func(T) bool) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := pqs.Peek(s.q)
+	if !ok || !pred(item) {
+		var zero T
+		return zero, false
+	}
+	return pqs.Dequeue(s.q)
+}
+
+// Peek returns the highest priority item without removing it.
+// The boolean indicates whether an item was returned.
+func (s *PQS[T]) Peek() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pqs.Peek(s.q)
+}
+
+// Len returns the number of items currently in the priority queue.
+func (s *PQS[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pqs.Len(s.q)
+}
+
+// Clear removes all items from the priority queue.
+func (s *PQS[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pqs.Clear(s.q)
+}
+
+// Do calls fn with the underlying queue while holding the lock, so that several
+// operations can be performed atomically. fn must not retain q or call methods on s.
+func (s *PQS[T]) Do(fn func(q *pqs.PriorityQueue[T])) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.q)
+}
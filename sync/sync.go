@@ -0,0 +1,42 @@
+// Package sync provides thread-safe wrappers around the pqs, mpqs, kpqs and kmpqs priority queues.
+//
+// Each wrapper guards the underlying queue with a mutex and exposes the same operations as methods,
+// plus compound operations such as DequeueIf and UpdateOrEnqueue that run atomically.
+// Once a queue is wrapped, it must only be accessed through the wrapper.
+//
+// MPQS and KMPQS additionally support blocking use: DequeueContext waits for an item,
+// EnqueueContext waits for free capacity set with SetCapacity, and Close wakes every
+// waiter while still allowing the remaining items to be drained.
+package sync
+
+import "errors"
+
+var (
+	// ErrClosed is returned when enqueueing into a closed queue, or when waiting
+	// to dequeue from a queue that is closed and empty.
+	ErrClosed = errors.New("sync: queue closed")
+	// ErrFull is returned by non-blocking enqueue operations when the queue is at capacity.
+	ErrFull = errors.New("sync: queue full")
+)
+
+// signal wakes goroutines blocked on a state change of a queue.
+// It must be guarded by the mutex of the queue it belongs to.
+type signal struct {
+	ch chan struct{}
+}
+
+// wait returns a channel that is closed on the next broadcast.
+func (s *signal) wait() <-chan struct{} {
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+// broadcast wakes all goroutines waiting on the channels returned by wait.
+func (s *signal) broadcast() {
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
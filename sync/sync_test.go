@@ -0,0 +1,396 @@
+package sync_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byExist/priorityqueues/kmpqs"
+	"github.com/byExist/priorityqueues/kpqs"
+	"github.com/byExist/priorityqueues/mpqs"
+	"github.com/byExist/priorityqueues/pqs"
+	pqsync "github.com/byExist/priorityqueues/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+type Job struct {
+	ID       int
+	Priority int
+}
+
+const (
+	producers   = 8
+	consumers   = 8
+	perProducer = 500
+)
+
+// runProducersConsumers starts producers that each call produce perProducer times and
+// consumers that call consume until every produced item has been taken.
+func runProducersConsumers(t *testing.T, produce func(p, i int), consume func() (int, bool)) {
+	t.Helper()
+	total := producers * perProducer
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perProducer {
+				produce(p, i)
+			}
+		}()
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int]bool, total)
+	for range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				done := len(seen) == total
+				mu.Unlock()
+				if done {
+					return
+				}
+				id, ok := consume()
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				assert.False(t, seen[id], "item %d consumed twice", id)
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, total)
+}
+
+func TestPQSConcurrent(t *testing.T) {
+	q := pqsync.NewPQS(pqs.New(pqs.MinFirst[int]))
+	runProducersConsumers(t,
+		func(p, i int) { q.Enqueue(p*perProducer + i) },
+		q.Dequeue,
+	)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestMPQSConcurrent(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[int, int]))
+	runProducersConsumers(t,
+		func(p, i int) { q.Enqueue(p*perProducer+i, i%10) },
+		q.Dequeue,
+	)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestKPQSConcurrent(t *testing.T) {
+	q := pqsync.NewKPQS(kpqs.New(
+		kpqs.StableMinFirst[Job, int],
+		func(j Job) int { return j.ID },
+		func(j Job) int { return j.Priority },
+	))
+	runProducersConsumers(t,
+		func(p, i int) {
+			id := p*perProducer + i
+			assert.NoError(t, q.Enqueue(Job{ID: id, Priority: i % 10}))
+			q.UpdateByKey(id)
+		},
+		func() (int, bool) {
+			j, ok := q.Dequeue()
+			return j.ID, ok
+		},
+	)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestKMPQSConcurrent(t *testing.T) {
+	q := pqsync.NewKMPQS(kmpqs.New(
+		kmpqs.StableMinFirst[int, int],
+		func(id int) int { return id },
+	))
+	runProducersConsumers(t,
+		func(p, i int) {
+			id := p*perProducer + i
+			assert.NoError(t, q.Enqueue(id, i%10))
+			q.UpdateByKey(id, i%5)
+		},
+		q.Dequeue,
+	)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestKMPQSConcurrentUpdateOrEnqueue(t *testing.T) {
+	kq := kmpqs.New(
+		kmpqs.StableMaxFirst[int, int],
+		func(id int) int { return id },
+	)
+	kmpqs.SetDuplicatePolicy(kq, kmpqs.Reject)
+	q := pqsync.NewKMPQS(kq)
+
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perProducer {
+				assert.NoError(t, q.UpdateOrEnqueue(i%50, p))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, q.Len())
+}
+
+func TestDequeueIf(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.MinFirst[string, int]))
+	q.Enqueue("a", 1)
+	_, ok := q.DequeueIf(func(s string) bool { return s == "b" })
+	assert.False(t, ok)
+	item, ok := q.DequeueIf(func(s string) bool { return s == "a" })
+	assert.True(t, ok)
+	assert.Equal(t, "a", item)
+	_, ok = q.DequeueIf(func(string) bool { return true })
+	assert.False(t, ok)
+}
+
+func TestDequeueIfConcurrent(t *testing.T) {
+	q := pqsync.NewPQS(pqs.New(pqs.MinFirst[int]))
+	for i := range 1000 {
+		q.Enqueue(i)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var taken []int
+	for range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, ok := q.DequeueIf(func(i int) bool { return i < 500 })
+				if !ok {
+					return
+				}
+				mu.Lock()
+				taken = append(taken, item)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, taken, 500)
+	assert.Equal(t, 500, q.Len())
+}
+
+func TestKPQSKeyOperations(t *testing.T) {
+	q := pqsync.NewKPQS(kpqs.New(
+		kpqs.StableMinFirst[*Job, int],
+		func(j *Job) int { return j.ID },
+		func(j *Job) int { return j.Priority },
+	))
+	j := &Job{ID: 1, Priority: 3}
+	assert.NoError(t, q.UpdateOrEnqueue(j))
+	assert.True(t, q.Contains(j))
+	assert.True(t, q.ContainsKey(1))
+	prio, _ := q.PriorityOf(1)
+	assert.Equal(t, 3, prio)
+	j.Priority = 1
+	assert.True(t, q.Update(j))
+	got, _ := q.GetByKey(1)
+	assert.Equal(t, 1, got.Priority)
+	_, ok := q.DeleteByKey(1)
+	assert.True(t, ok)
+	assert.False(t, q.Delete(j))
+}
+
+func TestDo(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	q.Enqueue("a", 2)
+	q.Enqueue("b", 1)
+	var items []string
+	q.Do(func(q *mpqs.PriorityQueue[string, int]) {
+		for item := range mpqs.Drain(q) {
+			items = append(items, item)
+		}
+	})
+	assert.Equal(t, []string{"b", "a"}, items)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestMPQSDequeueContextWaits(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	got := make(chan string)
+	go func() {
+		item, err := q.DequeueContext(context.Background())
+		assert.NoError(t, err)
+		got <- item
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, q.Enqueue("a", 1))
+	assert.Equal(t, "a", <-got)
+}
+
+func TestMPQSDequeueContextCancel(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.DequeueContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMPQSEnqueueContextCapacity(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	q.SetCapacity(1)
+	assert.NoError(t, q.Enqueue("a", 1))
+	assert.ErrorIs(t, q.Enqueue("b", 1), pqsync.ErrFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.EnqueueContext(ctx, "b", 1), context.DeadlineExceeded)
+
+	done := make(chan error)
+	go func() {
+		done <- q.EnqueueContext(context.Background(), "b", 1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	item, _ := q.Dequeue()
+	assert.Equal(t, "a", item)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestMPQSCloseWakesAndDrains(t *testing.T) {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	errs := make(chan error, consumers)
+	for range consumers {
+		go func() {
+			_, err := q.DequeueContext(context.Background())
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	for range consumers {
+		assert.ErrorIs(t, <-errs, pqsync.ErrClosed)
+	}
+
+	q2 := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	q2.Enqueue("a", 1)
+	q2.Enqueue("b", 2)
+	q2.Close()
+	assert.ErrorIs(t, q2.Enqueue("c", 3), pqsync.ErrClosed)
+	item, err := q2.DequeueContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "a", item)
+	item, ok := q2.Dequeue()
+	assert.True(t, ok)
+	assert.Equal(t, "b", item)
+	_, err = q2.DequeueContext(context.Background())
+	assert.ErrorIs(t, err, pqsync.ErrClosed)
+}
+
+func TestKMPQSBlockingWorkers(t *testing.T) {
+	q := pqsync.NewKMPQS(kmpqs.New(
+		kmpqs.StableMinFirst[int, int],
+		func(id int) int { return id },
+	))
+	q.SetCapacity(16)
+	total := producers * perProducer
+
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perProducer {
+				assert.NoError(t, q.EnqueueContext(context.Background(), p*perProducer+i, i%10))
+			}
+		}()
+	}
+
+	var cwg sync.WaitGroup
+	counts := make(chan int, consumers)
+	for range consumers {
+		cwg.Add(1)
+		go func() {
+			defer cwg.Done()
+			n := 0
+			for {
+				_, err := q.DequeueContext(context.Background())
+				if err != nil {
+					assert.ErrorIs(t, err, pqsync.ErrClosed)
+					counts <- n
+					return
+				}
+				n++
+			}
+		}()
+	}
+
+	wg.Wait()
+	q.Close()
+	cwg.Wait()
+	close(counts)
+	sum := 0
+	for n := range counts {
+		sum += n
+	}
+	assert.Equal(t, total, sum)
+}
+
+func TestKMPQSCapacityIgnoresExistingKeys(t *testing.T) {
+	q := pqsync.NewKMPQS(kmpqs.New(
+		kmpqs.StableMinFirst[string, int],
+		func(s string) string { return s },
+	))
+	q.SetCapacity(1)
+	assert.NoError(t, q.Enqueue("a", 2))
+	assert.NoError(t, q.Enqueue("a", 1))
+	assert.NoError(t, q.UpdateOrEnqueue("a", 3))
+	assert.ErrorIs(t, q.UpdateOrEnqueue("b", 3), pqsync.ErrFull)
+	q.Close()
+	assert.ErrorIs(t, q.UpdateOrEnqueue("a", 3), pqsync.ErrClosed)
+}
+
+func ExampleKMPQS_UpdateOrEnqueue() {
+	q := pqsync.NewKMPQS(kmpqs.New(
+		kmpqs.StableMinFirst[string, int],
+		func(s string) string { return s },
+	))
+	q.UpdateOrEnqueue("job", 5)
+	q.UpdateOrEnqueue("job", 1)
+	prio, _ := q.PriorityOf("job")
+	fmt.Println(q.Len(), prio)
+	// Output: 1 1
+}
+
+func ExampleMPQS_DequeueIf() {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.MinFirst[string, int]))
+	q.Enqueue("a", 1)
+	_, ok := q.DequeueIf(func(s string) bool { return s != "a" })
+	fmt.Println(ok, q.Len())
+	// Output: false 1
+}
+
+func ExampleMPQS_DequeueContext() {
+	q := pqsync.NewMPQS(mpqs.New(mpqs.StableMinFirst[string, int]))
+	go func() {
+		q.Enqueue("job", 1)
+		q.Close()
+	}()
+	for {
+		item, err := q.DequeueContext(context.Background())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(item)
+	}
+	// Output:
+	// job
+	// sync: queue closed
+}